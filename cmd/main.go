@@ -2,17 +2,26 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/auth"
+	"github.com/masjids-io/limestone-chat/internal/domain"
 	"github.com/masjids-io/limestone-chat/internal/infrastructure/database"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/email"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/storage"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/webhook"
 	"github.com/masjids-io/limestone-chat/internal/infrastructure/websocket"
 	"github.com/masjids-io/limestone-chat/internal/interfaces/api"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -21,13 +30,166 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	chatService := services.NewChatService(db)
+	storageDir := os.Getenv("ATTACHMENT_STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = "data/attachments"
+	}
+	blobStore, err := storage.NewLocalBlobStore(storageDir)
+	if err != nil {
+		log.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+	maxAttachmentBytes := int64(25 << 20)
+	if v, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil && v > 0 {
+		maxAttachmentBytes = v
+	}
+
+	// Downloads are authorised by the URL signature alone, so the key must
+	// never be guessable.
+	urlSecret := os.Getenv("ATTACHMENT_URL_SECRET")
+	if urlSecret == "" {
+		accessSecret := os.Getenv("ACCESS_SECRET")
+		if accessSecret == "" {
+			log.Fatal("ATTACHMENT_URL_SECRET or ACCESS_SECRET must be set to sign attachment URLs")
+		}
+		log.Println("ATTACHMENT_URL_SECRET not set, deriving attachment URL signing key from ACCESS_SECRET.")
+		urlSecret = "attachments:" + accessSecret
+	}
+	urlTTL, err := time.ParseDuration(os.Getenv("ATTACHMENT_URL_TTL"))
+	if err != nil || urlTTL <= 0 {
+		urlTTL = 5 * time.Minute
+	}
+
+	moderation, err := newModerationPipeline()
+	if err != nil {
+		log.Fatalf("Failed to initialize moderation: %v", err)
+	}
+
+	policy, err := services.LoadConversationPolicy()
+	if err != nil {
+		log.Fatalf("Failed to load conversation policy: %v", err)
+	}
+
+	businessHoursFile := os.Getenv("BUSINESS_HOURS_FILE")
+	if businessHoursFile == "" {
+		businessHoursFile = "config/business_hours.json"
+	}
+	businessHours, err := services.LoadBusinessHours(businessHoursFile)
+	if err != nil {
+		log.Fatalf("Failed to load business hours: %v", err)
+	}
+
+	assignment, err := services.NewAssignmentStrategy(os.Getenv("SUPPORT_ASSIGNMENT_STRATEGY"))
+	if err != nil {
+		log.Fatalf("Failed to configure support assignment: %v", err)
+	}
+	agentMaxTickets := 5
+	if v, err := strconv.Atoi(os.Getenv("SUPPORT_AGENT_MAX_TICKETS")); err == nil && v > 0 {
+		agentMaxTickets = v
+	}
+	slaTargets, err := services.LoadSLATargets()
+	if err != nil {
+		log.Fatalf("Failed to load support SLA targets: %v", err)
+	}
+
+	// The hub depends on the chat service, so the chat service and the
+	// support queue it drains reach the hub through a notifier attached once
+	// the hub exists.
+	notifier := &services.DeferredNotifier{}
+	supportService := services.NewSupportService(db, notifier, assignment, agentMaxTickets, slaTargets)
+	chatService := services.NewChatService(db, services.NewMessageTypeRegistry(), moderation, policy, businessHours, supportService, notifier)
+	attachmentService := services.NewAttachmentService(db, blobStore, auth.NewURLSigner(urlSecret, urlTTL), maxAttachmentBytes)
 	chatHub := websocket.NewHub(chatService, db)
+	notifier.Attach(chatHub)
+	reportService := services.NewReportService(db, chatHub)
+	blockService := services.NewBlockService(db)
+	guardianService := services.NewGuardianService(db, chatHub)
+
+	mentorProposalTTL, err := time.ParseDuration(os.Getenv("MENTOR_PROPOSAL_TTL"))
+	if err != nil || mentorProposalTTL <= 0 {
+		mentorProposalTTL = 48 * time.Hour
+	}
+	mentorInactiveAfter, err := time.ParseDuration(os.Getenv("MENTOR_INACTIVE_AFTER"))
+	if err != nil || mentorInactiveAfter <= 0 {
+		mentorInactiveAfter = 14 * 24 * time.Hour
+	}
+	mentorshipService := services.NewMentorshipService(db, chatHub, mentorProposalTTL, mentorInactiveAfter)
+	savedReplyService := services.NewSavedReplyService(db)
+	surveyService := services.NewSurveyService(db)
+	pinService := services.NewPinService(db, chatHub)
+
+	masjidLocation, err := services.LoadMasjidLocation()
+	if err != nil {
+		log.Fatalf("Failed to load masjid location: %v", err)
+	}
+	quietHoursService := services.NewQuietHoursService(db, masjidLocation)
+	notificationService := services.NewNotificationService(db, newNotificationSender(), chatHub, quietHoursService, loadRetryPolicy())
+	digestService, err := newDigestService(db, quietHoursService)
+	if err != nil {
+		log.Fatalf("Failed to initialize email digests: %v", err)
+	}
 
-	webSocketHandler := api.NewWebSocketHandler(chatService, chatHub)
+	webSocketHandler := api.NewWebSocketHandler(chatService, supportService, chatHub)
+	attachmentHandler := api.NewAttachmentHandler(attachmentService, maxAttachmentBytes)
+	conversationHandler := api.NewConversationHandler(chatService, attachmentService)
+	reportHandler := api.NewReportHandler(reportService)
+	blockHandler := api.NewBlockHandler(blockService)
+	guardianHandler := api.NewGuardianHandler(guardianService)
+	supportHandler := api.NewSupportHandler(supportService)
+	mentorshipHandler := api.NewMentorshipHandler(mentorshipService)
+	savedReplyHandler := api.NewSavedReplyHandler(savedReplyService)
+	surveyHandler := api.NewSurveyHandler(surveyService)
+	quietHoursHandler := api.NewQuietHoursHandler(quietHoursService)
+	notificationHandler := api.NewNotificationHandler(notificationService)
+	pinHandler := api.NewPinHandler(pinService)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", webSocketHandler.ServeChatWs)
+	mux.HandleFunc("POST /attachments", auth.RequireAuth(attachmentHandler.Upload))
+	mux.HandleFunc("GET /attachments/{id}/url", auth.RequireAuth(attachmentHandler.DownloadURL))
+	mux.HandleFunc("GET /attachments/{id}/download", attachmentHandler.Download)
+	mux.HandleFunc("GET /conversations", auth.RequireAuth(conversationHandler.List))
+	mux.HandleFunc("GET /conversations/{id}/messages", auth.RequireAuth(conversationHandler.Messages))
+	mux.HandleFunc("POST /conversations/{id}/status", auth.RequireAuth(conversationHandler.ChangeStatus))
+	mux.HandleFunc("GET /mentions", auth.RequireAuth(conversationHandler.Mentions))
+	mux.HandleFunc("GET /messages/{id}/thread", auth.RequireAuth(conversationHandler.Thread))
+	mux.HandleFunc("GET /conversations/{id}/pins", auth.RequireAuth(pinHandler.List))
+	mux.HandleFunc("PUT /conversations/{id}/pins/{message_id}", auth.RequireAuth(pinHandler.Pin))
+	mux.HandleFunc("DELETE /conversations/{id}/pins/{message_id}", auth.RequireAuth(pinHandler.Unpin))
+	mux.HandleFunc("POST /reports", auth.RequireAuth(reportHandler.Create))
+	mux.HandleFunc("GET /moderation/reports", auth.RequireAuth(reportHandler.Queue))
+	mux.HandleFunc("POST /moderation/reports/{id}/actions", auth.RequireAuth(reportHandler.Act))
+	mux.HandleFunc("GET /blocks", auth.RequireAuth(blockHandler.List))
+	mux.HandleFunc("PUT /blocks/{user_id}", auth.RequireAuth(blockHandler.Block))
+	mux.HandleFunc("DELETE /blocks/{user_id}", auth.RequireAuth(blockHandler.Unblock))
+	mux.HandleFunc("GET /guardian", auth.RequireAuth(guardianHandler.Get))
+	mux.HandleFunc("PUT /guardian", auth.RequireAuth(guardianHandler.Set))
+	mux.HandleFunc("DELETE /guardian", auth.RequireAuth(guardianHandler.Remove))
+	mux.HandleFunc("POST /conversations/{id}/approval", auth.RequireAuth(guardianHandler.Approve))
+	mux.HandleFunc("DELETE /conversations/{id}/observers/{user_id}", auth.RequireAuth(guardianHandler.RemoveObserver))
+	mux.HandleFunc("PUT /support/availability", auth.RequireAuth(supportHandler.SetAvailability))
+	mux.HandleFunc("GET /support/tickets", auth.RequireAuth(supportHandler.Tickets))
+	mux.HandleFunc("POST /support/tickets/{id}/transfer", auth.RequireAuth(supportHandler.Transfer))
+	mux.HandleFunc("GET /support/sla", auth.RequireAuth(supportHandler.SLAReport))
+	mux.HandleFunc("GET /surveys/report", auth.RequireAuth(surveyHandler.Report))
+	mux.HandleFunc("GET /saved-replies", auth.RequireAuth(savedReplyHandler.List))
+	mux.HandleFunc("POST /saved-replies", auth.RequireAuth(savedReplyHandler.Create))
+	mux.HandleFunc("PUT /saved-replies/{id}", auth.RequireAuth(savedReplyHandler.Update))
+	mux.HandleFunc("DELETE /saved-replies/{id}", auth.RequireAuth(savedReplyHandler.Delete))
+	mux.HandleFunc("GET /mentors/me", auth.RequireAuth(mentorshipHandler.MentorProfile))
+	mux.HandleFunc("PUT /mentors/me", auth.RequireAuth(mentorshipHandler.RegisterMentor))
+	mux.HandleFunc("GET /mentorships", auth.RequireAuth(mentorshipHandler.List))
+	mux.HandleFunc("POST /mentorships", auth.RequireAuth(mentorshipHandler.Request))
+	mux.HandleFunc("POST /mentorships/{id}/accept", auth.RequireAuth(mentorshipHandler.Accept))
+	mux.HandleFunc("POST /mentorships/{id}/decline", auth.RequireAuth(mentorshipHandler.Decline))
+	mux.HandleFunc("GET /quiet-hours", auth.RequireAuth(quietHoursHandler.Get))
+	mux.HandleFunc("PUT /quiet-hours", auth.RequireAuth(quietHoursHandler.Set))
+	mux.HandleFunc("DELETE /quiet-hours", auth.RequireAuth(quietHoursHandler.Delete))
+	mux.HandleFunc("GET /quiet-hours/prayer-times", auth.RequireAuth(quietHoursHandler.PrayerTimes))
+	mux.HandleFunc("GET /notifications/settings", auth.RequireAuth(notificationHandler.Settings))
+	mux.HandleFunc("PUT /notifications/settings", auth.RequireAuth(notificationHandler.UpdateSettings))
+	mux.HandleFunc("PUT /conversations/{id}/notifications", auth.RequireAuth(notificationHandler.UpdateConversationSettings))
+	mux.HandleFunc("GET /notifications/dead-letters", auth.RequireAuth(notificationHandler.DeadLetters))
+	mux.HandleFunc("POST /notifications/{id}/retry", auth.RequireAuth(notificationHandler.Retry))
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Limestone Chat Service is running. Connect to /ws?purpose=<your_purpose>"))
@@ -41,6 +203,15 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go mentorshipService.Run(background, 15*time.Minute)
+	go supportService.Run(background, time.Minute)
+	go notificationService.Run(background, 5*time.Second)
+	if digestService != nil {
+		go digestService.Run(background, 15*time.Minute)
+	}
+
 	go func() {
 		log.Printf("Server starting on %s", server.Addr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -53,6 +224,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -62,3 +234,105 @@ func main() {
 
 	log.Println("Server exited gracefully.")
 }
+
+// newNotificationSender posts offline notifications to the push gateway at
+// NOTIFICATION_WEBHOOK_URL. Without it, notifications are not sent.
+func newNotificationSender() services.NotificationSender {
+	url := os.Getenv("NOTIFICATION_WEBHOOK_URL")
+	if url == "" {
+		log.Println("NOTIFICATION_WEBHOOK_URL not set, offline notifications will not be sent.")
+		return nil
+	}
+	timeout, err := time.ParseDuration(os.Getenv("NOTIFICATION_WEBHOOK_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return webhook.NewSender(url, os.Getenv("NOTIFICATION_WEBHOOK_SECRET"), timeout)
+}
+
+// newDigestService emails digests of unread messages through the SMTP server
+// at SMTP_HOST. Without it, no digests are sent and it returns nil.
+func newDigestService(db *gorm.DB, quietHours services.QuietHoursService) (services.DigestService, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("SMTP_HOST not set, email digests are disabled.")
+		return nil, nil
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+	port := 587
+	if v, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil && v > 0 {
+		port = v
+	}
+	templateDir := os.Getenv("DIGEST_TEMPLATE_DIR")
+	if templateDir == "" {
+		templateDir = "config/templates"
+	}
+	templates, err := services.LoadDigestTemplates(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	options := services.DigestOptions{After: 24 * time.Hour, MinInterval: 24 * time.Hour, MaxMessages: 5, AppURL: os.Getenv("DIGEST_APP_URL")}
+	if d, err := time.ParseDuration(os.Getenv("DIGEST_AFTER")); err == nil && d > 0 {
+		options.After = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("DIGEST_MIN_INTERVAL")); err == nil && d > 0 {
+		options.MinInterval = d
+	}
+	if v, err := strconv.Atoi(os.Getenv("DIGEST_MAX_MESSAGES")); err == nil && v > 0 {
+		options.MaxMessages = v
+	}
+	sender := email.NewSMTPSender(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	return services.NewDigestService(db, sender, templates, quietHours, options), nil
+}
+
+func loadRetryPolicy() services.RetryPolicy {
+	policy := services.RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+	if v, err := strconv.Atoi(os.Getenv("NOTIFICATION_MAX_ATTEMPTS")); err == nil && v > 0 {
+		policy.MaxAttempts = v
+	}
+	if d, err := time.ParseDuration(os.Getenv("NOTIFICATION_RETRY_BASE")); err == nil && d > 0 {
+		policy.BaseDelay = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("NOTIFICATION_RETRY_MAX")); err == nil && d > 0 {
+		policy.MaxDelay = d
+	}
+	return policy
+}
+
+// newModerationPipeline builds the filters run on every message before it is
+// stored: profanity is masked everywhere, links are rejected in nikkah and
+// revert chats, and contact details are masked in nikkah chats until approval.
+func newModerationPipeline() (*services.ModerationPipeline, error) {
+	wordListDir := os.Getenv("MODERATION_WORDLIST_DIR")
+	if wordListDir == "" {
+		wordListDir = "config/wordlists"
+	}
+	profanity, err := services.LoadProfanityFilter(wordListDir, domain.ModerationActionMask)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowedDomains []string
+	for _, d := range strings.Split(os.Getenv("MODERATION_ALLOWED_LINK_DOMAINS"), ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			allowedDomains = append(allowedDomains, d)
+		}
+	}
+
+	return services.NewModerationPipeline(
+		profanity,
+		&services.LinkFilter{
+			Action:         domain.ModerationActionReject,
+			Purposes:       []domain.ConversationPurpose{domain.ConversationPurposeNikkah, domain.ConversationPurposeRevertService},
+			AllowedDomains: allowedDomains,
+		},
+		&services.ContactDetailsFilter{
+			Action:   domain.ModerationActionMask,
+			Purposes: []domain.ConversationPurpose{domain.ConversationPurposeNikkah},
+		},
+	), nil
+}
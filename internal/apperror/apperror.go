@@ -0,0 +1,131 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type Code string
+
+const (
+	CodeInvalidRequest Code = "invalid_request"
+	CodeUnauthorized   Code = "unauthorized"
+	CodeForbidden      Code = "forbidden"
+	CodeNotFound       Code = "not_found"
+	CodeConflict       Code = "conflict"
+	CodeTooLarge       Code = "payload_too_large"
+	CodeRejected       Code = "message_rejected"
+	CodeInternal       Code = "internal_error"
+	CodeUnavailable    Code = "service_unavailable"
+)
+
+// HTTPStatus maps an error code to the status used by REST handlers and by
+// ServeWs before the connection is upgraded.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeInvalidRequest:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeRejected:
+		return http.StatusUnprocessableEntity
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is the error model shared by every client-facing path. Message is safe
+// to show to clients; Err carries internal details and is only ever logged.
+type Error struct {
+	Code      Code
+	Message   string
+	Retryable bool
+	Err       error
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Internal wraps an unexpected failure. Internal errors are retryable because
+// they are usually caused by a transient database or network problem.
+func Internal(message string, err error) *Error {
+	return &Error{Code: CodeInternal, Message: message, Retryable: true, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// From returns err as an *Error, treating anything that is not already one as
+// an internal error so its details never reach the client.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal("Internal server error", err)
+}
+
+// Body is the JSON representation of an error sent to clients.
+type Body struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+// Frame is the envelope used for error responses on both WebSocket and HTTP.
+type Frame struct {
+	Error Body `json:"error"`
+}
+
+func NewFrame(err error, requestID string) Frame {
+	appErr := From(err)
+	return Frame{Error: Body{
+		Code:      appErr.Code,
+		Message:   appErr.Message,
+		RequestID: requestID,
+		Retryable: appErr.Retryable,
+	}}
+}
+
+// Marshal encodes err as a frame. It cannot fail for the types involved, so a
+// marshaling error is only logged.
+func Marshal(err error, requestID string) []byte {
+	frameBytes, marshalErr := json.Marshal(NewFrame(err, requestID))
+	if marshalErr != nil {
+		log.Printf("Error marshaling error frame: %v\n", marshalErr)
+	}
+	return frameBytes
+}
+
+// WriteHTTP writes err as a JSON error frame with the status matching its code.
+func WriteHTTP(w http.ResponseWriter, err error) {
+	appErr := From(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(appErr.Code.HTTPStatus())
+	w.Write(Marshal(appErr, ""))
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/auth"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"github.com/masjids-io/limestone-chat/internal/imaging"
+	"gorm.io/gorm"
+)
+
+// BlobStore stores attachment contents under opaque keys. The local disk
+// implementation lives in infrastructure/storage; an S3-compatible store only
+// needs to satisfy the same interface.
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+type AttachmentService interface {
+	Upload(uploaderID uuid.UUID, conversationID uuid.UUID, fileName string, r io.Reader) (*domain.Attachment, error)
+	GetAttachment(attachmentID uuid.UUID) (*domain.Attachment, error)
+	GetParticipantAttachment(attachmentID uuid.UUID, userID uuid.UUID) (*domain.Attachment, error)
+	Open(attachment *domain.Attachment, variant domain.AttachmentVariant) (io.ReadCloser, error)
+	SignDownloadURL(attachment *domain.Attachment, userID uuid.UUID, variant domain.AttachmentVariant) (*SignedURL, error)
+	AuthorizeDownload(attachmentID uuid.UUID, userID uuid.UUID, variant domain.AttachmentVariant, expires string, signature string) (*domain.Attachment, error)
+}
+
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type attachmentService struct {
+	db       *gorm.DB
+	store    BlobStore
+	signer   *auth.URLSigner
+	maxBytes int64
+}
+
+func NewAttachmentService(db *gorm.DB, store BlobStore, signer *auth.URLSigner, maxBytes int64) AttachmentService {
+	return &attachmentService{db: db, store: store, signer: signer, maxBytes: maxBytes}
+}
+
+func (s *attachmentService) Upload(uploaderID uuid.UUID, conversationID uuid.UUID, fileName string, r io.Reader) (*domain.Attachment, error) {
+	if _, err := requireParticipant(s.db, conversationID, uploaderID); err != nil {
+		return nil, err
+	}
+
+	attachment := domain.Attachment{
+		ID:             uuid.New(),
+		ConversationID: conversationID,
+		UploaderID:     uploaderID,
+		FileName:       sanitizeFileName(fileName),
+		CreatedAt:      now(),
+	}
+	attachment.StorageKey = fmt.Sprintf("%s/%s", conversationID.String(), attachment.ID.String())
+
+	// The MIME type is sniffed from the content rather than trusted from the
+	// client, so a renamed executable cannot pose as an image.
+	buffered := bufio.NewReaderSize(io.LimitReader(r, s.maxBytes+1), 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, apperror.Wrap(apperror.CodeInvalidRequest, "Failed to read upload", err)
+	}
+	if len(head) == 0 {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Uploaded file is empty")
+	}
+	attachment.MimeType = http.DetectContentType(head)
+	if strings.HasPrefix(attachment.MimeType, "image/") && !imaging.IsSupported(attachment.MimeType) {
+		// Images are only stored once their EXIF and GPS data is stripped,
+		// which is only possible for the types imaging can re-encode.
+		return nil, apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("Images of type %s are not supported, upload a JPEG, PNG or GIF", attachment.MimeType))
+	}
+
+	var content io.Reader = buffered
+	if imaging.IsSupported(attachment.MimeType) {
+		processed, err := s.processImage(&attachment, buffered)
+		if err != nil {
+			return nil, err
+		}
+		content = bytes.NewReader(processed)
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := s.store.Put(attachment.StorageKey, io.TeeReader(content, io.MultiWriter(hash, counter))); err != nil {
+		s.deleteBlob(attachment.ThumbnailKey)
+		return nil, apperror.Internal("Failed to store attachment", err)
+	}
+	if counter.n > s.maxBytes {
+		s.deleteBlobs(&attachment)
+		return nil, apperror.New(apperror.CodeTooLarge, fmt.Sprintf("Attachments are limited to %d bytes", s.maxBytes))
+	}
+	attachment.Size = counter.n
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if err := s.db.Create(&attachment).Error; err != nil {
+		s.deleteBlobs(&attachment)
+		return nil, apperror.Internal("Failed to save attachment", err)
+	}
+
+	log.Printf("Attachment %s uploaded by %s to conversation %s (%d bytes, %s)\n", attachment.ID, uploaderID, conversationID, attachment.Size, attachment.MimeType)
+	return &attachment, nil
+}
+
+// processImage strips metadata from an uploaded image, stores its thumbnail and
+// records the dimensions and blurred preview on the attachment. It returns the
+// cleaned image, which is what gets stored instead of the upload.
+func (s *attachmentService) processImage(attachment *domain.Attachment, r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, apperror.Wrap(apperror.CodeInvalidRequest, "Failed to read upload", err)
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, apperror.New(apperror.CodeTooLarge, fmt.Sprintf("Attachments are limited to %d bytes", s.maxBytes))
+	}
+
+	processed, err := imaging.Process(data, attachment.MimeType)
+	if err != nil {
+		return nil, apperror.Wrap(apperror.CodeInvalidRequest, "Image could not be processed", err)
+	}
+
+	thumbnailKey := attachment.StorageKey + ".thumb.jpg"
+	if err := s.store.Put(thumbnailKey, bytes.NewReader(processed.Thumbnail)); err != nil {
+		return nil, apperror.Internal("Failed to store thumbnail", err)
+	}
+	attachment.ThumbnailKey = thumbnailKey
+	attachment.Width = processed.Width
+	attachment.Height = processed.Height
+	attachment.Preview = processed.Preview
+	return processed.Image, nil
+}
+
+func (s *attachmentService) GetAttachment(attachmentID uuid.UUID) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	if err := s.db.First(&attachment, "id = ?", attachmentID).Error; err != nil {
+		return nil, notFoundOrInternal("Attachment not found", err)
+	}
+	return &attachment, nil
+}
+
+// GetParticipantAttachment returns the attachment if userID is a participant
+// of the conversation it was uploaded to.
+func (s *attachmentService) GetParticipantAttachment(attachmentID uuid.UUID, userID uuid.UUID) (*domain.Attachment, error) {
+	attachment, err := s.GetAttachment(attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireParticipant(s.db, attachment.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *attachmentService) Open(attachment *domain.Attachment, variant domain.AttachmentVariant) (io.ReadCloser, error) {
+	key := attachment.StorageKey
+	if variant == domain.AttachmentVariantThumbnail {
+		if !attachment.HasThumbnail() {
+			return nil, apperror.New(apperror.CodeNotFound, "Attachment has no thumbnail")
+		}
+		key = attachment.ThumbnailKey
+	}
+	rc, err := s.store.Open(key)
+	if err != nil {
+		return nil, apperror.Internal("Failed to open attachment", err)
+	}
+	return rc, nil
+}
+
+// SignDownloadURL returns a download URL only usable by userID. The caller is
+// responsible for checking that userID may see the attachment.
+func (s *attachmentService) SignDownloadURL(attachment *domain.Attachment, userID uuid.UUID, variant domain.AttachmentVariant) (*SignedURL, error) {
+	if variant == domain.AttachmentVariantThumbnail && !attachment.HasThumbnail() {
+		return nil, apperror.New(apperror.CodeNotFound, "Attachment has no thumbnail")
+	}
+
+	expiresAt, signature := s.signer.Sign(signedResource(attachment.ID, variant), userID)
+	query := url.Values{}
+	query.Set("user_id", userID.String())
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", signature)
+	if variant != domain.AttachmentVariantOriginal {
+		query.Set("variant", string(variant))
+	}
+	return &SignedURL{
+		URL:       fmt.Sprintf("/attachments/%s/download?%s", attachment.ID.String(), query.Encode()),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// AuthorizeDownload checks the signature and re-checks participation, so a
+// user removed from the conversation cannot use a URL issued earlier.
+func (s *attachmentService) AuthorizeDownload(attachmentID uuid.UUID, userID uuid.UUID, variant domain.AttachmentVariant, expires string, signature string) (*domain.Attachment, error) {
+	if err := s.signer.Verify(signedResource(attachmentID, variant), userID, expires, signature); err != nil {
+		return nil, apperror.Wrap(apperror.CodeForbidden, "Download link is invalid or has expired", err)
+	}
+	return s.GetParticipantAttachment(attachmentID, userID)
+}
+
+// signedResource includes the variant in the signature so a thumbnail URL
+// cannot be turned into a URL for the full-size original.
+func signedResource(attachmentID uuid.UUID, variant domain.AttachmentVariant) string {
+	if variant == domain.AttachmentVariantOriginal {
+		return attachmentID.String()
+	}
+	return attachmentID.String() + "/" + string(variant)
+}
+
+func (s *attachmentService) deleteBlobs(attachment *domain.Attachment) {
+	s.deleteBlob(attachment.StorageKey)
+	s.deleteBlob(attachment.ThumbnailKey)
+}
+
+func (s *attachmentService) deleteBlob(key string) {
+	if key == "" {
+		return
+	}
+	if err := s.store.Delete(key); err != nil {
+		log.Printf("Warning: Failed to delete orphaned attachment blob %s: %v", key, err)
+	}
+}
+
+func sanitizeFileName(fileName string) string {
+	name := filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[len(name)-255:], "")
+	}
+	return name
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlockService interface {
+	Block(blockerID uuid.UUID, blockedID uuid.UUID) error
+	Unblock(blockerID uuid.UUID, blockedID uuid.UUID) error
+	ListBlocked(blockerID uuid.UUID) ([]domain.UserBlock, error)
+}
+
+type blockService struct {
+	db *gorm.DB
+}
+
+func NewBlockService(db *gorm.DB) BlockService {
+	return &blockService{db: db}
+}
+
+func (s *blockService) Block(blockerID uuid.UUID, blockedID uuid.UUID) error {
+	if blockerID == blockedID {
+		return apperror.New(apperror.CodeInvalidRequest, "You cannot block yourself")
+	}
+	block := domain.UserBlock{BlockerID: blockerID, BlockedID: blockedID, CreatedAt: now()}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+		return apperror.Internal("Failed to block user", err)
+	}
+	log.Printf("User %s blocked user %s\n", blockerID, blockedID)
+	return nil
+}
+
+func (s *blockService) Unblock(blockerID uuid.UUID, blockedID uuid.UUID) error {
+	if err := s.db.Delete(&domain.UserBlock{}, "blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Error; err != nil {
+		return apperror.Internal("Failed to unblock user", err)
+	}
+	log.Printf("User %s unblocked user %s\n", blockerID, blockedID)
+	return nil
+}
+
+func (s *blockService) ListBlocked(blockerID uuid.UUID) ([]domain.UserBlock, error) {
+	var blocks []domain.UserBlock
+	if err := s.db.Where("blocker_id = ?", blockerID).Order("created_at DESC").Find(&blocks).Error; err != nil {
+		return nil, apperror.Internal("Failed to list blocked users", err)
+	}
+	return blocks, nil
+}
+
+func hasBlocked(db *gorm.DB, blockerID uuid.UUID, blockedID uuid.UUID) (bool, error) {
+	var block domain.UserBlock
+	err := db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).First(&block).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, apperror.Internal("Failed to check blocks", err)
+	}
+	return true, nil
+}
+
+// blockRelations returns every user who blocked userID or was blocked by them.
+func blockRelations(db *gorm.DB, userID uuid.UUID) (map[uuid.UUID]bool, error) {
+	var blocks []domain.UserBlock
+	if err := db.Where("blocker_id = ? OR blocked_id = ?", userID, userID).Find(&blocks).Error; err != nil {
+		return nil, apperror.Internal("Failed to check blocks", err)
+	}
+	related := make(map[uuid.UUID]bool, len(blocks))
+	for _, block := range blocks {
+		if block.BlockerID == userID {
+			related[block.BlockedID] = true
+		} else {
+			related[block.BlockerID] = true
+		}
+	}
+	return related, nil
+}
+
+// ensureNotBlockedInPrivateChat rejects a message into a private conversation
+// when the sender and the other participant have blocked each other in either
+// direction. Observers, such as guardians, and those who left do not count.
+func ensureNotBlockedInPrivateChat(db *gorm.DB, conversation *domain.Conversation, senderID uuid.UUID) error {
+	if conversation.Type != domain.ConversationTypePrivate {
+		return nil
+	}
+	related, err := blockRelations(db, senderID)
+	if err != nil || len(related) == 0 {
+		return err
+	}
+
+	var participants []domain.ConversationParticipant
+	if err := db.Where("conversation_id = ? AND user_id <> ? AND role <> ? AND left_at IS NULL", conversation.ID, senderID, domain.ParticipantRoleObserver).
+		Find(&participants).Error; err != nil {
+		return apperror.Internal("Failed to load participants", err)
+	}
+	for _, participant := range participants {
+		if !related[participant.UserID] {
+			continue
+		}
+		blockedBySender, err := hasBlocked(db, senderID, participant.UserID)
+		if err != nil {
+			return err
+		}
+		if blockedBySender {
+			return apperror.New(apperror.CodeForbidden, "You have blocked this user, unblock them to send messages")
+		}
+		return apperror.New(apperror.CodeForbidden, "You cannot send messages to this user")
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// Embedded so calendar time zones load on hosts without zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxClosedDays bounds the search for the next opening, so a calendar with
+// no hours at all does not loop forever.
+const maxClosedDays = 400
+
+// TimeRange is an opening period within a day, in minutes since midnight.
+type TimeRange struct {
+	Start int
+	End   int
+}
+
+// WeeklyHours lists the opening periods of each weekday. Days without an
+// entry are closed.
+type WeeklyHours map[time.Weekday][]TimeRange
+
+// RamadanSchedule replaces the regular hours between two dates, inclusive.
+type RamadanSchedule struct {
+	From  time.Time
+	To    time.Time
+	Hours WeeklyHours
+}
+
+// BusinessCalendar says when staff answer conversations of one purpose.
+// Dates and hours are in Location.
+type BusinessCalendar struct {
+	Location *time.Location
+	Hours    WeeklyHours
+	Holidays map[string]bool
+	Ramadan  []RamadanSchedule
+}
+
+// BusinessHours holds the calendar of each purpose. Purposes without one are
+// always open.
+type BusinessHours map[domain.ConversationPurpose]*BusinessCalendar
+
+// hoursOn returns the opening periods of a date, which must be midnight in
+// the calendar's location.
+func (c *BusinessCalendar) hoursOn(date time.Time) []TimeRange {
+	if c.Holidays[date.Format(time.DateOnly)] {
+		return nil
+	}
+	for _, schedule := range c.Ramadan {
+		if !date.Before(schedule.From) && !date.After(schedule.To) {
+			return schedule.Hours[date.Weekday()]
+		}
+	}
+	return c.Hours[date.Weekday()]
+}
+
+// NextOpening returns t if the calendar is open at t, or else the next time
+// it opens. ok is false when it does not open within maxClosedDays.
+func (c *BusinessCalendar) NextOpening(t time.Time) (opening time.Time, ok bool) {
+	local := t.In(c.Location)
+	year, month, day := local.Date()
+	for offset := 0; offset <= maxClosedDays; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, c.Location)
+		for _, r := range c.hoursOn(date) {
+			start := time.Date(date.Year(), date.Month(), date.Day(), r.Start/60, r.Start%60, 0, 0, c.Location)
+			end := time.Date(date.Year(), date.Month(), date.Day(), r.End/60, r.End%60, 0, 0, c.Location)
+			if !local.Before(start) && local.Before(end) {
+				return t, true
+			}
+			if start.After(local) {
+				return start, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// IsOpen reports whether the calendar is open at t.
+func (c *BusinessCalendar) IsOpen(t time.Time) bool {
+	opening, ok := c.NextOpening(t)
+	return ok && opening.Equal(t)
+}
+
+// businessCalendarConfig is the JSON form of a calendar, e.g.
+//
+//	{
+//	  "timezone": "Asia/Jakarta",
+//	  "hours": {"monday": ["09:00-12:00", "13:00-17:00"], "friday": ["09:00-11:30"]},
+//	  "holidays": ["2026-03-20"],
+//	  "ramadan": [{"from": "2026-02-18", "to": "2026-03-19", "hours": {"monday": ["10:00-15:00"]}}]
+//	}
+type businessCalendarConfig struct {
+	Timezone string              `json:"timezone"`
+	Hours    map[string][]string `json:"hours"`
+	Holidays []string            `json:"holidays"`
+	Ramadan  []struct {
+		From  string              `json:"from"`
+		To    string              `json:"to"`
+		Hours map[string][]string `json:"hours"`
+	} `json:"ramadan"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// LoadBusinessHours reads calendars from a JSON file keyed by purpose. A
+// missing file means every purpose is always open.
+func LoadBusinessHours(path string) (BusinessHours, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return BusinessHours{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read business hours %s: %w", path, err)
+	}
+	hours, err := ParseBusinessHours(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business hours in %s: %w", path, err)
+	}
+	return hours, nil
+}
+
+// ParseBusinessHours parses calendars keyed by purpose.
+func ParseBusinessHours(data []byte) (BusinessHours, error) {
+	var configs map[domain.ConversationPurpose]businessCalendarConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+	hours := make(BusinessHours, len(configs))
+	for purpose, config := range configs {
+		if !purpose.IsValid() {
+			return nil, fmt.Errorf("unknown purpose %q", purpose)
+		}
+		calendar, err := config.calendar()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", purpose, err)
+		}
+		hours[purpose] = calendar
+	}
+	return hours, nil
+}
+
+func (config businessCalendarConfig) calendar() (*BusinessCalendar, error) {
+	if config.Timezone == "" {
+		return nil, errors.New("timezone is required")
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	calendar := &BusinessCalendar{Location: location, Holidays: make(map[string]bool)}
+	if calendar.Hours, err = parseWeeklyHours(config.Hours); err != nil {
+		return nil, err
+	}
+	for _, holiday := range config.Holidays {
+		date, err := time.Parse(time.DateOnly, holiday)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q", holiday)
+		}
+		calendar.Holidays[date.Format(time.DateOnly)] = true
+	}
+	for _, period := range config.Ramadan {
+		from, err := time.ParseInLocation(time.DateOnly, period.From, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ramadan start %q", period.From)
+		}
+		to, err := time.ParseInLocation(time.DateOnly, period.To, location)
+		if err != nil || to.Before(from) {
+			return nil, fmt.Errorf("invalid ramadan end %q", period.To)
+		}
+		weekly, err := parseWeeklyHours(period.Hours)
+		if err != nil {
+			return nil, err
+		}
+		calendar.Ramadan = append(calendar.Ramadan, RamadanSchedule{From: from, To: to, Hours: weekly})
+	}
+	return calendar, nil
+}
+
+func parseWeeklyHours(config map[string][]string) (WeeklyHours, error) {
+	weekly := make(WeeklyHours, len(config))
+	for name, ranges := range config {
+		weekday, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+		for _, value := range ranges {
+			r, err := parseTimeRange(value)
+			if err != nil {
+				return nil, err
+			}
+			weekly[weekday] = append(weekly[weekday], r)
+		}
+		sort.Slice(weekly[weekday], func(i, j int) bool { return weekly[weekday][i].Start < weekly[weekday][j].Start })
+	}
+	return weekly, nil
+}
+
+// parseTimeRange parses "09:00-17:00". Ranges cannot pass midnight; "24:00"
+// ends a range at the end of the day.
+func parseTimeRange(value string) (TimeRange, error) {
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return TimeRange{}, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", value)
+	}
+	startMinutes, err := parseClock(start)
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("invalid hours %q: %w", value, err)
+	}
+	endMinutes, err := parseClock(end)
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("invalid hours %q: %w", value, err)
+	}
+	if startMinutes >= endMinutes {
+		return TimeRange{}, fmt.Errorf("invalid hours %q, start must be before end", value)
+	}
+	return TimeRange{Start: startMinutes, End: endMinutes}, nil
+}
+
+func parseClock(value string) (int, error) {
+	hour, minute, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	h, err := strconv.Atoi(hour)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	m, err := strconv.Atoi(minute)
+	if err != nil || h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m > 0 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return h*60 + m, nil
+}
+
+// OutOfHoursMessage is the auto-reply for a message sent while closed.
+func OutOfHoursMessage(opening time.Time, ok bool) string {
+	if !ok {
+		return "Our team is currently away. We will reply as soon as we are back."
+	}
+	return fmt.Sprintf("Our team is currently away. We will be back on %s at %s (%s time) and will reply to you then.",
+		opening.Format("Monday 2 January"), opening.Format("15:04"), opening.Location())
+}
+
+// outOfHoursSuppression is how long to stay quiet after an auto-reply when
+// the calendar has no upcoming opening.
+const outOfHoursSuppression = 24 * time.Hour
+
+// AutoReplyOutOfHours posts a system message with the expected response time
+// when a non-staff user writes while the conversation's purpose is closed. It
+// is posted once until the team is back, however many messages follow.
+func (s *chatService) AutoReplyOutOfHours(message *domain.Message) error {
+	var conversation domain.Conversation
+	if err := s.db.First(&conversation, "id = ?", message.ConversationID).Error; err != nil {
+		return notFoundOrInternal("Conversation not found", err)
+	}
+	calendar := s.businessHours[conversation.Purpose]
+	if calendar == nil {
+		return nil
+	}
+	opening, ok := calendar.NextOpening(message.CreatedAt)
+	if ok && opening.Equal(message.CreatedAt) {
+		return nil
+	}
+	staff, err := isStaff(s.db, message.SenderID)
+	if err != nil || staff {
+		return err
+	}
+
+	until := message.CreatedAt.Add(outOfHoursSuppression)
+	if ok {
+		until = opening
+	}
+	content := OutOfHoursMessage(opening, ok)
+	var reply *domain.Message
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		record := domain.OutOfHoursReply{ConversationID: conversation.ID, SuppressedUntil: until, SentAt: now()}
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "conversation_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"suppressed_until", "sent_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Lte{Column: clause.Column{Table: "out_of_hours_replies", Name: "suppressed_until"}, Value: message.CreatedAt}}},
+		}).Create(&record)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		var err error
+		reply, err = createSystemMessage(tx, conversation.ID, message.SenderID, content)
+		return err
+	})
+	if err != nil {
+		return apperror.Internal("Failed to post out-of-hours reply", err)
+	}
+	if reply == nil {
+		return nil
+	}
+	log.Printf("Out-of-hours reply posted in conversation %s until %s\n", conversation.ID, until.Format(time.RFC3339))
+	s.notifier.BroadcastMessage(reply)
+	return nil
+}
@@ -1,34 +1,121 @@
 package services
 
 import (
-	"fmt"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
 	"github.com/masjids-io/limestone-chat/internal/domain"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type ChatService interface {
-	SendMessage(senderID uuid.UUID, conversationID uuid.UUID, content string, messageType string, mediaURL string, metadata []byte, replyToMessageID *uuid.UUID) (*domain.Message, error)
+	SendMessage(senderID uuid.UUID, conversationID uuid.UUID, content string, messageType string, mediaURL string, metadata []byte, replyToMessageID *uuid.UUID, attachmentID *uuid.UUID) (*domain.Message, error)
 	GetMessagesByConversation(conversationID uuid.UUID, limit, offset int) ([]domain.Message, error)
+	GetConversationHistory(userID uuid.UUID, conversationID uuid.UUID, limit, offset int) ([]domain.Message, error)
 	MarkMessageAsRead(messageID uuid.UUID, readerID uuid.UUID) error
+	EnsureCanConnect(userID uuid.UUID) error
+	HasBlocked(blockerID uuid.UUID, blockedID uuid.UUID) (bool, error)
+	GuardianObservers(conversationID uuid.UUID, members ...uuid.UUID) ([]domain.ConversationParticipant, error)
+	GetParticipantConversation(userID uuid.UUID, conversationID uuid.UUID) (*domain.Conversation, error)
+	EvaluateConversationPolicy(userID uuid.UUID, partnerID uuid.UUID, purpose domain.ConversationPurpose) error
+	ChangeStatus(actorID uuid.UUID, conversationID uuid.UUID, status domain.ConversationStatus) (*domain.Conversation, error)
+	ListConversations(userID uuid.UUID, status domain.ConversationStatus, limit, offset int) ([]domain.Conversation, error)
+	ExpandSavedReply(agentID uuid.UUID, conversationID uuid.UUID, replyID uuid.UUID) (string, error)
+	SubmitSurveyResponse(userID uuid.UUID, conversationID uuid.UUID, surveyID uuid.UUID, rating int, comment string) (*domain.SatisfactionSurvey, error)
+	AutoReplyOutOfHours(message *domain.Message) error
+	// ListMentions returns messages mentioning the user, newest first.
+	ListMentions(userID uuid.UUID, limit, offset int) ([]domain.Message, error)
+	// GetThreadRoot returns the root of the thread a message starts or
+	// belongs to.
+	GetThreadRoot(userID uuid.UUID, messageID uuid.UUID) (*domain.Message, error)
+	// GetThread returns a thread's root and its replies, oldest first.
+	GetThread(userID uuid.UUID, messageID uuid.UUID, limit, offset int) (*domain.Message, []domain.Message, error)
 }
 
 type chatService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	messageTypes  *MessageTypeRegistry
+	moderation    *ModerationPipeline
+	policy        *ConversationPolicy
+	businessHours BusinessHours
+	tickets       TicketQueue
+	notifier      LiveNotifier
 }
 
-func NewChatService(db *gorm.DB) ChatService {
-	return &chatService{db: db}
+func NewChatService(db *gorm.DB, messageTypes *MessageTypeRegistry, moderation *ModerationPipeline, policy *ConversationPolicy, businessHours BusinessHours, tickets TicketQueue, notifier LiveNotifier) ChatService {
+	return &chatService{db: db, messageTypes: messageTypes, moderation: moderation, policy: policy, businessHours: businessHours, tickets: tickets, notifier: notifier}
 }
 
-func (s *chatService) SendMessage(senderID uuid.UUID, conversationID uuid.UUID, content string, messageType string, mediaURL string, metadata []byte, replyToMessageID *uuid.UUID) (*domain.Message, error) {
+func (s *chatService) SendMessage(senderID uuid.UUID, conversationID uuid.UUID, content string, messageType string, mediaURL string, metadata []byte, replyToMessageID *uuid.UUID, attachmentID *uuid.UUID) (*domain.Message, error) {
+	var attachment *domain.Attachment
+	if attachmentID != nil {
+		var err error
+		if attachment, err = s.loadAttachment(*attachmentID, senderID, conversationID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.messageTypes.Validate(messageType, content, mediaURL, metadata, attachment); err != nil {
+		return nil, err
+	}
+	if err := ensureNotSanctioned(s.db, senderID, domain.SanctionTypeBan, domain.SanctionTypeMute); err != nil {
+		return nil, err
+	}
+
 	var conversation domain.Conversation
 	if err := s.db.First(&conversation, "id = ?", conversationID).Error; err != nil {
-		return nil, fmt.Errorf("conversation not found: %w", err)
+		return nil, notFoundOrInternal("Conversation not found", err)
+	}
+
+	if err := ensureCanWrite(s.db, conversationID, senderID); err != nil {
+		return nil, err
+	}
+	if err := ensureNotBlockedInPrivateChat(s.db, &conversation, senderID); err != nil {
+		return nil, err
+	}
+	if err := ensureApproved(s.db, &conversation); err != nil {
+		return nil, err
+	}
+	var threadRoot *domain.Message
+	if replyToMessageID != nil {
+		var err error
+		if threadRoot, err = threadRootOf(s.db, conversationID, *replyToMessageID); err != nil {
+			return nil, err
+		}
+	}
+	// Validate has already rejected metadata that is not a JSON object.
+	var fields map[string]interface{}
+	if len(metadata) > 0 {
+		json.Unmarshal(metadata, &fields)
+	}
+	moderation := s.moderation.Run(ModerationInput{SenderID: senderID, Conversation: &conversation, Content: content, Metadata: fields})
+	if moderation.Action == domain.ModerationActionReject {
+		if err := s.db.Create(&moderation.Decisions).Error; err != nil {
+			log.Printf("Warning: Failed to record moderation decisions for rejected message from %s: %v", senderID, err)
+		}
+		reason := moderation.Decisions[len(moderation.Decisions)-1].Reason
+		return nil, apperror.New(apperror.CodeRejected, "Message was rejected: "+reason)
+	}
+	content = moderation.Content
+
+	var mentions []Mention
+	if conversation.Type == domain.ConversationTypeGroup {
+		var err error
+		if mentions, err = resolveMentions(s.db, conversationID, ParseMentions(content)); err != nil {
+			return nil, err
+		}
+	}
+	mentioned := mentionedUsers(mentions, senderID)
+	reopen, err := s.reopensOnActivity(&conversation, senderID)
+	if err != nil {
+		return nil, err
 	}
 
 	newMessage := domain.Message{
@@ -51,13 +138,79 @@ func (s *chatService) SendMessage(senderID uuid.UUID, conversationID uuid.UUID,
 	if replyToMessageID != nil {
 		newMessage.ReplyToMessageID.String = replyToMessageID.String()
 		newMessage.ReplyToMessageID.Valid = true
+		newMessage.ThreadRootID.String = threadRoot.ID.String()
+		newMessage.ThreadRootID.Valid = true
+	}
+	if attachment != nil {
+		newMessage.AttachmentID.String = attachment.ID.String()
+		newMessage.AttachmentID.Valid = true
+		newMessage.Attachment = attachment
+		if attachment.Width > 0 && attachment.Height > 0 {
+			withDimensions, err := withImageDimensions(newMessage.Metadata, attachment)
+			if err != nil {
+				return nil, err
+			}
+			newMessage.Metadata = withDimensions
+		}
 	}
+	withMentionList, err := withMentions(newMessage.Metadata, mentions)
+	if err != nil {
+		return nil, err
+	}
+	newMessage.Metadata = withMentionList
 
-	if err := s.db.Create(&newMessage).Error; err != nil {
-		return nil, fmt.Errorf("failed to save message: %w", err)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&newMessage).Error; err != nil {
+			return err
+		}
+		for i := range moderation.Decisions {
+			moderation.Decisions[i].MessageID.String = newMessage.ID.String()
+			moderation.Decisions[i].MessageID.Valid = true
+		}
+		if len(moderation.Decisions) > 0 {
+			if err := tx.Create(&moderation.Decisions).Error; err != nil {
+				return err
+			}
+		}
+		if moderation.Action == domain.ModerationActionFlag {
+			if err := tx.Create(flaggedMessageReport(&newMessage, moderation.Decisions)).Error; err != nil {
+				return err
+			}
+		}
+		if threadRoot != nil {
+			if err := addThreadReply(tx, threadRoot, &newMessage); err != nil {
+				return err
+			}
+		}
+		if err := createMentions(tx, &newMessage, mentioned); err != nil {
+			return err
+		}
+		return enqueueMessageNotifications(tx, &newMessage, mentioned)
+	})
+	if err != nil {
+		return nil, apperror.Internal("Failed to save message", err)
 	}
 
+	newMessage.ThreadRoot = threadRoot
 	s.db.Model(&conversation).Update("last_message_id", newMessage.ID.String())
+	// The conversation reopens only once the message is stored.
+	if reopen {
+		if err := s.transitionStatus(&conversation, senderID, domain.ConversationStatusOpen); err != nil {
+			log.Printf("Warning: Failed to reopen conversation %s: %v", conversationID, err)
+		}
+	}
+	if conversation.Purpose.IsSupport() {
+		if err := recordFirstResponse(s.db, &newMessage); err != nil {
+			log.Printf("Warning: Failed to record first response in conversation %s: %v", conversationID, err)
+		}
+	}
+	for userID := range mentioned {
+		s.notifier.NotifyUser(userID, domain.Event{
+			Type:           domain.EventMentioned,
+			ConversationID: conversationID,
+			Data:           map[string]interface{}{"message_id": newMessage.ID.String(), "sender_id": senderID.String()},
+		})
+	}
 
 	log.Printf("Message sent: %v\n", newMessage.ID)
 	return &newMessage, nil
@@ -66,21 +219,135 @@ func (s *chatService) SendMessage(senderID uuid.UUID, conversationID uuid.UUID,
 func (s *chatService) GetMessagesByConversation(conversationID uuid.UUID, limit, offset int) ([]domain.Message, error) {
 	var messages []domain.Message
 	if err := s.db.Where("conversation_id = ?", conversationID).Limit(limit).Offset(offset).Order("created_at ASC").Find(&messages).Error; err != nil {
-		return nil, fmt.Errorf("failed to get messages: %w", err)
+		return nil, apperror.Internal("Failed to get messages", err)
 	}
 	return messages, nil
 }
 
+// GetConversationHistory returns messages for a participant with their
+// attachments loaded, so image thumbnails can be listed without fetching the
+// full files.
+func (s *chatService) GetConversationHistory(userID uuid.UUID, conversationID uuid.UUID, limit, offset int) ([]domain.Message, error) {
+	if _, err := requireParticipant(s.db, conversationID, userID); err != nil {
+		return nil, err
+	}
+	messages, err := s.GetMessagesByConversation(conversationID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachmentIDs []string
+	for _, message := range messages {
+		if message.AttachmentID.Valid {
+			attachmentIDs = append(attachmentIDs, message.AttachmentID.String)
+		}
+	}
+	if len(attachmentIDs) == 0 {
+		return messages, nil
+	}
+
+	var attachments []domain.Attachment
+	if err := s.db.Where("id IN ?", attachmentIDs).Find(&attachments).Error; err != nil {
+		return nil, apperror.Internal("Failed to get attachments", err)
+	}
+	byID := make(map[string]*domain.Attachment, len(attachments))
+	for i := range attachments {
+		byID[attachments[i].ID.String()] = &attachments[i]
+	}
+	for i := range messages {
+		if messages[i].AttachmentID.Valid {
+			messages[i].Attachment = byID[messages[i].AttachmentID.String]
+		}
+	}
+	return messages, nil
+}
+
+// EnsureCanConnect rejects banned users before a WebSocket is opened.
+func (s *chatService) EnsureCanConnect(userID uuid.UUID) error {
+	return ensureNotSanctioned(s.db, userID, domain.SanctionTypeBan)
+}
+
+func (s *chatService) HasBlocked(blockerID uuid.UUID, blockedID uuid.UUID) (bool, error) {
+	return hasBlocked(s.db, blockerID, blockedID)
+}
+
+// GetParticipantConversation returns a conversation the user currently takes
+// part in, as a member or an observer. A member cannot rejoin a private
+// conversation with a partner who blocked them.
+func (s *chatService) GetParticipantConversation(userID uuid.UUID, conversationID uuid.UUID) (*domain.Conversation, error) {
+	participant, err := requireParticipant(s.db, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var conversation domain.Conversation
+	if err := s.db.First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return nil, notFoundOrInternal("Conversation not found", err)
+	}
+	if participant.Role == domain.ParticipantRoleObserver || conversation.Type != domain.ConversationTypePrivate {
+		return &conversation, nil
+	}
+
+	var partners []domain.ConversationParticipant
+	if err := s.db.Where("conversation_id = ? AND user_id <> ? AND role <> ?", conversationID, userID, domain.ParticipantRoleObserver).
+		Find(&partners).Error; err != nil {
+		return nil, apperror.Internal("Failed to load participants", err)
+	}
+	for _, partner := range partners {
+		if err := s.EvaluateConversationPolicy(userID, partner.UserID, conversation.Purpose); err != nil {
+			return nil, err
+		}
+		blocked, err := hasBlocked(s.db, partner.UserID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, apperror.New(apperror.CodeForbidden, "You cannot chat with this user")
+		}
+	}
+	return &conversation, nil
+}
+
+// EvaluateConversationPolicy checks the rules of purpose for a private
+// conversation between userID and partnerID.
+func (s *chatService) EvaluateConversationPolicy(userID uuid.UUID, partnerID uuid.UUID, purpose domain.ConversationPurpose) error {
+	if len(s.policy.Rules(purpose)) == 0 {
+		return nil
+	}
+
+	input := PolicyInput{Purpose: purpose}
+	if err := s.db.First(&input.User, "id = ?", userID).Error; err != nil {
+		return notFoundOrInternal("Your user profile was not found", err)
+	}
+	if err := s.db.First(&input.Partner, "id = ?", partnerID).Error; err != nil {
+		return notFoundOrInternal("Partner not found", err)
+	}
+	var match domain.Match
+	err := s.db.Where("purpose = ? AND status = ?", purpose, domain.MatchStatusApproved).
+		Where("(user_id = ? AND partner_id = ?) OR (user_id = ? AND partner_id = ?)", userID, partnerID, partnerID, userID).
+		First(&match).Error
+	if err == nil {
+		input.Match = &match
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.Internal("Failed to load match", err)
+	}
+	return s.policy.Evaluate(input)
+}
+
+// GuardianObservers returns the guardians to add as observers to a new
+// private nikkah conversation between members.
+func (s *chatService) GuardianObservers(conversationID uuid.UUID, members ...uuid.UUID) ([]domain.ConversationParticipant, error) {
+	return guardianObservers(s.db, conversationID, members...)
+}
+
 func (s *chatService) MarkMessageAsRead(messageID uuid.UUID, readerID uuid.UUID) error {
 	var message domain.Message
 	if err := s.db.First(&message, "id = ?", messageID).Error; err != nil {
-		return fmt.Errorf("message not found: %w", err)
+		return notFoundOrInternal("Message not found", err)
 	}
 
-	var participant domain.ConversationParticipant
-	err := s.db.Where("conversation_id = ? AND user_id = ?", message.ConversationID, readerID).First(&participant).Error
+	participant, err := requireParticipant(s.db, message.ConversationID, readerID)
 	if err != nil {
-		return fmt.Errorf("reader %s is not a participant of conversation %s: %w", readerID, message.ConversationID, err)
+		return err
 	}
 
 	messageRead := domain.MessageRead{
@@ -92,7 +359,7 @@ func (s *chatService) MarkMessageAsRead(messageID uuid.UUID, readerID uuid.UUID)
 		Columns:   []clause.Column{{Name: "message_id"}, {Name: "reader_id"}},
 		DoUpdates: clause.Assignments(map[string]interface{}{"read_at": now()}),
 	}).Create(&messageRead).Error; err != nil {
-		return fmt.Errorf("failed to mark message as read: %w", err)
+		return apperror.Internal("Failed to mark message as read", err)
 	}
 
 	participant.LastReadMessageID.String = messageID.String()
@@ -104,6 +371,102 @@ func (s *chatService) MarkMessageAsRead(messageID uuid.UUID, readerID uuid.UUID)
 	return nil
 }
 
+// flaggedMessageReport queues a message flagged by the moderation pipeline for
+// moderator review, as if it had been reported.
+func flaggedMessageReport(message *domain.Message, decisions []domain.ModerationDecision) *domain.Report {
+	var reasons []string
+	for _, decision := range decisions {
+		if decision.Action == domain.ModerationActionFlag {
+			reasons = append(reasons, decision.Filter+": "+decision.Reason)
+		}
+	}
+	report := newReport(uuid.Nil, message.SenderID, message.ConversationID, domain.ReportReasonAutoFlagged, strings.Join(reasons, "; "))
+	report.MessageID = sql.NullString{String: message.ID.String(), Valid: true}
+	return &report
+}
+
+// loadAttachment returns an attachment the sender uploaded to the same
+// conversation, so a message cannot reference someone else's file.
+func (s *chatService) loadAttachment(attachmentID uuid.UUID, senderID uuid.UUID, conversationID uuid.UUID) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	if err := s.db.First(&attachment, "id = ?", attachmentID).Error; err != nil {
+		return nil, notFoundOrInternal("Attachment not found", err)
+	}
+	if attachment.ConversationID != conversationID || attachment.UploaderID != senderID {
+		return nil, apperror.New(apperror.CodeForbidden, "Attachment cannot be used in this conversation")
+	}
+	return &attachment, nil
+}
+
+// withImageDimensions records the dimensions measured at upload time in the
+// message metadata, overriding whatever the client claimed.
+func withImageDimensions(metadata []byte, attachment *domain.Attachment) ([]byte, error) {
+	fields := map[string]interface{}{}
+	if len(metadata) > 0 && string(metadata) != "null" {
+		if err := json.Unmarshal(metadata, &fields); err != nil {
+			return nil, apperror.Wrap(apperror.CodeInvalidRequest, "Metadata must be a JSON object", err)
+		}
+	}
+	fields["width"] = attachment.Width
+	fields["height"] = attachment.Height
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, apperror.Internal("Failed to encode metadata", err)
+	}
+	return merged, nil
+}
+
+// requireParticipant returns the user's participant record, or a forbidden
+// error when the user is not, or is no longer, part of the conversation.
+func requireParticipant(db *gorm.DB, conversationID uuid.UUID, userID uuid.UUID) (*domain.ConversationParticipant, error) {
+	var participant domain.ConversationParticipant
+	err := db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&participant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.Wrap(apperror.CodeForbidden, "You are not a participant of this conversation", err)
+		}
+		return nil, apperror.Internal("Failed to check participant", err)
+	}
+	if participant.LeftAt.Valid {
+		return nil, apperror.New(apperror.CodeForbidden, "You are no longer a participant of this conversation")
+	}
+	return &participant, nil
+}
+
+// systemActorID is the actor of changes the server makes on its own, such as
+// SLA escalations; their system messages have the nil UUID as sender.
+var systemActorID = uuid.Nil
+
+// createSystemMessage stores a server-generated message on behalf of actorID,
+// the user whose action it announces, or systemActorID.
+func createSystemMessage(db *gorm.DB, conversationID uuid.UUID, actorID uuid.UUID, content string) (*domain.Message, error) {
+	message := domain.Message{
+		ID:             uuid.New(),
+		ConversationID: conversationID,
+		SenderID:       actorID,
+		Content:        content,
+		MessageType:    string(domain.MessageTypeSystem),
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+	}
+	if err := db.Omit(clause.Associations).Create(&message).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&domain.Conversation{}).Where("id = ?", conversationID).UpdateColumn("last_message_id", message.ID.String()).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// notFoundOrInternal distinguishes a missing record, which the client can act
+// on, from a database failure, whose details must stay in the logs.
+func notFoundOrInternal(message string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.Wrap(apperror.CodeNotFound, message, err)
+	}
+	return apperror.Internal(message, err)
+}
+
 func now() time.Time {
 	return time.Now()
 }
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+// PolicyInput is what conversation rules see when a user creates or joins a
+// private conversation with a partner. Match is the approved match between
+// the two for the purpose, or nil.
+type PolicyInput struct {
+	Purpose domain.ConversationPurpose
+	User    domain.User
+	Partner domain.User
+	Match   *domain.Match
+}
+
+// ConversationRule decides whether two users may talk for a purpose. Rules
+// return a forbidden apperror whose message tells the user why.
+type ConversationRule interface {
+	Name() string
+	Evaluate(input PolicyInput) error
+}
+
+// ConversationPolicy holds the rules of every purpose. Purposes without rules
+// are unrestricted.
+type ConversationPolicy struct {
+	rules map[domain.ConversationPurpose][]ConversationRule
+}
+
+func NewConversationPolicy() *ConversationPolicy {
+	return &ConversationPolicy{rules: make(map[domain.ConversationPurpose][]ConversationRule)}
+}
+
+// Add appends rules to a purpose.
+func (p *ConversationPolicy) Add(purpose domain.ConversationPurpose, rules ...ConversationRule) {
+	p.rules[purpose] = append(p.rules[purpose], rules...)
+}
+
+// Rules returns the rules of a purpose. It is nil-safe.
+func (p *ConversationPolicy) Rules(purpose domain.ConversationPurpose) []ConversationRule {
+	if p == nil {
+		return nil
+	}
+	return p.rules[purpose]
+}
+
+// Evaluate returns the error of the first rule that rejects the input.
+func (p *ConversationPolicy) Evaluate(input PolicyInput) error {
+	for _, rule := range p.Rules(input.Purpose) {
+		if err := rule.Evaluate(input); err != nil {
+			log.Printf("Conversation between %s and %s for %s rejected by %s: %v\n", input.User.ID, input.Partner.ID, input.Purpose, rule.Name(), err)
+			return err
+		}
+	}
+	return nil
+}
+
+// conversationRules are the rules that can be named in configuration.
+var conversationRules = map[string]ConversationRule{
+	OppositeGenderRule{}.Name(): OppositeGenderRule{},
+	SameGenderRule{}.Name():     SameGenderRule{},
+	ApprovedMatchRule{}.Name():  ApprovedMatchRule{},
+}
+
+// defaultConversationRules require nikkah conversations to be between people
+// of opposite genders and revert mentorship to pair people of the same gender.
+// approved_match is left to deployments whose matchmaking writes the matches
+// table.
+var defaultConversationRules = map[domain.ConversationPurpose][]string{
+	domain.ConversationPurposeNikkah:        {"opposite_gender"},
+	domain.ConversationPurposeRevertService: {"same_gender"},
+}
+
+// LoadConversationPolicy builds the policy from the defaults, letting
+// CONVERSATION_POLICY_<PURPOSE> replace the rules of one purpose with a comma
+// separated list of rule names, e.g. CONVERSATION_POLICY_NIKKAH_SERVICE=
+// opposite_gender. Setting it to "none" lifts every rule.
+func LoadConversationPolicy() (*ConversationPolicy, error) {
+	policy := NewConversationPolicy()
+	for _, purpose := range domain.ConversationPurposes() {
+		names := defaultConversationRules[purpose]
+		key := "CONVERSATION_POLICY_" + strings.ToUpper(string(purpose))
+		if value, ok := os.LookupEnv(key); ok {
+			names = nil
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" && name != "none" {
+					names = append(names, name)
+				}
+			}
+		}
+		for _, name := range names {
+			rule, ok := conversationRules[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown conversation rule %q in %s", name, key)
+			}
+			policy.Add(purpose, rule)
+		}
+	}
+	return policy, nil
+}
+
+// requireGender rejects users whose profile has no recognised gender, since
+// gender rules cannot be evaluated for them.
+func requireGender(input PolicyInput) error {
+	if !input.User.GenderValue().IsValid() {
+		return apperror.New(apperror.CodeForbidden, "Your profile must specify your gender to use "+string(input.Purpose))
+	}
+	if !input.Partner.GenderValue().IsValid() {
+		return apperror.New(apperror.CodeForbidden, "Your partner's profile does not specify a gender, which "+string(input.Purpose)+" requires")
+	}
+	return nil
+}
+
+// OppositeGenderRule only allows conversations between a man and a woman.
+type OppositeGenderRule struct{}
+
+func (OppositeGenderRule) Name() string {
+	return "opposite_gender"
+}
+
+func (OppositeGenderRule) Evaluate(input PolicyInput) error {
+	if err := requireGender(input); err != nil {
+		return err
+	}
+	if input.User.GenderValue() == input.Partner.GenderValue() {
+		return apperror.New(apperror.CodeForbidden, string(input.Purpose)+" conversations must be between a man and a woman")
+	}
+	return nil
+}
+
+// SameGenderRule only allows conversations between people of the same gender.
+type SameGenderRule struct{}
+
+func (SameGenderRule) Name() string {
+	return "same_gender"
+}
+
+func (SameGenderRule) Evaluate(input PolicyInput) error {
+	if err := requireGender(input); err != nil {
+		return err
+	}
+	if input.User.GenderValue() != input.Partner.GenderValue() {
+		return apperror.New(apperror.CodeForbidden, string(input.Purpose)+" conversations must be between people of the same gender")
+	}
+	return nil
+}
+
+// ApprovedMatchRule requires an approved match between the two users.
+type ApprovedMatchRule struct{}
+
+func (ApprovedMatchRule) Name() string {
+	return "approved_match"
+}
+
+func (ApprovedMatchRule) Evaluate(input PolicyInput) error {
+	if input.Match == nil || input.Match.Status != domain.MatchStatusApproved {
+		return apperror.New(apperror.CodeForbidden, "You can only start a "+string(input.Purpose)+" conversation with an approved match")
+	}
+	return nil
+}
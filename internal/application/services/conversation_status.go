@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+)
+
+var statusChangeMessages = map[domain.ConversationStatus]string{
+	domain.ConversationStatusOpen:     "The conversation was reopened.",
+	domain.ConversationStatusPending:  "The conversation is waiting for a reply.",
+	domain.ConversationStatusResolved: "The conversation was marked as resolved.",
+	domain.ConversationStatusClosed:   "The conversation was closed.",
+}
+
+// CheckStatusChange decides whether an actor may move a conversation from one
+// status to another. Only staff can mark a conversation as pending, close a
+// support conversation or reopen a closed one; participants can do the rest.
+func CheckStatusChange(staff bool, purpose domain.ConversationPurpose, from, to domain.ConversationStatus) error {
+	if !to.IsValid() {
+		return apperror.New(apperror.CodeInvalidRequest, "Invalid conversation status")
+	}
+	if from == to {
+		return apperror.New(apperror.CodeConflict, "The conversation is already "+string(to))
+	}
+	if !from.CanTransitionTo(to) {
+		return apperror.New(apperror.CodeConflict, "A "+string(from)+" conversation cannot become "+string(to))
+	}
+	if staff {
+		return nil
+	}
+	switch {
+	case to == domain.ConversationStatusPending:
+		return apperror.New(apperror.CodeForbidden, "Only staff can mark a conversation as pending")
+	case to == domain.ConversationStatusClosed && purpose.IsSupport():
+		return apperror.New(apperror.CodeForbidden, "Only staff can close a support conversation")
+	case from == domain.ConversationStatusClosed:
+		return apperror.New(apperror.CodeForbidden, "Only staff can reopen a closed conversation")
+	}
+	return nil
+}
+
+// ChangeStatus moves a conversation to a new status on behalf of a participant,
+// or of a supervisor or moderator who is not part of it.
+func (s *chatService) ChangeStatus(actorID uuid.UUID, conversationID uuid.UUID, status domain.ConversationStatus) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	if err := s.db.First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return nil, notFoundOrInternal("Conversation not found", err)
+	}
+
+	participant, err := requireParticipant(s.db, conversationID, actorID)
+	if err != nil {
+		var appErr *apperror.Error
+		if !errors.As(err, &appErr) || appErr.Code != apperror.CodeForbidden {
+			return nil, err
+		}
+		if err := requireOversight(s.db, actorID); err != nil {
+			return nil, err
+		}
+	} else if participant.Role == domain.ParticipantRoleObserver {
+		return nil, apperror.New(apperror.CodeForbidden, "Observers cannot change the conversation status")
+	}
+
+	staff, err := isStaff(s.db, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckStatusChange(staff, conversation.Purpose, currentStatus(&conversation), status); err != nil {
+		return nil, err
+	}
+	if err := s.transitionStatus(&conversation, actorID, status); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// requireOversight allows supervisors and moderators to act on conversations
+// they do not take part in.
+func requireOversight(db *gorm.DB, userID uuid.UUID) error {
+	for _, role := range []domain.StaffRole{domain.StaffRoleSupervisor, domain.StaffRoleModerator} {
+		ok, err := hasStaffRole(db, userID, role)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return apperror.New(apperror.CodeForbidden, "You are not a participant of this conversation")
+}
+
+// reopensOnActivity reports whether a message from the sender reopens the
+// conversation: a pending or resolved one reopens when someone other than
+// staff writes in it. Closed conversations reject new messages.
+func (s *chatService) reopensOnActivity(conversation *domain.Conversation, senderID uuid.UUID) (bool, error) {
+	switch currentStatus(conversation) {
+	case domain.ConversationStatusClosed:
+		return false, apperror.New(apperror.CodeForbidden, "This conversation is closed")
+	case domain.ConversationStatusPending, domain.ConversationStatusResolved:
+		staff, err := isStaff(s.db, senderID)
+		if err != nil {
+			return false, err
+		}
+		return !staff, nil
+	}
+	return false, nil
+}
+
+// transitionStatus stores the new status with a system message announcing it,
+// keeps the support ticket in step and tells connected clients. Resolving a
+// support or revert conversation also sends a satisfaction survey.
+func (s *chatService) transitionStatus(conversation *domain.Conversation, actorID uuid.UUID, status domain.ConversationStatus) error {
+	previous := currentStatus(conversation)
+	content := statusChangeMessages[status]
+	var message *domain.Message
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Conversation{}).Where("id = ?", conversation.ID).
+			UpdateColumns(map[string]interface{}{"status": status, "updated_at": now()}).Error; err != nil {
+			return err
+		}
+		if err := syncTicketStatus(tx, conversation.ID, status); err != nil {
+			return err
+		}
+		var err error
+		message, err = createSystemMessage(tx, conversation.ID, actorID, content)
+		return err
+	})
+	if err != nil {
+		return apperror.Internal("Failed to change conversation status", err)
+	}
+	conversation.Status = status
+
+	log.Printf("Conversation %s moved from %s to %s by %s\n", conversation.ID, previous, status, actorID)
+	if conversation.Purpose.IsSupport() && (status == domain.ConversationStatusResolved || status == domain.ConversationStatusClosed) {
+		// The agent has room for another ticket now.
+		s.tickets.DrainQueue()
+	}
+	s.notifier.BroadcastEvent(domain.Event{
+		Type:           domain.EventConversationStatusChanged,
+		ConversationID: conversation.ID,
+		Data: map[string]interface{}{
+			"status":     status,
+			"previous":   previous,
+			"message_id": message.ID.String(),
+			"content":    content,
+		},
+	})
+
+	if status == domain.ConversationStatusResolved {
+		if err := s.sendSurvey(conversation, actorID); err != nil {
+			log.Printf("Warning: Failed to send satisfaction survey to conversation %s: %v", conversation.ID, err)
+		}
+	}
+	return nil
+}
+
+// syncTicketStatus mirrors a support conversation's status on its ticket, so
+// resolved and closed tickets stop counting towards the agent's load. The
+// resolution time is kept when a resolved ticket is closed and cleared when
+// it is reopened.
+func syncTicketStatus(tx *gorm.DB, conversationID uuid.UUID, status domain.ConversationStatus) error {
+	tickets := tx.Model(&domain.SupportTicket{}).Where("conversation_id = ?", conversationID).Session(&gorm.Session{})
+	resolvedAt := gorm.Expr("COALESCE(resolved_at, ?)", now())
+	switch status {
+	case domain.ConversationStatusResolved:
+		return tickets.Updates(map[string]interface{}{"status": domain.SupportTicketStatusResolved, "resolved_at": resolvedAt, "updated_at": now()}).Error
+	case domain.ConversationStatusClosed:
+		return tickets.Updates(map[string]interface{}{"status": domain.SupportTicketStatusClosed, "resolved_at": resolvedAt, "updated_at": now()}).Error
+	}
+	if err := tickets.Where("agent_id IS NULL").Updates(map[string]interface{}{"status": domain.SupportTicketStatusQueued, "resolved_at": nil, "updated_at": now()}).Error; err != nil {
+		return err
+	}
+	return tickets.Where("agent_id IS NOT NULL").Updates(map[string]interface{}{"status": domain.SupportTicketStatusAssigned, "resolved_at": nil, "updated_at": now()}).Error
+}
+
+// currentStatus treats conversations created before statuses existed as open.
+func currentStatus(conversation *domain.Conversation) domain.ConversationStatus {
+	if conversation.Status == "" {
+		return domain.ConversationStatusOpen
+	}
+	return conversation.Status
+}
+
+// ListConversations returns the conversations the user takes part in, most
+// recently active first, optionally filtered by status. Participants holds
+// only the user's own row, with their notification settings.
+func (s *chatService) ListConversations(userID uuid.UUID, status domain.ConversationStatus, limit, offset int) ([]domain.Conversation, error) {
+	if status != "" && !status.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Invalid conversation status")
+	}
+	query := s.db.
+		Preload("Participants", "user_id = ?", userID).
+		Joins("JOIN conversation_participants cp ON conversations.id = cp.conversation_id").
+		Where("cp.user_id = ? AND cp.left_at IS NULL", userID).
+		Order("conversations.updated_at DESC").
+		Limit(limit).
+		Offset(offset)
+	if status != "" {
+		query = query.Where("conversations.status = ?", status)
+	}
+	var conversations []domain.Conversation
+	if err := query.Find(&conversations).Error; err != nil {
+		return nil, apperror.Internal("Failed to list conversations", err)
+	}
+	return conversations, nil
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailSender sends an email with a plain text and an HTML body. The SMTP
+// sender implements it.
+type EmailSender interface {
+	Send(to string, subject string, text string, html string) error
+}
+
+// DigestMessage is one unread message shown in a digest.
+type DigestMessage struct {
+	Sender  string
+	Preview string
+	SentAt  time.Time
+}
+
+// DigestConversation lists the newest unread messages of a conversation.
+// Unread counts all of them, shown or not.
+type DigestConversation struct {
+	Title    string
+	Unread   int
+	Messages []DigestMessage
+}
+
+// More is the number of unread messages not shown.
+func (c DigestConversation) More() int {
+	return c.Unread - len(c.Messages)
+}
+
+// Digest is the data the digest templates are rendered with.
+type Digest struct {
+	FirstName     string
+	Unread        int
+	Conversations []DigestConversation
+	AppURL        string
+}
+
+// DigestTemplates renders digest emails from digest.txt.tmpl and
+// digest.html.tmpl.
+type DigestTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// LoadDigestTemplates parses the digest templates in dir.
+func LoadDigestTemplates(dir string) (*DigestTemplates, error) {
+	text, err := texttemplate.ParseFiles(filepath.Join(dir, "digest.txt.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load digest text template: %w", err)
+	}
+	html, err := htmltemplate.ParseFiles(filepath.Join(dir, "digest.html.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load digest HTML template: %w", err)
+	}
+	return &DigestTemplates{text: text, html: html}, nil
+}
+
+// Render returns the subject and both bodies of a digest email.
+func (t *DigestTemplates) Render(digest Digest) (subject string, text string, html string, err error) {
+	subject = fmt.Sprintf("You have %d unread messages", digest.Unread)
+	if digest.Unread == 1 {
+		subject = "You have 1 unread message"
+	}
+	var textBody, htmlBody bytes.Buffer
+	if err := t.text.Execute(&textBody, digest); err != nil {
+		return "", "", "", err
+	}
+	if err := t.html.Execute(&htmlBody, digest); err != nil {
+		return "", "", "", err
+	}
+	return subject, textBody.String(), htmlBody.String(), nil
+}
+
+// DigestOptions configures the digest job. Messages are included once
+// unread for After, and a user gets at most one digest per MinInterval, with
+// up to MaxMessages messages per conversation.
+type DigestOptions struct {
+	After       time.Duration
+	MinInterval time.Duration
+	MaxMessages int
+	AppURL      string
+}
+
+// unreadCondition selects, from conversation_participants cp joined with
+// messages m and the participant's last read message lr, messages unread
+// since before the cutoff that no digest included yet.
+const unreadCondition = `cp.left_at IS NULL AND m.deleted_at IS NULL AND m.sender_id <> cp.user_id
+	AND m.created_at < ? AND m.created_at > COALESCE(lr.created_at, cp.joined_at)
+	AND (cp.digested_until IS NULL OR m.created_at > cp.digested_until)`
+
+type DigestService interface {
+	// Run emails a digest to users with messages unread for longer than
+	// DigestOptions.After until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type digestService struct {
+	db         *gorm.DB
+	sender     EmailSender
+	templates  *DigestTemplates
+	quietHours QuietHoursService
+	options    DigestOptions
+}
+
+func NewDigestService(db *gorm.DB, sender EmailSender, templates *DigestTemplates, quietHours QuietHoursService, options DigestOptions) DigestService {
+	return &digestService{db: db, sender: sender, templates: templates, quietHours: quietHours, options: options}
+}
+
+func (s *digestService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendDigests(ctx)
+		}
+	}
+}
+
+func (s *digestService) sendDigests(ctx context.Context) {
+	cutoff := now().Add(-s.options.After)
+	var userIDs []uuid.UUID
+	err := s.db.Table("conversation_participants cp").
+		Joins("JOIN messages m ON m.conversation_id = cp.conversation_id").
+		Joins("LEFT JOIN messages lr ON lr.id = cp.last_read_message_id").
+		Where(unreadCondition, cutoff).
+		Distinct().
+		Pluck("cp.user_id", &userIDs).Error
+	if err != nil {
+		log.Printf("Warning: Failed to find users with unread messages: %v", err)
+		return
+	}
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.sendDigest(userID, cutoff); err != nil {
+			log.Printf("Warning: Failed to send digest to user %s: %v", userID, err)
+		}
+	}
+}
+
+// sendDigest emails one user their unread messages older than cutoff, unless
+// they had a digest recently, are in quiet hours or have no verified email.
+// Messages of muted conversations are marked digested without being sent.
+func (s *digestService) sendDigest(userID uuid.UUID, cutoff time.Time) error {
+	recent, err := s.digestedRecently(s.db, userID)
+	if err != nil || recent {
+		return err
+	}
+	var user domain.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	if !user.IsVerified || user.Email == "" {
+		return nil
+	}
+	until, err := s.quietHours.DeferUntil(userID, now())
+	if err != nil || until.After(now()) {
+		return err
+	}
+	var setting *domain.NotificationSetting
+	var saved domain.NotificationSetting
+	err = s.db.Where("user_id = ?", userID).First(&saved).Error
+	if err == nil {
+		setting = &saved
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var participants []domain.ConversationParticipant
+	if err := s.db.Preload("Conversation").Where("user_id = ? AND left_at IS NULL", userID).Find(&participants).Error; err != nil {
+		return err
+	}
+	digest := Digest{FirstName: user.FirstName, AppURL: s.options.AppURL}
+	digested := make(map[uuid.UUID]time.Time)
+	for i := range participants {
+		conversation, newest, err := s.unread(&participants[i], cutoff)
+		if err != nil {
+			return err
+		}
+		if conversation == nil {
+			continue
+		}
+		digested[participants[i].ConversationID] = newest
+		if !NotificationAllowed(setting, &participants[i], domain.NotificationTypeMessage, now()) {
+			continue
+		}
+		digest.Unread += conversation.Unread
+		digest.Conversations = append(digest.Conversations, *conversation)
+	}
+	if len(digested) == 0 {
+		return nil
+	}
+	var subject, text, html string
+	if len(digest.Conversations) > 0 {
+		if subject, text, html, err = s.templates.Render(digest); err != nil {
+			return err
+		}
+	}
+
+	// The user is claimed by locking their row, so of two replicas only one
+	// records the digest; the other skips the user or finds the record.
+	var record *domain.EmailDigest
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var claimed []domain.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Select("id").Where("id = ?", userID).Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		recent, err := s.digestedRecently(tx, userID)
+		if err != nil || recent {
+			return err
+		}
+		for conversationID, newest := range digested {
+			if err := tx.Model(&domain.ConversationParticipant{}).
+				Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+				Update("digested_until", newest).Error; err != nil {
+				return err
+			}
+		}
+		if len(digest.Conversations) == 0 {
+			return nil
+		}
+		record = &domain.EmailDigest{
+			ID:                uuid.New(),
+			UserID:            userID,
+			Email:             user.Email,
+			MessageCount:      digest.Unread,
+			ConversationCount: len(digest.Conversations),
+			SentAt:            now(),
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil || record == nil {
+		return err
+	}
+
+	// The email is sent only once the digest is recorded, outside the
+	// transaction. A failed send is undone, leaving its messages for the next
+	// run.
+	if err := s.sender.Send(user.Email, subject, text, html); err != nil {
+		if undoErr := s.undo(record, participants, digested); undoErr != nil {
+			log.Printf("Warning: Failed to undo digest %s: %v", record.ID, undoErr)
+		}
+		return err
+	}
+	log.Printf("Digest of %d messages in %d conversations emailed to user %s\n", record.MessageCount, record.ConversationCount, userID)
+	return nil
+}
+
+// digestedRecently reports whether the user was sent a digest within
+// DigestOptions.MinInterval.
+func (s *digestService) digestedRecently(db *gorm.DB, userID uuid.UUID) (bool, error) {
+	var recent int64
+	if err := db.Model(&domain.EmailDigest{}).Where("user_id = ? AND sent_at > ?", userID, now().Add(-s.options.MinInterval)).Count(&recent).Error; err != nil {
+		return false, err
+	}
+	return recent > 0, nil
+}
+
+// undo deletes a digest whose email was not sent and moves the participants'
+// digested_until back to where it was.
+func (s *digestService) undo(record *domain.EmailDigest, participants []domain.ConversationParticipant, digested map[uuid.UUID]time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range participants {
+			if _, ok := digested[participants[i].ConversationID]; !ok {
+				continue
+			}
+			if err := tx.Model(&domain.ConversationParticipant{}).
+				Where("conversation_id = ? AND user_id = ?", participants[i].ConversationID, record.UserID).
+				Update("digested_until", participants[i].DigestedUntil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&domain.EmailDigest{}, "id = ?", record.ID).Error
+	})
+}
+
+// unread returns a participant's undigested unread messages older than
+// cutoff and the creation time of the newest, or nil when there are none.
+func (s *digestService) unread(participant *domain.ConversationParticipant, cutoff time.Time) (*DigestConversation, time.Time, error) {
+	since := participant.JoinedAt
+	if participant.LastReadMessageID.Valid {
+		var lastRead domain.Message
+		err := s.db.Unscoped().Select("created_at").First(&lastRead, "id = ?", participant.LastReadMessageID.String).Error
+		if err == nil {
+			since = lastRead.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, time.Time{}, err
+		}
+	}
+	if participant.DigestedUntil.Valid && participant.DigestedUntil.Time.After(since) {
+		since = participant.DigestedUntil.Time
+	}
+
+	query := s.db.Model(&domain.Message{}).
+		Where("conversation_id = ? AND sender_id <> ? AND created_at > ? AND created_at < ?", participant.ConversationID, participant.UserID, since, cutoff).
+		Session(&gorm.Session{})
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, time.Time{}, err
+	}
+	if count == 0 {
+		return nil, time.Time{}, nil
+	}
+	var messages []domain.Message
+	if err := query.Preload("Sender").Order("created_at DESC").Limit(s.options.MaxMessages).Find(&messages).Error; err != nil {
+		return nil, time.Time{}, err
+	}
+	conversation := &DigestConversation{Title: conversationTitle(&participant.Conversation), Unread: int(count)}
+	for i := len(messages) - 1; i >= 0; i-- {
+		sender := messages[i].Sender.FirstName
+		if sender == "" {
+			sender = "Someone"
+		}
+		conversation.Messages = append(conversation.Messages, DigestMessage{
+			Sender:  sender,
+			Preview: NotificationPreview(&messages[i]),
+			SentAt:  messages[i].CreatedAt.UTC(),
+		})
+	}
+	return conversation, messages[0].CreatedAt, nil
+}
+
+// conversationTitle names a conversation in a digest: its name, or its
+// purpose, e.g. "Nikkah service".
+func conversationTitle(conversation *domain.Conversation) string {
+	if conversation.Name.Valid && conversation.Name.String != "" {
+		return conversation.Name.String
+	}
+	title := strings.ReplaceAll(string(conversation.Purpose), "_", " ")
+	if title == "" {
+		return "Conversation"
+	}
+	first, size := utf8.DecodeRuneInString(title)
+	return string(unicode.ToUpper(first)) + title[size:]
+}
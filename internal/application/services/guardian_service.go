@@ -0,0 +1,259 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuardianService manages the wali or mahram who observes a user's nikkah
+// conversations.
+type GuardianService interface {
+	SetGuardian(userID uuid.UUID, guardianID uuid.UUID, requireApproval bool) (*domain.Guardian, error)
+	GetGuardian(userID uuid.UUID) (*domain.Guardian, error)
+	RemoveGuardian(userID uuid.UUID) error
+	ApproveConversation(guardianID uuid.UUID, conversationID uuid.UUID) (*domain.Conversation, error)
+	RemoveObserver(actorID uuid.UUID, conversationID uuid.UUID, observerID uuid.UUID) error
+}
+
+type guardianService struct {
+	db       *gorm.DB
+	notifier LiveNotifier
+}
+
+func NewGuardianService(db *gorm.DB, notifier LiveNotifier) GuardianService {
+	return &guardianService{db: db, notifier: notifier}
+}
+
+// SetGuardian designates or replaces the user's guardian. It only affects
+// conversations created afterwards; guardians already observing a
+// conversation stay in it.
+func (s *guardianService) SetGuardian(userID uuid.UUID, guardianID uuid.UUID, requireApproval bool) (*domain.Guardian, error) {
+	if userID == guardianID {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "You cannot be your own guardian")
+	}
+	guardian := domain.Guardian{
+		UserID:          userID,
+		GuardianID:      guardianID,
+		RequireApproval: requireApproval,
+		CreatedAt:       now(),
+		UpdatedAt:       now(),
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"guardian_id", "require_approval", "updated_at"}),
+	}).Create(&guardian).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to save guardian", err)
+	}
+	log.Printf("User %s designated %s as guardian (approval required: %t)\n", userID, guardianID, requireApproval)
+	return &guardian, nil
+}
+
+func (s *guardianService) GetGuardian(userID uuid.UUID) (*domain.Guardian, error) {
+	var guardian domain.Guardian
+	if err := s.db.First(&guardian, "user_id = ?", userID).Error; err != nil {
+		return nil, notFoundOrInternal("No guardian designated", err)
+	}
+	return &guardian, nil
+}
+
+func (s *guardianService) RemoveGuardian(userID uuid.UUID) error {
+	if err := s.db.Delete(&domain.Guardian{}, "user_id = ?", userID).Error; err != nil {
+		return apperror.Internal("Failed to remove guardian", err)
+	}
+	log.Printf("User %s removed their guardian designation\n", userID)
+	return nil
+}
+
+// ApproveConversation records a guardian's approval. Once every guardian whose
+// approval is required has approved, the conversation is approved and
+// messages can flow.
+func (s *guardianService) ApproveConversation(guardianID uuid.UUID, conversationID uuid.UUID) (*domain.Conversation, error) {
+	participant, err := requireParticipant(s.db, conversationID, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	if participant.Role != domain.ParticipantRoleObserver || !participant.ApprovalRequired {
+		return nil, apperror.New(apperror.CodeForbidden, "Only a guardian whose approval is required can approve this conversation")
+	}
+	if participant.ApprovedAt.Valid {
+		return nil, apperror.New(apperror.CodeConflict, "You have already approved this conversation")
+	}
+
+	var conversation domain.Conversation
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.ConversationParticipant{}).Where("conversation_id = ? AND user_id = ?", conversationID, guardianID).
+			Update("approved_at", now()).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&conversation, "id = ?", conversationID).Error; err != nil {
+			return err
+		}
+		_, err := approveWhenReady(tx, &conversation)
+		return err
+	})
+	if err != nil {
+		return nil, apperror.Internal("Failed to approve conversation", err)
+	}
+
+	log.Printf("Guardian %s approved conversation %s\n", guardianID, conversationID)
+	if conversation.ApprovedAt.Valid {
+		s.notifier.BroadcastEvent(domain.Event{Type: domain.EventConversationApproved, ConversationID: conversationID})
+	}
+	return &conversation, nil
+}
+
+// RemoveObserver takes a guardian out of a conversation. Only the guardian
+// themselves or a moderator can do this, and it is always announced to the
+// conversation with a system message.
+func (s *guardianService) RemoveObserver(actorID uuid.UUID, conversationID uuid.UUID, observerID uuid.UUID) error {
+	var observer domain.ConversationParticipant
+	err := s.db.Where("conversation_id = ? AND user_id = ? AND role = ? AND left_at IS NULL", conversationID, observerID, domain.ParticipantRoleObserver).
+		First(&observer).Error
+	if err != nil {
+		return notFoundOrInternal("Observer not found", err)
+	}
+
+	content := "The guardian left the conversation."
+	if actorID != observerID {
+		isModerator, err := hasStaffRole(s.db, actorID, domain.StaffRoleModerator)
+		if err != nil {
+			return err
+		}
+		if !isModerator {
+			return apperror.New(apperror.CodeForbidden, "A guardian can only be removed by themselves or a moderator")
+		}
+		content = "The guardian was removed from the conversation by a moderator."
+	}
+
+	var message *domain.Message
+	var approved bool
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.ConversationParticipant{}).Where("conversation_id = ? AND user_id = ?", conversationID, observerID).
+			Update("left_at", now()).Error; err != nil {
+			return err
+		}
+		var err error
+		message, err = createSystemMessage(tx, conversationID, actorID, content)
+		if err != nil {
+			return err
+		}
+		// The conversation no longer waits for a guardian who left.
+		var conversation domain.Conversation
+		if err := tx.First(&conversation, "id = ?", conversationID).Error; err != nil {
+			return err
+		}
+		approved, err = approveWhenReady(tx, &conversation)
+		return err
+	})
+	if err != nil {
+		return apperror.Internal("Failed to remove observer", err)
+	}
+
+	log.Printf("Observer %s removed from conversation %s by %s\n", observerID, conversationID, actorID)
+	event := domain.Event{
+		Type:           domain.EventObserverLeft,
+		ConversationID: conversationID,
+		Data:           map[string]interface{}{"user_id": observerID.String(), "message_id": message.ID.String(), "content": content},
+	}
+	s.notifier.BroadcastEvent(event)
+	s.notifier.DisconnectParticipant(conversationID, observerID, event)
+	if approved {
+		s.notifier.BroadcastEvent(domain.Event{Type: domain.EventConversationApproved, ConversationID: conversationID})
+	}
+	return nil
+}
+
+// guardianObservers returns the observer participants to add to a new private
+// nikkah conversation between members: one per distinct guardian who is not a
+// member themselves.
+func guardianObservers(db *gorm.DB, conversationID uuid.UUID, members ...uuid.UUID) ([]domain.ConversationParticipant, error) {
+	var guardians []domain.Guardian
+	if err := db.Where("user_id IN ?", members).Find(&guardians).Error; err != nil {
+		return nil, apperror.Internal("Failed to load guardians", err)
+	}
+
+	isMember := make(map[uuid.UUID]bool, len(members))
+	for _, member := range members {
+		isMember[member] = true
+	}
+	var observers []domain.ConversationParticipant
+	index := make(map[uuid.UUID]int)
+	for _, guardian := range guardians {
+		if isMember[guardian.GuardianID] {
+			continue
+		}
+		if i, ok := index[guardian.GuardianID]; ok {
+			observers[i].ApprovalRequired = observers[i].ApprovalRequired || guardian.RequireApproval
+			continue
+		}
+		index[guardian.GuardianID] = len(observers)
+		observers = append(observers, domain.ConversationParticipant{
+			ConversationID:   conversationID,
+			UserID:           guardian.GuardianID,
+			JoinedAt:         now(),
+			Role:             domain.ParticipantRoleObserver,
+			ApprovalRequired: guardian.RequireApproval,
+		})
+	}
+	return observers, nil
+}
+
+func pendingApprovals(db *gorm.DB, conversationID uuid.UUID) (int64, error) {
+	var pending int64
+	err := db.Model(&domain.ConversationParticipant{}).
+		Where("conversation_id = ? AND role = ? AND approval_required AND approved_at IS NULL AND left_at IS NULL", conversationID, domain.ParticipantRoleObserver).
+		Count(&pending).Error
+	return pending, err
+}
+
+// approveWhenReady approves a nikkah conversation that no guardian's approval
+// is pending for any more, and reports whether it did.
+func approveWhenReady(db *gorm.DB, conversation *domain.Conversation) (bool, error) {
+	if conversation.Purpose != domain.ConversationPurposeNikkah || conversation.ApprovedAt.Valid {
+		return false, nil
+	}
+	pending, err := pendingApprovals(db, conversation.ID)
+	if err != nil || pending > 0 {
+		return false, err
+	}
+	approvedAt := sql.NullTime{Time: now(), Valid: true}
+	result := db.Model(conversation).Where("approved_at IS NULL").Update("approved_at", approvedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	conversation.ApprovedAt = approvedAt
+	return result.RowsAffected > 0, nil
+}
+
+// ensureApproved rejects messages in a conversation that still waits for a
+// guardian's approval. A conversation nobody's approval is pending for is
+// approved on the way, as those created before guardians could approve were
+// never marked.
+func ensureApproved(db *gorm.DB, conversation *domain.Conversation) error {
+	if _, err := approveWhenReady(db, conversation); err != nil {
+		return apperror.Internal("Failed to check conversation approval", err)
+	}
+	if conversation.Purpose == domain.ConversationPurposeNikkah && !conversation.ApprovedAt.Valid {
+		return apperror.New(apperror.CodeForbidden, "This conversation is waiting for a guardian's approval")
+	}
+	return nil
+}
+
+// ensureCanWrite rejects senders who are not participants or only observe.
+func ensureCanWrite(db *gorm.DB, conversationID uuid.UUID, senderID uuid.UUID) error {
+	participant, err := requireParticipant(db, conversationID, senderID)
+	if err != nil {
+		return err
+	}
+	if participant.Role == domain.ParticipantRoleObserver {
+		return apperror.New(apperror.CodeForbidden, "Observers cannot send messages")
+	}
+	return nil
+}
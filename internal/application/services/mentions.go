@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxMentionsPerMessage bounds how many participants one message can
+// mention, so a message cannot notify a whole group one name at a time.
+const MaxMentionsPerMessage = 20
+
+// MentionToken is an "@username" found in message content. Offset and Length
+// are in runes and include the '@'.
+type MentionToken struct {
+	Username string
+	Offset   int
+	Length   int
+}
+
+// Mention is a mention of a participant, stored under "mentions" in the
+// message's metadata so clients can highlight it.
+type Mention struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Offset   int       `json:"offset"`
+	Length   int       `json:"length"`
+}
+
+func isUsernameRune(r rune) bool {
+	return r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-')
+}
+
+// ParseMentions finds the "@username" tokens in content. An '@' starts a
+// mention at the start of the content or after anything but a letter, digit
+// or underscore, so email addresses are not mentions. Trailing dots and
+// hyphens end the sentence rather than the username.
+func ParseMentions(content string) []MentionToken {
+	runes := []rune(content)
+	var tokens []MentionToken
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' {
+			continue
+		}
+		if i > 0 && (unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1]) || runes[i-1] == '_') {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isUsernameRune(runes[end]) {
+			end++
+		}
+		for end > i+1 && (runes[end-1] == '.' || runes[end-1] == '-') {
+			end--
+		}
+		if end > i+1 {
+			tokens = append(tokens, MentionToken{Username: string(runes[i+1 : end]), Offset: i, Length: end - i})
+		}
+		i = end - 1
+	}
+	return tokens
+}
+
+// resolveMentions matches mention tokens to current participants of the
+// conversation, ignoring case. Names that are no participant's stay plain
+// text.
+func resolveMentions(db *gorm.DB, conversationID uuid.UUID, tokens []MentionToken) ([]Mention, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		names = append(names, strings.ToLower(token.Username))
+	}
+	var users []domain.User
+	if err := db.Select("users.id", "users.username").
+		Joins("JOIN conversation_participants cp ON cp.user_id = users.id").
+		Where("cp.conversation_id = ? AND cp.left_at IS NULL AND LOWER(users.username) IN ?", conversationID, names).
+		Find(&users).Error; err != nil {
+		return nil, apperror.Internal("Failed to look up mentioned users", err)
+	}
+	byName := make(map[string]domain.User, len(users))
+	for _, user := range users {
+		byName[strings.ToLower(user.Username)] = user
+	}
+
+	var mentions []Mention
+	distinct := make(map[uuid.UUID]bool)
+	for _, token := range tokens {
+		user, ok := byName[strings.ToLower(token.Username)]
+		if !ok {
+			continue
+		}
+		mentions = append(mentions, Mention{UserID: user.ID, Username: user.Username, Offset: token.Offset, Length: token.Length})
+		distinct[user.ID] = true
+	}
+	if len(distinct) > MaxMentionsPerMessage {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Too many mentions in one message")
+	}
+	return mentions, nil
+}
+
+// withMentions sets the "mentions" key of the metadata JSON object, replacing
+// any a client sent, and drops it when there are none.
+func withMentions(metadata []byte, mentions []Mention) ([]byte, error) {
+	fields := map[string]interface{}{}
+	if len(metadata) > 0 && string(metadata) != "null" {
+		if err := json.Unmarshal(metadata, &fields); err != nil {
+			return nil, apperror.Wrap(apperror.CodeInvalidRequest, "Metadata must be a JSON object", err)
+		}
+	}
+	if _, ok := fields["mentions"]; !ok && len(mentions) == 0 {
+		return metadata, nil
+	}
+	delete(fields, "mentions")
+	if len(mentions) > 0 {
+		fields["mentions"] = mentions
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, apperror.Internal("Failed to encode metadata", err)
+	}
+	return merged, nil
+}
+
+// mentionedUsers returns the distinct users mentioned, but not the sender.
+func mentionedUsers(mentions []Mention, senderID uuid.UUID) map[uuid.UUID]bool {
+	users := make(map[uuid.UUID]bool, len(mentions))
+	for _, mention := range mentions {
+		if mention.UserID != senderID {
+			users[mention.UserID] = true
+		}
+	}
+	return users
+}
+
+// createMentions records who a message mentions, for ListMentions.
+func createMentions(tx *gorm.DB, message *domain.Message, mentioned map[uuid.UUID]bool) error {
+	if len(mentioned) == 0 {
+		return nil
+	}
+	rows := make([]domain.MessageMention, 0, len(mentioned))
+	for userID := range mentioned {
+		rows = append(rows, domain.MessageMention{
+			MessageID:      message.ID,
+			UserID:         userID,
+			ConversationID: message.ConversationID,
+			CreatedAt:      message.CreatedAt,
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// ListMentions returns messages mentioning the user in conversations they
+// are still part of, newest first.
+func (s *chatService) ListMentions(userID uuid.UUID, limit, offset int) ([]domain.Message, error) {
+	var messages []domain.Message
+	err := s.db.
+		Joins("JOIN message_mentions mm ON mm.message_id = messages.id").
+		Joins("JOIN conversation_participants cp ON cp.conversation_id = messages.conversation_id AND cp.user_id = mm.user_id").
+		Where("mm.user_id = ? AND cp.left_at IS NULL", userID).
+		Order("messages.created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&messages).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to list mentions", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+// MentorCandidate is an active mentor as seen by SelectMentor. Mentees counts
+// proposed and active mentorships.
+type MentorCandidate struct {
+	UserID        uuid.UUID
+	Gender        domain.Gender
+	Languages     []string
+	Capacity      int
+	Mentees       int
+	LastMatchedAt time.Time
+}
+
+// MenteeRequest is what a revert asked for. Languages are in order of
+// preference; Excluded holds mentors who must not be proposed again.
+type MenteeRequest struct {
+	RevertID  uuid.UUID
+	Gender    domain.Gender
+	Languages []string
+	Excluded  map[uuid.UUID]bool
+}
+
+// SelectMentor picks the mentor for a request: same gender, a shared language
+// and spare capacity are required. Among those it prefers the revert's
+// earliest-listed language, then the lowest share of capacity in use, then
+// the mentor who waited longest since their last match.
+func SelectMentor(request MenteeRequest, candidates []MentorCandidate) (MentorCandidate, bool) {
+	var best MentorCandidate
+	bestRank := -1
+	found := false
+	for _, c := range candidates {
+		if c.UserID == request.RevertID || request.Excluded[c.UserID] {
+			continue
+		}
+		if c.Gender != request.Gender || c.Mentees >= c.Capacity {
+			continue
+		}
+		rank := languageRank(request.Languages, c.Languages)
+		if rank < 0 {
+			continue
+		}
+		if !found || rank < bestRank || rank == bestRank && betterMentor(c, best) {
+			best, bestRank, found = c, rank, true
+		}
+	}
+	return best, found
+}
+
+// languageRank returns the position of the first preferred language the
+// mentor speaks, or -1 if they share none.
+func languageRank(preferred []string, spoken []string) int {
+	for i, language := range preferred {
+		for _, s := range spoken {
+			if s == language {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func betterMentor(a, b MentorCandidate) bool {
+	// Compare load as a share of capacity: a/ca < b/cb.
+	if la, lb := a.Mentees*b.Capacity, b.Mentees*a.Capacity; la != lb {
+		return la < lb
+	}
+	if !a.LastMatchedAt.Equal(b.LastMatchedAt) {
+		return a.LastMatchedAt.Before(b.LastMatchedAt)
+	}
+	return a.UserID.String() < b.UserID.String()
+}
@@ -0,0 +1,529 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxMentorCapacity = 50
+
+// MentorshipService pairs new Muslims with mentors for revert_service
+// conversations.
+type MentorshipService interface {
+	RegisterMentor(userID uuid.UUID, capacity int, languages []string, active bool) (*domain.MentorProfile, error)
+	GetMentorProfile(userID uuid.UUID) (*domain.MentorProfile, error)
+	RequestMentor(revertID uuid.UUID, languages []string) (*domain.Mentorship, error)
+	ListMentorships(userID uuid.UUID) ([]domain.Mentorship, error)
+	Accept(mentorID uuid.UUID, mentorshipID uuid.UUID) (*domain.Mentorship, error)
+	Decline(mentorID uuid.UUID, mentorshipID uuid.UUID) (*domain.Mentorship, error)
+	// Run periodically re-proposes lapsed proposals, replaces inactive
+	// mentors and proposes mentors for waiting requests until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type mentorshipService struct {
+	db            *gorm.DB
+	notifier      LiveNotifier
+	proposalTTL   time.Duration
+	inactiveAfter time.Duration
+}
+
+// NewMentorshipService creates the service. A proposal not answered within
+// proposalTTL goes to another mentor, and a mentor who has not written to any
+// mentee for inactiveAfter is marked inactive and replaced.
+func NewMentorshipService(db *gorm.DB, notifier LiveNotifier, proposalTTL, inactiveAfter time.Duration) MentorshipService {
+	return &mentorshipService{db: db, notifier: notifier, proposalTTL: proposalTTL, inactiveAfter: inactiveAfter}
+}
+
+var openMentorshipStatuses = []domain.MentorshipStatus{domain.MentorshipStatusRequested, domain.MentorshipStatusProposed, domain.MentorshipStatusActive}
+
+// RegisterMentor creates or updates the caller's mentor profile. Deactivating
+// it hands the mentor's mentees to other mentors.
+func (s *mentorshipService) RegisterMentor(userID uuid.UUID, capacity int, languages []string, active bool) (*domain.MentorProfile, error) {
+	if err := requireStaffRole(s.db, userID, domain.StaffRoleMentor); err != nil {
+		return nil, err
+	}
+	if capacity < 1 || capacity > maxMentorCapacity {
+		return nil, apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("capacity must be between 1 and %d", maxMentorCapacity))
+	}
+	languageList := domain.SplitLanguages(strings.Join(languages, ","))
+	if len(languageList) == 0 {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "At least one language is required")
+	}
+	gender, err := s.profileGender(userID, "mentor")
+	if err != nil {
+		return nil, err
+	}
+
+	profile := domain.MentorProfile{
+		UserID:    userID,
+		Gender:    gender,
+		Languages: strings.Join(languageList, ","),
+		Capacity:  capacity,
+		Active:    active,
+		CreatedAt: now(),
+		UpdatedAt: now(),
+	}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"gender", "languages", "capacity", "active", "updated_at"}),
+	}).Create(&profile).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to save mentor profile", err)
+	}
+	log.Printf("Mentor %s registered with capacity %d (%s), active: %t\n", userID, capacity, profile.Languages, active)
+
+	if active {
+		s.proposeWaiting()
+	} else {
+		s.releaseMentor(userID, "inactive")
+	}
+	return &profile, nil
+}
+
+func (s *mentorshipService) GetMentorProfile(userID uuid.UUID) (*domain.MentorProfile, error) {
+	var profile domain.MentorProfile
+	if err := s.db.First(&profile, "user_id = ?", userID).Error; err != nil {
+		return nil, notFoundOrInternal("Mentor profile not found", err)
+	}
+	return &profile, nil
+}
+
+func (s *mentorshipService) profileGender(userID uuid.UUID, purpose string) (domain.Gender, error) {
+	var user domain.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return "", notFoundOrInternal("Your user profile was not found", err)
+	}
+	if !user.GenderValue().IsValid() {
+		return "", apperror.New(apperror.CodeForbidden, "Your profile must specify your gender to "+purpose)
+	}
+	return user.GenderValue(), nil
+}
+
+// RequestMentor opens a mentorship request for a revert and proposes a mentor
+// right away when one is available.
+func (s *mentorshipService) RequestMentor(revertID uuid.UUID, languages []string) (*domain.Mentorship, error) {
+	languageList := domain.SplitLanguages(strings.Join(languages, ","))
+	if len(languageList) == 0 {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "At least one language is required")
+	}
+	var existing int64
+	if err := s.db.Model(&domain.Mentorship{}).Where("revert_id = ? AND status IN ?", revertID, openMentorshipStatuses).Count(&existing).Error; err != nil {
+		return nil, apperror.Internal("Failed to check mentorships", err)
+	}
+	if existing > 0 {
+		return nil, apperror.New(apperror.CodeConflict, "You already have a mentorship")
+	}
+	gender, err := s.profileGender(revertID, "request a mentor")
+	if err != nil {
+		return nil, err
+	}
+
+	mentorship := domain.Mentorship{
+		ID:        uuid.New(),
+		RevertID:  revertID,
+		Gender:    gender,
+		Languages: strings.Join(languageList, ","),
+		Status:    domain.MentorshipStatusRequested,
+		CreatedAt: now(),
+		UpdatedAt: now(),
+	}
+	if err := s.db.Create(&mentorship).Error; err != nil {
+		return nil, apperror.Internal("Failed to request a mentor", err)
+	}
+	log.Printf("Revert %s requested a mentor (%s)\n", revertID, mentorship.Languages)
+
+	if err := s.propose(&mentorship); err != nil {
+		log.Printf("Warning: Failed to propose a mentor for mentorship %s: %v", mentorship.ID, err)
+	}
+	return &mentorship, nil
+}
+
+func (s *mentorshipService) ListMentorships(userID uuid.UUID) ([]domain.Mentorship, error) {
+	var mentorships []domain.Mentorship
+	if err := s.db.Where("revert_id = ? OR mentor_id = ?", userID, userID).Order("created_at DESC").Find(&mentorships).Error; err != nil {
+		return nil, apperror.Internal("Failed to list mentorships", err)
+	}
+	return mentorships, nil
+}
+
+// propose offers a requested mentorship to the best available mentor. It
+// stays requested when nobody fits.
+func (s *mentorshipService) propose(mentorship *domain.Mentorship) error {
+	var profiles []domain.MentorProfile
+	if err := s.db.Where("active AND gender = ?", mentorship.Gender).Find(&profiles).Error; err != nil {
+		return err
+	}
+	var declines []domain.MentorshipDecline
+	if err := s.db.Where("mentorship_id = ?", mentorship.ID).Find(&declines).Error; err != nil {
+		return err
+	}
+	request := MenteeRequest{
+		RevertID:  mentorship.RevertID,
+		Gender:    mentorship.Gender,
+		Languages: domain.SplitLanguages(mentorship.Languages),
+		Excluded:  make(map[uuid.UUID]bool, len(declines)),
+	}
+	for _, decline := range declines {
+		request.Excluded[decline.MentorID] = true
+	}
+
+	candidates := make([]MentorCandidate, 0, len(profiles))
+	for _, profile := range profiles {
+		var mentees int64
+		if err := s.db.Model(&domain.Mentorship{}).
+			Where("mentor_id = ? AND status IN ?", profile.UserID, []domain.MentorshipStatus{domain.MentorshipStatusProposed, domain.MentorshipStatusActive}).
+			Count(&mentees).Error; err != nil {
+			return err
+		}
+		candidate := MentorCandidate{
+			UserID:    profile.UserID,
+			Gender:    profile.Gender,
+			Languages: profile.LanguageList(),
+			Capacity:  profile.Capacity,
+			Mentees:   int(mentees),
+		}
+		if profile.LastMatchedAt.Valid {
+			candidate.LastMatchedAt = profile.LastMatchedAt.Time
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	mentor, ok := SelectMentor(request, candidates)
+	if !ok {
+		log.Printf("No mentor available for mentorship %s yet\n", mentorship.ID)
+		return nil
+	}
+	result := s.db.Model(&domain.Mentorship{}).
+		Where("id = ? AND status = ?", mentorship.ID, domain.MentorshipStatusRequested).
+		Updates(map[string]interface{}{
+			"status":      domain.MentorshipStatusProposed,
+			"mentor_id":   mentor.UserID.String(),
+			"proposed_at": now(),
+			"updated_at":  now(),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	mentorship.Status = domain.MentorshipStatusProposed
+	mentorship.MentorID = sql.NullString{String: mentor.UserID.String(), Valid: true}
+	mentorship.ProposedAt = sql.NullTime{Time: now(), Valid: true}
+
+	log.Printf("Mentor %s proposed for mentorship %s\n", mentor.UserID, mentorship.ID)
+	s.notifier.NotifyUser(mentor.UserID, domain.Event{
+		Type: domain.EventMentorshipProposed,
+		Data: map[string]interface{}{"mentorship_id": mentorship.ID.String(), "languages": request.Languages},
+	})
+	return nil
+}
+
+// proposeWaiting proposes mentors for requested mentorships, oldest first.
+func (s *mentorshipService) proposeWaiting() {
+	var waiting []domain.Mentorship
+	if err := s.db.Where("status = ?", domain.MentorshipStatusRequested).Order("created_at ASC").Find(&waiting).Error; err != nil {
+		log.Printf("Warning: Failed to load waiting mentorships: %v", err)
+		return
+	}
+	for i := range waiting {
+		if err := s.propose(&waiting[i]); err != nil {
+			log.Printf("Warning: Failed to propose a mentor for mentorship %s: %v", waiting[i].ID, err)
+		}
+	}
+}
+
+// loadProposal returns a mentorship proposed to mentorID.
+func (s *mentorshipService) loadProposal(mentorID uuid.UUID, mentorshipID uuid.UUID) (*domain.Mentorship, error) {
+	var mentorship domain.Mentorship
+	if err := s.db.First(&mentorship, "id = ?", mentorshipID).Error; err != nil {
+		return nil, notFoundOrInternal("Mentorship not found", err)
+	}
+	if mentorship.MentorID.String != mentorID.String() {
+		return nil, apperror.New(apperror.CodeForbidden, "This mentorship was not proposed to you")
+	}
+	if mentorship.Status != domain.MentorshipStatusProposed {
+		return nil, apperror.New(apperror.CodeConflict, "This mentorship is no longer waiting for your answer")
+	}
+	return &mentorship, nil
+}
+
+// Accept starts the mentorship. The first mentor to accept gets a new private
+// revert_service conversation with the revert; a replacement mentor joins the
+// existing one so the history is kept.
+func (s *mentorshipService) Accept(mentorID uuid.UUID, mentorshipID uuid.UUID) (*domain.Mentorship, error) {
+	mentorship, err := s.loadProposal(mentorID, mentorshipID)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement := mentorship.ConversationID.Valid
+	acceptedAt := now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// The proposal may have been declined, lapsed or accepted since it
+		// was loaded; only the update that still finds it proposed wins.
+		result := tx.Model(&domain.Mentorship{}).
+			Where("id = ? AND status = ?", mentorship.ID, domain.MentorshipStatusProposed).
+			Updates(map[string]interface{}{"status": domain.MentorshipStatusActive, "accepted_at": acceptedAt, "updated_at": acceptedAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperror.New(apperror.CodeConflict, "This mentorship is no longer waiting for your answer")
+		}
+
+		if replacement {
+			conversationID, err := uuid.Parse(mentorship.ConversationID.String)
+			if err != nil {
+				return err
+			}
+			if err := addParticipant(tx, conversationID, mentorID, domain.ParticipantRoleMember); err != nil {
+				return err
+			}
+			if _, err := createSystemMessage(tx, conversationID, mentorID, "A new mentor joined the conversation."); err != nil {
+				return err
+			}
+		} else {
+			conversation := domain.Conversation{
+				ID:        uuid.New(),
+				CreatorID: mentorship.RevertID,
+				Type:      domain.ConversationTypePrivate,
+				Purpose:   domain.ConversationPurposeRevertService,
+				Name:      sql.NullString{String: fmt.Sprintf("Mentorship for %s & %s", mentorship.RevertID.String()[:8], mentorID.String()[:8]), Valid: true},
+				CreatedAt: now(),
+				UpdatedAt: now(),
+			}
+			if err := tx.Omit(clause.Associations).Create(&conversation).Error; err != nil {
+				return err
+			}
+			for _, userID := range []uuid.UUID{mentorship.RevertID, mentorID} {
+				if err := addParticipant(tx, conversation.ID, userID, domain.ParticipantRoleMember); err != nil {
+					return err
+				}
+			}
+			mentorship.ConversationID = sql.NullString{String: conversation.ID.String(), Valid: true}
+			if err := tx.Model(&domain.Mentorship{}).Where("id = ?", mentorship.ID).Update("conversation_id", mentorship.ConversationID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&domain.MentorProfile{}).Where("user_id = ?", mentorID).Update("last_matched_at", acceptedAt).Error
+	})
+	if err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		return nil, apperror.Internal("Failed to accept mentorship", err)
+	}
+	mentorship.Status = domain.MentorshipStatusActive
+	mentorship.AcceptedAt = sql.NullTime{Time: acceptedAt, Valid: true}
+	mentorship.UpdatedAt = acceptedAt
+
+	log.Printf("Mentor %s accepted mentorship %s (conversation %s)\n", mentorID, mentorship.ID, mentorship.ConversationID.String)
+	conversationID, _ := uuid.Parse(mentorship.ConversationID.String)
+	event := domain.Event{
+		Type:           domain.EventMentorshipAccepted,
+		ConversationID: conversationID,
+		Data: map[string]interface{}{
+			"mentorship_id":   mentorship.ID.String(),
+			"mentor_id":       mentorID.String(),
+			"conversation_id": mentorship.ConversationID.String,
+		},
+	}
+	if replacement {
+		s.notifier.BroadcastEvent(event)
+	} else {
+		s.notifier.NotifyUser(mentorship.RevertID, event)
+	}
+	return mentorship, nil
+}
+
+// Decline passes the proposal on to the next mentor.
+func (s *mentorshipService) Decline(mentorID uuid.UUID, mentorshipID uuid.UUID) (*domain.Mentorship, error) {
+	mentorship, err := s.loadProposal(mentorID, mentorshipID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reassign(mentorship, "declined"); err != nil {
+		return nil, err
+	}
+	return mentorship, nil
+}
+
+// releaseMentor hands every proposed or active mentorship of a mentor to
+// someone else.
+func (s *mentorshipService) releaseMentor(mentorID uuid.UUID, reason string) {
+	var mentorships []domain.Mentorship
+	if err := s.db.Where("mentor_id = ? AND status IN ?", mentorID, []domain.MentorshipStatus{domain.MentorshipStatusProposed, domain.MentorshipStatusActive}).
+		Find(&mentorships).Error; err != nil {
+		log.Printf("Warning: Failed to load mentorships of mentor %s: %v", mentorID, err)
+		return
+	}
+	for i := range mentorships {
+		if err := s.reassign(&mentorships[i], reason); err != nil {
+			log.Printf("Warning: Failed to reassign mentorship %s: %v", mentorships[i].ID, err)
+		}
+	}
+}
+
+// reassign takes a mentorship away from its mentor, who is not proposed for it
+// again, and proposes another. An active mentor leaves the conversation and
+// the revert is told with a system message.
+func (s *mentorshipService) reassign(mentorship *domain.Mentorship, reason string) error {
+	previousID, err := uuid.Parse(mentorship.MentorID.String)
+	if err != nil {
+		return apperror.Internal("Mentorship has no mentor", err)
+	}
+	wasActive := mentorship.Status == domain.MentorshipStatusActive && mentorship.ConversationID.Valid
+	conversationID, _ := uuid.Parse(mentorship.ConversationID.String)
+	content := "Your mentor is no longer available. A new mentor will be proposed."
+
+	var message *domain.Message
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// The mentorship may have been accepted, declined or reassigned
+		// since it was loaded; only the update that still finds it as it
+		// was read wins.
+		updatedAt := now()
+		result := tx.Model(&domain.Mentorship{}).
+			Where("id = ? AND status = ? AND mentor_id = ?", mentorship.ID, mentorship.Status, mentorship.MentorID.String).
+			Updates(map[string]interface{}{
+				"status":      domain.MentorshipStatusRequested,
+				"mentor_id":   nil,
+				"proposed_at": nil,
+				"accepted_at": nil,
+				"updated_at":  updatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperror.New(apperror.CodeConflict, "This mentorship changed in the meantime")
+		}
+		mentorship.Status = domain.MentorshipStatusRequested
+		mentorship.MentorID = sql.NullString{}
+		mentorship.ProposedAt = sql.NullTime{}
+		mentorship.AcceptedAt = sql.NullTime{}
+		mentorship.UpdatedAt = updatedAt
+
+		decline := domain.MentorshipDecline{MentorshipID: mentorship.ID, MentorID: previousID, Reason: reason, CreatedAt: now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&decline).Error; err != nil {
+			return err
+		}
+		if !wasActive {
+			return nil
+		}
+		if err := tx.Model(&domain.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversationID, previousID).
+			Update("left_at", now()).Error; err != nil {
+			return err
+		}
+		var err error
+		message, err = createSystemMessage(tx, conversationID, previousID, content)
+		return err
+	})
+	if err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			return err
+		}
+		return apperror.Internal("Failed to reassign mentorship", err)
+	}
+	log.Printf("Mentorship %s taken from mentor %s (%s)\n", mentorship.ID, previousID, reason)
+
+	if wasActive {
+		event := domain.Event{
+			Type:           domain.EventMentorshipReassigned,
+			ConversationID: conversationID,
+			Data:           map[string]interface{}{"mentorship_id": mentorship.ID.String(), "message_id": message.ID.String(), "content": content},
+		}
+		s.notifier.BroadcastEvent(event)
+		s.notifier.DisconnectParticipant(conversationID, previousID, event)
+	}
+	return s.propose(mentorship)
+}
+
+func (s *mentorshipService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *mentorshipService) sweep() {
+	var lapsed []domain.Mentorship
+	if err := s.db.Where("status = ? AND proposed_at < ?", domain.MentorshipStatusProposed, now().Add(-s.proposalTTL)).Find(&lapsed).Error; err != nil {
+		log.Printf("Warning: Failed to load lapsed mentorship proposals: %v", err)
+	}
+	for i := range lapsed {
+		if err := s.reassign(&lapsed[i], "expired"); err != nil {
+			log.Printf("Warning: Failed to reassign lapsed mentorship %s: %v", lapsed[i].ID, err)
+		}
+	}
+
+	var mentorIDs []string
+	if err := s.db.Model(&domain.Mentorship{}).Where("status = ?", domain.MentorshipStatusActive).Distinct().Pluck("mentor_id", &mentorIDs).Error; err != nil {
+		log.Printf("Warning: Failed to load active mentors: %v", err)
+	}
+	for _, id := range mentorIDs {
+		mentorID, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		inactive, err := s.isInactive(mentorID)
+		if err != nil {
+			log.Printf("Warning: Failed to check activity of mentor %s: %v", mentorID, err)
+			continue
+		}
+		if !inactive {
+			continue
+		}
+		if err := s.db.Model(&domain.MentorProfile{}).Where("user_id = ?", mentorID).
+			Updates(map[string]interface{}{"active": false, "updated_at": now()}).Error; err != nil {
+			log.Printf("Warning: Failed to deactivate mentor %s: %v", mentorID, err)
+			continue
+		}
+		log.Printf("Mentor %s marked inactive after %s without writing to a mentee\n", mentorID, s.inactiveAfter)
+		s.releaseMentor(mentorID, "inactive")
+	}
+
+	s.proposeWaiting()
+}
+
+// isInactive reports whether a mentor has neither accepted a mentorship nor
+// written in any of their mentorship conversations for inactiveAfter.
+func (s *mentorshipService) isInactive(mentorID uuid.UUID) (bool, error) {
+	cutoff := now().Add(-s.inactiveAfter)
+	var recentAccepts int64
+	if err := s.db.Model(&domain.Mentorship{}).
+		Where("mentor_id = ? AND status = ? AND accepted_at >= ?", mentorID, domain.MentorshipStatusActive, cutoff).
+		Count(&recentAccepts).Error; err != nil {
+		return false, err
+	}
+	if recentAccepts > 0 {
+		return false, nil
+	}
+	var message domain.Message
+	err := s.db.
+		Where("sender_id = ? AND created_at >= ?", mentorID, cutoff).
+		Where("conversation_id IN (?)", s.db.Model(&domain.Mentorship{}).Select("conversation_id").Where("mentor_id = ? AND status = ?", mentorID, domain.MentorshipStatusActive)).
+		First(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"github.com/masjids-io/limestone-chat/internal/imaging"
+)
+
+// MessagePayload is the client-controlled part of a message that a message
+// type validates. Metadata is nil when the client sent none, and Attachment is
+// nil when the message does not reference an uploaded file.
+type MessagePayload struct {
+	Content    string
+	MediaURL   string
+	Metadata   map[string]interface{}
+	Attachment *domain.Attachment
+}
+
+type MessageTypeHandler struct {
+	// ServerOnly types, such as system notices, are rejected when sent by a client.
+	ServerOnly bool
+	Validate   func(payload MessagePayload) error
+}
+
+// MessageTypeRegistry holds the supported message types. New types are added
+// by registering a handler; anything unregistered is rejected by SendMessage.
+type MessageTypeRegistry struct {
+	mu       sync.RWMutex
+	handlers map[domain.MessageType]MessageTypeHandler
+}
+
+// NewMessageTypeRegistry returns a registry with the built-in message types.
+func NewMessageTypeRegistry() *MessageTypeRegistry {
+	r := &MessageTypeRegistry{handlers: make(map[domain.MessageType]MessageTypeHandler)}
+	r.Register(domain.MessageTypeText, MessageTypeHandler{Validate: validateTextMessage})
+	r.Register(domain.MessageTypeImage, MessageTypeHandler{Validate: validateMediaMessage(imaging.SupportedTypes, map[string]string{"width": "number", "height": "number"})})
+	r.Register(domain.MessageTypeFile, MessageTypeHandler{Validate: validateMediaMessage([]string{""}, map[string]string{"file_name": "string", "size": "number"})})
+	r.Register(domain.MessageTypeAudio, MessageTypeHandler{Validate: validateMediaMessage(audioMimePrefixes, map[string]string{"duration_seconds": "number"})})
+	r.Register(domain.MessageTypeLocation, MessageTypeHandler{Validate: validateLocationMessage})
+	r.Register(domain.MessageTypeContactCard, MessageTypeHandler{Validate: validateContactCardMessage})
+	r.Register(domain.MessageTypeSystem, MessageTypeHandler{ServerOnly: true, Validate: validateTextMessage})
+	r.Register(domain.MessageTypeSurvey, MessageTypeHandler{ServerOnly: true})
+	return r
+}
+
+// Register adds or replaces the handler for a message type.
+func (r *MessageTypeRegistry) Register(messageType domain.MessageType, handler MessageTypeHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[messageType] = handler
+}
+
+// Validate checks a message sent by a client against its type's handler.
+func (r *MessageTypeRegistry) Validate(messageType string, content string, mediaURL string, metadata []byte, attachment *domain.Attachment) error {
+	r.mu.RLock()
+	handler, ok := r.handlers[domain.MessageType(messageType)]
+	r.mu.RUnlock()
+	if !ok {
+		return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("Unsupported message type %q", messageType))
+	}
+	if handler.ServerOnly {
+		return apperror.New(apperror.CodeForbidden, fmt.Sprintf("Message type %q cannot be sent by clients", messageType))
+	}
+
+	payload := MessagePayload{Content: content, MediaURL: mediaURL, Attachment: attachment}
+	if len(metadata) > 0 && string(metadata) != "null" {
+		if err := json.Unmarshal(metadata, &payload.Metadata); err != nil {
+			return apperror.Wrap(apperror.CodeInvalidRequest, "Metadata must be a JSON object", err)
+		}
+	}
+	if handler.Validate == nil {
+		return nil
+	}
+	return handler.Validate(payload)
+}
+
+func invalidPayload(format string, args ...interface{}) error {
+	return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf(format, args...))
+}
+
+func validateTextMessage(payload MessagePayload) error {
+	if strings.TrimSpace(payload.Content) == "" {
+		return invalidPayload("Content is required")
+	}
+	if payload.MediaURL != "" || payload.Attachment != nil {
+		return invalidPayload("Media is not allowed for text messages")
+	}
+	return nil
+}
+
+// audioMimePrefixes accepts audio/* and the containers http.DetectContentType
+// reports for common voice notes: application/ogg for Ogg/Opus and video/mp4
+// for M4A.
+var audioMimePrefixes = []string{"audio/", "application/ogg", "video/mp4"}
+
+// validateMediaMessage requires an uploaded attachment whose sniffed MIME type
+// starts with one of mimePrefixes, and checks the types of the optional
+// metadata fields listed in fields. Raw media URLs are not accepted.
+func validateMediaMessage(mimePrefixes []string, fields map[string]string) func(payload MessagePayload) error {
+	return func(payload MessagePayload) error {
+		if payload.MediaURL != "" {
+			return invalidPayload("Media URL is not accepted, upload the file and send its attachment_id")
+		}
+		if payload.Attachment == nil {
+			return invalidPayload("Attachment is required")
+		}
+		if !hasAnyPrefix(payload.Attachment.MimeType, mimePrefixes) {
+			return invalidPayload("Attachment of type %s does not match the message type", payload.Attachment.MimeType)
+		}
+		for field, kind := range fields {
+			if err := checkOptionalField(payload.Metadata, field, kind); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLocationMessage(payload MessagePayload) error {
+	if payload.MediaURL != "" || payload.Attachment != nil {
+		return invalidPayload("Media is not allowed for location messages")
+	}
+	latitude, ok := payload.Metadata["latitude"].(float64)
+	if !ok || latitude < -90 || latitude > 90 {
+		return invalidPayload("Metadata latitude must be a number between -90 and 90")
+	}
+	longitude, ok := payload.Metadata["longitude"].(float64)
+	if !ok || longitude < -180 || longitude > 180 {
+		return invalidPayload("Metadata longitude must be a number between -180 and 180")
+	}
+	return nil
+}
+
+func validateContactCardMessage(payload MessagePayload) error {
+	if payload.MediaURL != "" || payload.Attachment != nil {
+		return invalidPayload("Media is not allowed for contact card messages")
+	}
+	name, _ := payload.Metadata["name"].(string)
+	if strings.TrimSpace(name) == "" {
+		return invalidPayload("Metadata name is required for contact cards")
+	}
+	for _, field := range []string{"phone_number", "email", "user_id"} {
+		if err := checkOptionalField(payload.Metadata, field, "string"); err != nil {
+			return err
+		}
+	}
+	if userID, ok := payload.Metadata["user_id"].(string); ok {
+		if _, err := uuid.Parse(userID); err != nil {
+			return invalidPayload("Metadata user_id must be a UUID")
+		}
+	}
+	if payload.Metadata["phone_number"] == nil && payload.Metadata["email"] == nil && payload.Metadata["user_id"] == nil {
+		return invalidPayload("Contact cards need a phone_number, email or user_id")
+	}
+	return nil
+}
+
+func checkOptionalField(metadata map[string]interface{}, field string, kind string) error {
+	value, ok := metadata[field]
+	if !ok || value == nil {
+		return nil
+	}
+	switch kind {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return invalidPayload("Metadata %s must be a string", field)
+		}
+	case "number":
+		if number, ok := value.(float64); !ok || number < 0 {
+			return invalidPayload("Metadata %s must be a non-negative number", field)
+		}
+	}
+	return nil
+}
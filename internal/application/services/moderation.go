@@ -0,0 +1,382 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+// ModerationInput is what filters see of a message before it is stored.
+// Metadata is the decoded metadata object, such as a contact card's fields.
+type ModerationInput struct {
+	SenderID     uuid.UUID
+	Conversation *domain.Conversation
+	Content      string
+	Metadata     map[string]interface{}
+}
+
+// ModerationVerdict is a filter's decision. Content is only used when the
+// action is mask and holds the masked text.
+type ModerationVerdict struct {
+	Action  domain.ModerationAction
+	Reason  string
+	Content string
+}
+
+// ModerationFilter inspects a message. Filters run in the order they were
+// given to the pipeline and each sees the content as masked by earlier ones.
+type ModerationFilter interface {
+	Name() string
+	Check(input ModerationInput) ModerationVerdict
+}
+
+// ModerationResult is the combined outcome of the pipeline: the most severe
+// action, the content to store and one audit record per non-allow verdict.
+type ModerationResult struct {
+	Action    domain.ModerationAction
+	Content   string
+	Decisions []domain.ModerationDecision
+}
+
+type ModerationPipeline struct {
+	filters []ModerationFilter
+}
+
+func NewModerationPipeline(filters ...ModerationFilter) *ModerationPipeline {
+	return &ModerationPipeline{filters: filters}
+}
+
+// Run applies every filter, stopping at the first rejection.
+func (p *ModerationPipeline) Run(input ModerationInput) ModerationResult {
+	result := ModerationResult{Action: domain.ModerationActionAllow, Content: input.Content}
+	if p == nil {
+		return result
+	}
+
+	for _, filter := range p.filters {
+		verdict := filter.Check(ModerationInput{SenderID: input.SenderID, Conversation: input.Conversation, Content: result.Content, Metadata: input.Metadata})
+		if verdict.Action == domain.ModerationActionAllow || verdict.Action == "" {
+			continue
+		}
+
+		result.Decisions = append(result.Decisions, domain.ModerationDecision{
+			ID:              uuid.New(),
+			ConversationID:  input.Conversation.ID,
+			SenderID:        input.SenderID,
+			Filter:          filter.Name(),
+			Action:          verdict.Action,
+			Reason:          verdict.Reason,
+			OriginalContent: input.Content,
+			CreatedAt:       now(),
+		})
+		if verdict.Action.Severity() > result.Action.Severity() {
+			result.Action = verdict.Action
+		}
+		if verdict.Action == domain.ModerationActionMask {
+			result.Content = verdict.Content
+		}
+		if verdict.Action == domain.ModerationActionReject {
+			log.Printf("Message from %s to conversation %s rejected by %s: %s\n", input.SenderID, input.Conversation.ID, filter.Name(), verdict.Reason)
+			break
+		}
+	}
+	return result
+}
+
+// appliesTo reports whether purposes contains purpose; an empty list means
+// every purpose.
+func appliesTo(purposes []domain.ConversationPurpose, purpose domain.ConversationPurpose) bool {
+	if len(purposes) == 0 {
+		return true
+	}
+	for _, p := range purposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+func maskRunes(s string) string {
+	return strings.Repeat("*", len([]rune(s)))
+}
+
+// metadataStrings returns the string values of message metadata, nested ones
+// included. IDs, such as a contact card's user_id, are not text and are left
+// out.
+func metadataStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if _, err := uuid.Parse(v); err == nil {
+			return nil
+		}
+		return []string{v}
+	case map[string]interface{}:
+		var values []string
+		for _, field := range v {
+			values = append(values, metadataStrings(field)...)
+		}
+		return values
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, metadataStrings(item)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// metadataAction is what a filter does when metadata matches. Metadata cannot
+// be masked, so a masking filter rejects the message instead.
+func metadataAction(action domain.ModerationAction) domain.ModerationAction {
+	if action == domain.ModerationActionMask {
+		return domain.ModerationActionReject
+	}
+	return action
+}
+
+// ProfanityFilter matches whole words from per-language word lists.
+type ProfanityFilter struct {
+	Action    domain.ModerationAction
+	wordLists map[string]map[string]bool
+}
+
+// LoadProfanityFilter reads one word list per language from dir, named after
+// the language, e.g. en.txt or id.txt, with one word per line and # comments.
+func LoadProfanityFilter(dir string, action domain.ModerationAction) (*ProfanityFilter, error) {
+	filter := &ProfanityFilter{Action: action, wordLists: make(map[string]map[string]bool)}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list word lists in %s: %w", dir, err)
+	}
+	for _, path := range paths {
+		language := strings.TrimSuffix(filepath.Base(path), ".txt")
+		words, err := readWordList(path)
+		if err != nil {
+			return nil, err
+		}
+		filter.AddWords(language, words...)
+	}
+	return filter, nil
+}
+
+func readWordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open word list %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read word list %s: %w", path, err)
+	}
+	return words, nil
+}
+
+func (f *ProfanityFilter) AddWords(language string, words ...string) {
+	if f.wordLists == nil {
+		f.wordLists = make(map[string]map[string]bool)
+	}
+	if f.wordLists[language] == nil {
+		f.wordLists[language] = make(map[string]bool)
+	}
+	for _, word := range words {
+		f.wordLists[language][strings.ToLower(word)] = true
+	}
+}
+
+func (f *ProfanityFilter) Name() string {
+	return "profanity"
+}
+
+func (f *ProfanityFilter) Check(input ModerationInput) ModerationVerdict {
+	var masked strings.Builder
+	var word []rune
+	var languages []string
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		text := string(word)
+		if language, ok := f.match(text); ok {
+			languages = append(languages, language)
+			text = maskRunes(text)
+		}
+		masked.WriteString(text)
+		word = word[:0]
+	}
+	for _, r := range input.Content {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '\'' {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		masked.WriteRune(r)
+	}
+	flush()
+
+	if len(languages) == 0 {
+		return ModerationVerdict{Action: domain.ModerationActionAllow}
+	}
+	return ModerationVerdict{
+		Action:  f.Action,
+		Reason:  fmt.Sprintf("profanity (%s)", strings.Join(uniqueStrings(languages), ", ")),
+		Content: masked.String(),
+	}
+}
+
+func (f *ProfanityFilter) match(word string) (string, bool) {
+	lower := strings.ToLower(word)
+	for language, words := range f.wordLists {
+		if words[lower] {
+			return language, true
+		}
+	}
+	return "", false
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b((?:https?://|www\.)[^\s]+|[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|me|id|co|info|biz|ly|gg|app)(?:/[^\s]*)?)`)
+
+// LinkFilter blocks links except to allowed domains.
+type LinkFilter struct {
+	Action         domain.ModerationAction
+	Purposes       []domain.ConversationPurpose
+	AllowedDomains []string
+}
+
+func (f *LinkFilter) Name() string {
+	return "link"
+}
+
+func (f *LinkFilter) Check(input ModerationInput) ModerationVerdict {
+	if !appliesTo(f.Purposes, input.Conversation.Purpose) {
+		return ModerationVerdict{Action: domain.ModerationActionAllow}
+	}
+	for _, value := range metadataStrings(input.Metadata) {
+		if _, found := f.mask(value); found {
+			return ModerationVerdict{Action: metadataAction(f.Action), Reason: "links are not allowed in this conversation"}
+		}
+	}
+	masked, found := f.mask(input.Content)
+	if !found {
+		return ModerationVerdict{Action: domain.ModerationActionAllow}
+	}
+	return ModerationVerdict{Action: f.Action, Reason: "links are not allowed in this conversation", Content: masked}
+}
+
+// mask masks the links in s that are not to allowed domains and reports
+// whether there were any.
+func (f *LinkFilter) mask(s string) (string, bool) {
+	masked := s
+	found := false
+	for _, loc := range linkPattern.FindAllStringIndex(s, -1) {
+		link := s[loc[0]:loc[1]]
+		// The domain part of an email address is not a link.
+		if loc[0] > 0 && s[loc[0]-1] == '@' || f.isAllowed(link) {
+			continue
+		}
+		found = true
+		masked = strings.Replace(masked, link, maskRunes(link), 1)
+	}
+	return masked, found
+}
+
+func (f *LinkFilter) isAllowed(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range f.AllowedDomains {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s().-]{7,}\d`)
+)
+
+// ContactDetailsFilter withholds phone numbers and email addresses from
+// conversations that have not been approved yet, so prospective spouses
+// cannot move the conversation off the platform before approval.
+type ContactDetailsFilter struct {
+	Action   domain.ModerationAction
+	Purposes []domain.ConversationPurpose
+}
+
+func (f *ContactDetailsFilter) Name() string {
+	return "contact_details"
+}
+
+func (f *ContactDetailsFilter) Check(input ModerationInput) ModerationVerdict {
+	if !appliesTo(f.Purposes, input.Conversation.Purpose) || input.Conversation.ApprovedAt.Valid {
+		return ModerationVerdict{Action: domain.ModerationActionAllow}
+	}
+	// A contact card's phone_number and email are caught here too.
+	for _, value := range metadataStrings(input.Metadata) {
+		if maskContactDetails(value) != value {
+			return ModerationVerdict{Action: metadataAction(f.Action), Reason: "contact details before approval"}
+		}
+	}
+	masked := maskContactDetails(input.Content)
+	if masked == input.Content {
+		return ModerationVerdict{Action: domain.ModerationActionAllow}
+	}
+	return ModerationVerdict{Action: f.Action, Reason: "contact details before approval", Content: masked}
+}
+
+func maskContactDetails(s string) string {
+	masked := emailPattern.ReplaceAllStringFunc(s, maskRunes)
+	return phonePattern.ReplaceAllStringFunc(masked, func(m string) string {
+		// Nine digits keeps dates such as 2025-06-22 from being masked.
+		digits := 0
+		for _, r := range m {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		if digits < 9 {
+			return m
+		}
+		return maskRunes(m)
+	})
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationAllowed decides whether a notification reaches its recipient at
+// a time. setting is the recipient's own, nil when never saved, and
+// participant their row in the conversation. A mute at either level silences
+// everything; otherwise the conversation's level applies, then the user's,
+// then all.
+func NotificationAllowed(setting *domain.NotificationSetting, participant *domain.ConversationParticipant, notificationType domain.NotificationType, at time.Time) bool {
+	level := domain.NotificationLevelAll
+	if setting != nil {
+		if setting.MutedUntil.Valid && at.Before(setting.MutedUntil.Time) {
+			return false
+		}
+		if setting.Level != "" {
+			level = setting.Level
+		}
+	}
+	if participant != nil {
+		if participant.MutedUntil.Valid && at.Before(participant.MutedUntil.Time) {
+			return false
+		}
+		if participant.NotificationLevel != "" {
+			level = participant.NotificationLevel
+		}
+	}
+	return level.Allows(notificationType)
+}
+
+// notificationAllowed loads the recipient's settings and applies
+// NotificationAllowed. Recipients who left the conversation get nothing.
+func notificationAllowed(db *gorm.DB, recipientID uuid.UUID, conversationID uuid.UUID, notificationType domain.NotificationType, at time.Time) (bool, error) {
+	var participant domain.ConversationParticipant
+	err := db.Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, recipientID).First(&participant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var setting domain.NotificationSetting
+	err = db.Where("user_id = ?", recipientID).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotificationAllowed(nil, &participant, notificationType, at), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return NotificationAllowed(&setting, &participant, notificationType, at), nil
+}
+
+// validateMute rejects mutes that already ended.
+func validateMute(mutedUntil *time.Time) (sql.NullTime, error) {
+	if mutedUntil == nil {
+		return sql.NullTime{}, nil
+	}
+	if !mutedUntil.After(now()) {
+		return sql.NullTime{}, apperror.New(apperror.CodeInvalidRequest, "muted_until must be in the future")
+	}
+	return sql.NullTime{Time: *mutedUntil, Valid: true}, nil
+}
+
+func (s *notificationService) GetSettings(userID uuid.UUID) (*domain.NotificationSetting, error) {
+	setting := domain.NotificationSetting{UserID: userID, Level: domain.NotificationLevelAll}
+	err := s.db.Where("user_id = ?", userID).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.Internal("Failed to load notification settings", err)
+	}
+	return &setting, nil
+}
+
+func (s *notificationService) UpdateSettings(userID uuid.UUID, level domain.NotificationLevel, mutedUntil *time.Time) (*domain.NotificationSetting, error) {
+	if !level.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "level must be all, mentions or none")
+	}
+	muted, err := validateMute(mutedUntil)
+	if err != nil {
+		return nil, err
+	}
+	setting := domain.NotificationSetting{UserID: userID, Level: level, MutedUntil: muted, CreatedAt: now(), UpdatedAt: now()}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"level", "muted_until", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to save notification settings", err)
+	}
+	log.Printf("Notification level of user %s set to %s\n", userID, level)
+	return &setting, nil
+}
+
+func (s *notificationService) UpdateConversationSettings(userID uuid.UUID, conversationID uuid.UUID, level domain.NotificationLevel, mutedUntil *time.Time) (*domain.ConversationParticipant, error) {
+	if level != "" && !level.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "level must be all, mentions, none or empty for your default")
+	}
+	muted, err := validateMute(mutedUntil)
+	if err != nil {
+		return nil, err
+	}
+	participant, err := requireParticipant(s.db, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&domain.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Updates(map[string]interface{}{"notification_level": level, "muted_until": muted}).Error; err != nil {
+		return nil, apperror.Internal("Failed to save conversation notification settings", err)
+	}
+	participant.NotificationLevel = level
+	participant.MutedUntil = muted
+	return participant, nil
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	notificationBatchSize = 100
+	// notificationClaimTimeout is how long a claimed notification is hidden
+	// from other dispatchers after its claim was last extended. One that is
+	// still pending afterwards, because its dispatcher stopped, is picked up
+	// again.
+	notificationClaimTimeout = 2 * time.Minute
+	notificationPreviewRunes = 120
+	maxNotificationErrorSize = 1000
+	// notificationRetention is how long delivered and skipped notifications
+	// are kept. Dead ones are kept until retried.
+	notificationRetention = 7 * 24 * time.Hour
+)
+
+// ErrNotificationRejected is wrapped by senders when the gateway refused a
+// notification in a way retrying cannot fix. Such notifications are
+// dead-lettered right away.
+var ErrNotificationRejected = errors.New("notification rejected by gateway")
+
+// NotificationPayload is what the push gateway receives for a notification.
+type NotificationPayload struct {
+	ID             uuid.UUID                  `json:"id"`
+	Type           domain.NotificationType    `json:"type"`
+	RecipientID    uuid.UUID                  `json:"recipient_id"`
+	ConversationID uuid.UUID                  `json:"conversation_id"`
+	Purpose        domain.ConversationPurpose `json:"purpose"`
+	MessageID      uuid.UUID                  `json:"message_id"`
+	SenderID       uuid.UUID                  `json:"sender_id"`
+	MessageType    string                     `json:"message_type"`
+	Preview        string                     `json:"preview"`
+	SentAt         time.Time                  `json:"sent_at"`
+}
+
+// NotificationSender delivers notifications to the push gateway. The webhook
+// sender implements it.
+type NotificationSender interface {
+	Send(ctx context.Context, payload NotificationPayload) error
+}
+
+// Presence tells whether a user has a live connection to a conversation. The
+// WebSocket hub implements it.
+type Presence interface {
+	IsConnected(conversationID uuid.UUID, userID uuid.UUID) bool
+}
+
+// RetryPolicy says how often and how long failed notifications are retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Backoff returns the delay after the given failed attempt, counting from 1:
+// BaseDelay, doubled after each further failure, up to MaxDelay.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// NotificationPreview shortens message content for a notification. Messages
+// without text are described by their type.
+func NotificationPreview(message *domain.Message) string {
+	if message.Content == "" {
+		return "Sent a " + message.MessageType
+	}
+	if utf8.RuneCountInString(message.Content) <= notificationPreviewRunes {
+		return message.Content
+	}
+	return string([]rune(message.Content)[:notificationPreviewRunes-1]) + "…"
+}
+
+// enqueueMessageNotifications writes a notification for every current
+// participant of the message's conversation but its sender, of type mention
+// for those it mentions. It runs in the transaction that stores the message,
+// so a stored message always has its notifications.
+func enqueueMessageNotifications(tx *gorm.DB, message *domain.Message, mentioned map[uuid.UUID]bool) error {
+	var recipients []uuid.UUID
+	if err := tx.Model(&domain.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id <> ? AND left_at IS NULL", message.ConversationID, message.SenderID).
+		Pluck("user_id", &recipients).Error; err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	notifications := make([]domain.OutboxNotification, len(recipients))
+	for i, recipientID := range recipients {
+		notificationType := domain.NotificationTypeMessage
+		if mentioned[recipientID] {
+			notificationType = domain.NotificationTypeMention
+		}
+		notifications[i] = domain.OutboxNotification{
+			ID:             uuid.New(),
+			Type:           notificationType,
+			RecipientID:    recipientID,
+			ConversationID: message.ConversationID,
+			MessageID:      message.ID,
+			Status:         domain.NotificationStatusPending,
+			NextAttemptAt:  message.CreatedAt,
+			CreatedAt:      now(),
+			UpdatedAt:      now(),
+		}
+	}
+	return tx.Create(&notifications).Error
+}
+
+type NotificationService interface {
+	// GetSettings returns the user's notification settings, level all when
+	// never saved.
+	GetSettings(userID uuid.UUID) (*domain.NotificationSetting, error)
+	UpdateSettings(userID uuid.UUID, level domain.NotificationLevel, mutedUntil *time.Time) (*domain.NotificationSetting, error)
+	// UpdateConversationSettings sets the user's level for one conversation,
+	// empty for their default, and mutes it until mutedUntil when not nil.
+	UpdateConversationSettings(userID uuid.UUID, conversationID uuid.UUID, level domain.NotificationLevel, mutedUntil *time.Time) (*domain.ConversationParticipant, error)
+	// DeadLetters lists notifications that could not be delivered, newest
+	// first, for supervisors.
+	DeadLetters(actorID uuid.UUID, limit, offset int) ([]domain.OutboxNotification, error)
+	// Retry puts a dead notification back in the outbox with fresh attempts.
+	Retry(actorID uuid.UUID, notificationID uuid.UUID) (*domain.OutboxNotification, error)
+	// Run delivers due notifications to recipients who are not connected to
+	// the conversation, and purges old finished ones, until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type notificationService struct {
+	db         *gorm.DB
+	sender     NotificationSender
+	presence   Presence
+	quietHours QuietHoursService
+	retry      RetryPolicy
+}
+
+// NewNotificationService creates the outbox dispatcher. sender may be nil
+// when no push gateway is configured; notifications are then skipped.
+func NewNotificationService(db *gorm.DB, sender NotificationSender, presence Presence, quietHours QuietHoursService, retry RetryPolicy) NotificationService {
+	return &notificationService{db: db, sender: sender, presence: presence, quietHours: quietHours, retry: retry}
+}
+
+func (s *notificationService) DeadLetters(actorID uuid.UUID, limit, offset int) ([]domain.OutboxNotification, error) {
+	if err := requireStaffRole(s.db, actorID, domain.StaffRoleSupervisor); err != nil {
+		return nil, err
+	}
+	var notifications []domain.OutboxNotification
+	if err := s.db.Where("status = ?", domain.NotificationStatusDead).
+		Order("updated_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&notifications).Error; err != nil {
+		return nil, apperror.Internal("Failed to load dead notifications", err)
+	}
+	return notifications, nil
+}
+
+func (s *notificationService) Retry(actorID uuid.UUID, notificationID uuid.UUID) (*domain.OutboxNotification, error) {
+	if err := requireStaffRole(s.db, actorID, domain.StaffRoleSupervisor); err != nil {
+		return nil, err
+	}
+	var notification domain.OutboxNotification
+	if err := s.db.First(&notification, "id = ?", notificationID).Error; err != nil {
+		return nil, notFoundOrInternal("Notification not found", err)
+	}
+	if notification.Status != domain.NotificationStatusDead {
+		return nil, apperror.New(apperror.CodeConflict, "Only dead notifications can be retried")
+	}
+	notification.Status = domain.NotificationStatusPending
+	notification.Attempts = 0
+	notification.NextAttemptAt = now()
+	if err := s.db.Model(&notification).Updates(map[string]interface{}{
+		"status": notification.Status, "attempts": 0, "next_attempt_at": notification.NextAttemptAt,
+	}).Error; err != nil {
+		return nil, apperror.Internal("Failed to retry notification", err)
+	}
+	log.Printf("Notification %s requeued by %s\n", notification.ID, actorID)
+	return &notification, nil
+}
+
+func (s *notificationService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.dispatch(ctx)
+			s.purge()
+		}
+	}
+}
+
+func (s *notificationService) purge() {
+	err := s.db.Where("status IN ? AND updated_at < ?",
+		[]domain.NotificationStatus{domain.NotificationStatusDelivered, domain.NotificationStatusSkipped}, now().Add(-notificationRetention)).
+		Delete(&domain.OutboxNotification{}).Error
+	if err != nil {
+		log.Printf("Warning: Failed to purge old notifications: %v", err)
+	}
+}
+
+// dispatch delivers due notifications in batches until none are left.
+func (s *notificationService) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		notifications, err := s.claim()
+		if err != nil {
+			log.Printf("Warning: Failed to claim notifications: %v", err)
+			return
+		}
+		for i := range notifications {
+			// A send can take as long as the gateway's timeout, so the rest
+			// of the batch is claimed afresh before each one.
+			if err := s.extendClaim(s.db, notifications[i:]); err != nil {
+				log.Printf("Warning: Failed to extend the claim on notifications: %v", err)
+				return
+			}
+			s.deliver(ctx, &notifications[i])
+		}
+		if len(notifications) < notificationBatchSize {
+			return
+		}
+	}
+}
+
+// claim picks due notifications and moves their next attempt past the claim
+// timeout, so concurrent dispatchers do not pick them as well.
+func (s *notificationService) claim() ([]domain.OutboxNotification, error) {
+	var notifications []domain.OutboxNotification
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", domain.NotificationStatusPending, now()).
+			Order("next_attempt_at ASC").
+			Limit(notificationBatchSize).
+			Find(&notifications).Error; err != nil {
+			return err
+		}
+		if len(notifications) == 0 {
+			return nil
+		}
+		return s.extendClaim(tx, notifications)
+	})
+	return notifications, err
+}
+
+// extendClaim hides pending notifications from other dispatchers for another
+// claim timeout.
+func (s *notificationService) extendClaim(db *gorm.DB, notifications []domain.OutboxNotification) error {
+	ids := make([]uuid.UUID, len(notifications))
+	for i := range notifications {
+		ids[i] = notifications[i].ID
+	}
+	return db.Model(&domain.OutboxNotification{}).Where("id IN ? AND status = ?", ids, domain.NotificationStatusPending).
+		Update("next_attempt_at", now().Add(notificationClaimTimeout)).Error
+}
+
+func (s *notificationService) deliver(ctx context.Context, notification *domain.OutboxNotification) {
+	if s.presence.IsConnected(notification.ConversationID, notification.RecipientID) {
+		s.finish(notification, domain.NotificationStatusSkipped, "")
+		return
+	}
+	allowed, err := notificationAllowed(s.db, notification.RecipientID, notification.ConversationID, notification.Type, now())
+	if err != nil {
+		s.fail(notification, err)
+		return
+	}
+	if !allowed {
+		s.finish(notification, domain.NotificationStatusSkipped, "muted by recipient")
+		return
+	}
+	until, err := s.quietHours.DeferUntil(notification.RecipientID, now())
+	if err != nil {
+		s.fail(notification, err)
+		return
+	}
+	if until.After(now()) {
+		s.update(notification, map[string]interface{}{"next_attempt_at": until})
+		return
+	}
+	if s.sender == nil {
+		s.finish(notification, domain.NotificationStatusSkipped, "no push gateway configured")
+		return
+	}
+
+	var message domain.Message
+	if err := s.db.First(&message, "id = ?", notification.MessageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.finish(notification, domain.NotificationStatusSkipped, "message was deleted")
+			return
+		}
+		s.fail(notification, err)
+		return
+	}
+	var conversation domain.Conversation
+	if err := s.db.First(&conversation, "id = ?", notification.ConversationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.finish(notification, domain.NotificationStatusSkipped, "conversation was deleted")
+			return
+		}
+		s.fail(notification, err)
+		return
+	}
+
+	err = s.sender.Send(ctx, NotificationPayload{
+		ID:             notification.ID,
+		Type:           notification.Type,
+		RecipientID:    notification.RecipientID,
+		ConversationID: notification.ConversationID,
+		Purpose:        conversation.Purpose,
+		MessageID:      message.ID,
+		SenderID:       message.SenderID,
+		MessageType:    message.MessageType,
+		Preview:        NotificationPreview(&message),
+		SentAt:         message.CreatedAt,
+	})
+	if err != nil {
+		s.fail(notification, err)
+		return
+	}
+	s.finish(notification, domain.NotificationStatusDelivered, "")
+}
+
+func (s *notificationService) finish(notification *domain.OutboxNotification, status domain.NotificationStatus, reason string) {
+	updates := map[string]interface{}{"status": status, "last_error": reason}
+	if status == domain.NotificationStatusDelivered {
+		updates["delivered_at"] = now()
+	}
+	s.update(notification, updates)
+}
+
+// fail records a failed attempt, scheduling the next one after the backoff
+// or dead-lettering the notification once attempts run out.
+func (s *notificationService) fail(notification *domain.OutboxNotification, cause error) {
+	attempts := notification.Attempts + 1
+	reason := cause.Error()
+	if len(reason) > maxNotificationErrorSize {
+		reason = reason[:maxNotificationErrorSize]
+	}
+	updates := map[string]interface{}{"attempts": attempts, "last_error": reason}
+	if errors.Is(cause, ErrNotificationRejected) || attempts >= s.retry.MaxAttempts {
+		updates["status"] = domain.NotificationStatusDead
+		log.Printf("Notification %s to %s dead after %d attempts: %v\n", notification.ID, notification.RecipientID, attempts, cause)
+	} else {
+		updates["next_attempt_at"] = now().Add(s.retry.Backoff(attempts))
+	}
+	s.update(notification, updates)
+}
+
+func (s *notificationService) update(notification *domain.OutboxNotification, updates map[string]interface{}) {
+	if err := s.db.Model(&domain.OutboxNotification{}).Where("id = ?", notification.ID).Updates(updates).Error; err != nil {
+		log.Printf("Warning: Failed to update notification %s: %v", notification.ID, err)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxPinnedMessages bounds the pins of one conversation, so the pinned list
+// stays short enough to read at a glance.
+const MaxPinnedMessages = 10
+
+// CheckPin decides whether an actor may pin and unpin messages. Staff can in
+// any conversation they may act on, and the creator of a group conversation
+// can in theirs; observers never can.
+func CheckPin(staff bool, creator bool, conversationType domain.ConversationType, role string) error {
+	if role == domain.ParticipantRoleObserver {
+		return apperror.New(apperror.CodeForbidden, "Observers cannot pin messages")
+	}
+	if staff || (creator && conversationType == domain.ConversationTypeGroup) {
+		return nil
+	}
+	return apperror.New(apperror.CodeForbidden, "Only staff can pin messages in this conversation")
+}
+
+type PinService interface {
+	// Pin pins a message, or returns its pin when it is already pinned.
+	Pin(actorID uuid.UUID, conversationID uuid.UUID, messageID uuid.UUID) (*domain.PinnedMessage, error)
+	Unpin(actorID uuid.UUID, conversationID uuid.UUID, messageID uuid.UUID) error
+	// List returns a conversation's pinned messages, most recently pinned
+	// first.
+	List(userID uuid.UUID, conversationID uuid.UUID) ([]domain.PinnedMessage, error)
+}
+
+type pinService struct {
+	db       *gorm.DB
+	notifier LiveNotifier
+}
+
+func NewPinService(db *gorm.DB, notifier LiveNotifier) PinService {
+	return &pinService{db: db, notifier: notifier}
+}
+
+// participantRole returns the user's role in the conversation, or an empty
+// role for supervisors and moderators who are not part of it.
+func (s *pinService) participantRole(userID uuid.UUID, conversationID uuid.UUID) (string, error) {
+	participant, err := requireParticipant(s.db, conversationID, userID)
+	if err == nil {
+		return participant.Role, nil
+	}
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperror.CodeForbidden {
+		return "", err
+	}
+	if err := requireOversight(s.db, userID); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+func (s *pinService) authorize(actorID uuid.UUID, conversationID uuid.UUID) error {
+	var conversation domain.Conversation
+	if err := s.db.First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return notFoundOrInternal("Conversation not found", err)
+	}
+	role, err := s.participantRole(actorID, conversationID)
+	if err != nil {
+		return err
+	}
+	staff, err := isStaff(s.db, actorID)
+	if err != nil {
+		return err
+	}
+	return CheckPin(staff, conversation.CreatorID == actorID, conversation.Type, role)
+}
+
+func (s *pinService) Pin(actorID uuid.UUID, conversationID uuid.UUID, messageID uuid.UUID) (*domain.PinnedMessage, error) {
+	if err := s.authorize(actorID, conversationID); err != nil {
+		return nil, err
+	}
+	var message domain.Message
+	if err := s.db.Where("id = ? AND conversation_id = ?", messageID, conversationID).First(&message).Error; err != nil {
+		return nil, notFoundOrInternal("Message not found in this conversation", err)
+	}
+
+	pin := domain.PinnedMessage{ConversationID: conversationID, MessageID: messageID, PinnedBy: actorID, PinnedAt: now()}
+	var alreadyPinned bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the conversation serialises pins, so two at once cannot
+		// both take the last free place.
+		var locked domain.Conversation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", conversationID).Error; err != nil {
+			return apperror.Internal("Failed to lock conversation", err)
+		}
+		var existing []domain.PinnedMessage
+		if err := tx.Where("conversation_id = ? AND message_id = ?", conversationID, messageID).Limit(1).Find(&existing).Error; err != nil {
+			return apperror.Internal("Failed to check pins", err)
+		}
+		if len(existing) > 0 {
+			pin, alreadyPinned = existing[0], true
+			return nil
+		}
+		var pinned int64
+		if err := tx.Table("pinned_messages pm").
+			Joins("JOIN messages m ON m.id = pm.message_id").
+			Where("pm.conversation_id = ? AND m.deleted_at IS NULL", conversationID).
+			Count(&pinned).Error; err != nil {
+			return apperror.Internal("Failed to count pins", err)
+		}
+		if pinned >= MaxPinnedMessages {
+			return apperror.New(apperror.CodeConflict, fmt.Sprintf("A conversation can have at most %d pinned messages", MaxPinnedMessages))
+		}
+		if err := tx.Create(&pin).Error; err != nil {
+			return apperror.Internal("Failed to pin message", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	pin.Message = &message
+	if alreadyPinned {
+		return &pin, nil
+	}
+
+	log.Printf("Message %s pinned in conversation %s by %s\n", messageID, conversationID, actorID)
+	s.notifier.BroadcastEvent(domain.Event{
+		Type:           domain.EventMessagePinned,
+		ConversationID: conversationID,
+		Data:           map[string]interface{}{"message_id": messageID.String(), "pinned_by": actorID.String()},
+	})
+	return &pin, nil
+}
+
+func (s *pinService) Unpin(actorID uuid.UUID, conversationID uuid.UUID, messageID uuid.UUID) error {
+	if err := s.authorize(actorID, conversationID); err != nil {
+		return err
+	}
+	result := s.db.Where("conversation_id = ? AND message_id = ?", conversationID, messageID).Delete(&domain.PinnedMessage{})
+	if result.Error != nil {
+		return apperror.Internal("Failed to unpin message", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperror.New(apperror.CodeNotFound, "The message is not pinned")
+	}
+
+	log.Printf("Message %s unpinned in conversation %s by %s\n", messageID, conversationID, actorID)
+	s.notifier.BroadcastEvent(domain.Event{
+		Type:           domain.EventMessageUnpinned,
+		ConversationID: conversationID,
+		Data:           map[string]interface{}{"message_id": messageID.String(), "unpinned_by": actorID.String()},
+	})
+	return nil
+}
+
+// List leaves out pins of messages that were deleted since.
+func (s *pinService) List(userID uuid.UUID, conversationID uuid.UUID) ([]domain.PinnedMessage, error) {
+	if _, err := s.participantRole(userID, conversationID); err != nil {
+		return nil, err
+	}
+	var pins []domain.PinnedMessage
+	if err := s.db.Where("conversation_id = ?", conversationID).Order("pinned_at DESC").Find(&pins).Error; err != nil {
+		return nil, apperror.Internal("Failed to list pinned messages", err)
+	}
+	if len(pins) == 0 {
+		return pins, nil
+	}
+	messageIDs := make([]uuid.UUID, len(pins))
+	for i, pin := range pins {
+		messageIDs[i] = pin.MessageID
+	}
+	var messages []domain.Message
+	if err := s.db.Where("id IN ?", messageIDs).Find(&messages).Error; err != nil {
+		return nil, apperror.Internal("Failed to load pinned messages", err)
+	}
+	byID := make(map[uuid.UUID]*domain.Message, len(messages))
+	for i := range messages {
+		byID[messages[i].ID] = &messages[i]
+	}
+	visible := pins[:0]
+	for _, pin := range pins {
+		if message, ok := byID[pin.MessageID]; ok {
+			pin.Message = message
+			visible = append(visible, pin)
+		}
+	}
+	return visible, nil
+}
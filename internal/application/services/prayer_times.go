@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+// riseSetAngle is the sun's depression at sunrise and sunset, allowing for
+// refraction and the sun's radius.
+const riseSetAngle = 0.833
+
+// prayerMethodParams are the angles of a calculation method. Methods with
+// IshaMinutes set Isha that many minutes after Maghrib instead of by angle.
+type prayerMethodParams struct {
+	FajrAngle   float64
+	IshaAngle   float64
+	IshaMinutes float64
+}
+
+var prayerMethods = map[domain.PrayerMethod]prayerMethodParams{
+	domain.PrayerMethodMWL:     {FajrAngle: 18, IshaAngle: 17},
+	domain.PrayerMethodISNA:    {FajrAngle: 15, IshaAngle: 15},
+	domain.PrayerMethodEgypt:   {FajrAngle: 19.5, IshaAngle: 17.5},
+	domain.PrayerMethodMakkah:  {FajrAngle: 18.5, IshaMinutes: 90},
+	domain.PrayerMethodKarachi: {FajrAngle: 18, IshaAngle: 18},
+	domain.PrayerMethodKemenag: {FajrAngle: 20, IshaAngle: 18},
+}
+
+// Coordinates is a position on Earth in decimal degrees.
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// IsValid reports whether the coordinates are within range.
+func (c Coordinates) IsValid() bool {
+	return c.Latitude >= -90 && c.Latitude <= 90 && c.Longitude >= -180 && c.Longitude <= 180
+}
+
+// PrayerTimes are the times of one day's prayers, in UTC.
+type PrayerTimes struct {
+	Fajr    time.Time `json:"fajr"`
+	Sunrise time.Time `json:"sunrise"`
+	Dhuhr   time.Time `json:"dhuhr"`
+	Asr     time.Time `json:"asr"`
+	Maghrib time.Time `json:"maghrib"`
+	Isha    time.Time `json:"isha"`
+}
+
+// Of returns the time of one prayer.
+func (p PrayerTimes) Of(prayer domain.Prayer) time.Time {
+	switch prayer {
+	case domain.PrayerFajr:
+		return p.Fajr
+	case domain.PrayerDhuhr:
+		return p.Dhuhr
+	case domain.PrayerAsr:
+		return p.Asr
+	case domain.PrayerMaghrib:
+		return p.Maghrib
+	case domain.PrayerIsha:
+		return p.Isha
+	}
+	return time.Time{}
+}
+
+// CalculatePrayerTimes calculates the prayer times of a calendar day at the
+// given coordinates from the sun's position, with no external service. Only
+// the year, month and day of date are used. At high latitudes, where the sun
+// does not reach the Fajr or Isha angle, those times are moved to the same
+// fraction of the night as the angle is of 60°. It fails where the sun does
+// not rise or set that day.
+func CalculatePrayerTimes(date time.Time, at Coordinates, method domain.PrayerMethod, school domain.AsrSchool) (PrayerTimes, error) {
+	params, ok := prayerMethods[method]
+	if !ok {
+		return PrayerTimes{}, fmt.Errorf("unknown prayer method %q", method)
+	}
+	if !school.IsValid() {
+		return PrayerTimes{}, fmt.Errorf("unknown asr school %q", school)
+	}
+	if !at.IsValid() {
+		return PrayerTimes{}, fmt.Errorf("invalid coordinates %v, %v", at.Latitude, at.Longitude)
+	}
+	year, month, day := date.Date()
+	c := solarCalculator{
+		jd:       julianDate(year, int(month), day) - at.Longitude/(15*24),
+		latitude: at.Latitude,
+	}
+
+	// Each time is estimated from the sun's position at its usual hour, as
+	// a fraction of the day.
+	sunrise := c.sunAngleTime(riseSetAngle, 6.0/24, true)
+	sunset := c.sunAngleTime(riseSetAngle, 18.0/24, false)
+	if math.IsNaN(sunrise) || math.IsNaN(sunset) {
+		return PrayerTimes{}, fmt.Errorf("the sun does not rise or set at latitude %v on %s", at.Latitude, date.Format(time.DateOnly))
+	}
+	fajr := c.sunAngleTime(params.FajrAngle, 5.0/24, true)
+	dhuhr := c.midDay(12.0 / 24)
+	asrFactor := 1.0
+	if school == domain.AsrSchoolHanafi {
+		asrFactor = 2
+	}
+	asr := c.asrTime(asrFactor, 13.0/24)
+	isha := sunset + params.IshaMinutes/60
+	if params.IshaMinutes == 0 {
+		isha = c.sunAngleTime(params.IshaAngle, 18.0/24, false)
+	}
+
+	night := fixHour(sunrise - sunset)
+	if limit := params.FajrAngle / 60 * night; math.IsNaN(fajr) || sunrise-fajr > limit {
+		fajr = sunrise - limit
+	}
+	if params.IshaMinutes == 0 {
+		if limit := params.IshaAngle / 60 * night; math.IsNaN(isha) || isha-sunset > limit {
+			isha = sunset + limit
+		}
+	}
+
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	toUTC := func(hours float64) time.Time {
+		hours -= at.Longitude / 15
+		return midnight.Add(time.Duration(hours * float64(time.Hour))).Round(time.Minute)
+	}
+	return PrayerTimes{
+		Fajr:    toUTC(fajr),
+		Sunrise: toUTC(sunrise),
+		Dhuhr:   toUTC(dhuhr),
+		Asr:     toUTC(asr),
+		Maghrib: toUTC(sunset),
+		Isha:    toUTC(isha),
+	}, nil
+}
+
+// solarCalculator finds when the sun reaches an angle on one day. Times are
+// hours of local mean solar time.
+type solarCalculator struct {
+	jd       float64
+	latitude float64
+}
+
+// sunPosition returns the sun's declination and the equation of time, in
+// hours, at a Julian date.
+func sunPosition(jd float64) (declination, equationOfTime float64) {
+	d := jd - 2451545.0
+	g := fixAngle(357.529 + 0.98560028*d)
+	q := fixAngle(280.459 + 0.98564736*d)
+	l := fixAngle(q + 1.915*dsin(g) + 0.020*dsin(2*g))
+	e := 23.439 - 0.00000036*d
+	rightAscension := darctan2(dcos(e)*dsin(l), dcos(l)) / 15
+	return darcsin(dsin(e) * dsin(l)), q/15 - fixHour(rightAscension)
+}
+
+// midDay returns solar noon near the fraction of day t.
+func (c solarCalculator) midDay(t float64) float64 {
+	_, equationOfTime := sunPosition(c.jd + t)
+	return fixHour(12 - equationOfTime)
+}
+
+// sunAngleTime returns when the sun is angle degrees below the horizon,
+// before noon when ccw is set and after it otherwise. It is NaN when the sun
+// never reaches the angle.
+func (c solarCalculator) sunAngleTime(angle float64, t float64, ccw bool) float64 {
+	declination, _ := sunPosition(c.jd + t)
+	noon := c.midDay(t)
+	cosine := (-dsin(angle) - dsin(declination)*dsin(c.latitude)) / (dcos(declination) * dcos(c.latitude))
+	if cosine < -1 || cosine > 1 {
+		return math.NaN()
+	}
+	offset := darccos(cosine) / 15
+	if ccw {
+		return noon - offset
+	}
+	return noon + offset
+}
+
+// asrTime returns when shadows are factor times an object's length plus its
+// noon shadow.
+func (c solarCalculator) asrTime(factor float64, t float64) float64 {
+	declination, _ := sunPosition(c.jd + t)
+	angle := -darccot(factor + dtan(math.Abs(c.latitude-declination)))
+	return c.sunAngleTime(angle, t, false)
+}
+
+// julianDate returns the Julian date at midnight UTC of a Gregorian date.
+func julianDate(year, month, day int) float64 {
+	if month <= 2 {
+		year--
+		month += 12
+	}
+	a := math.Floor(float64(year) / 100)
+	b := 2 - a + math.Floor(a/4)
+	return math.Floor(365.25*float64(year+4716)) + math.Floor(30.6001*float64(month+1)) + float64(day) + b - 1524.5
+}
+
+func dsin(d float64) float64        { return math.Sin(d * math.Pi / 180) }
+func dcos(d float64) float64        { return math.Cos(d * math.Pi / 180) }
+func dtan(d float64) float64        { return math.Tan(d * math.Pi / 180) }
+func darcsin(x float64) float64     { return math.Asin(x) * 180 / math.Pi }
+func darccos(x float64) float64     { return math.Acos(x) * 180 / math.Pi }
+func darccot(x float64) float64     { return math.Atan(1/x) * 180 / math.Pi }
+func darctan2(y, x float64) float64 { return math.Atan2(y, x) * 180 / math.Pi }
+
+func fixAngle(a float64) float64 { return a - 360*math.Floor(a/360) }
+func fixHour(h float64) float64  { return h - 24*math.Floor(h/24) }
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultQuietMinutesBefore = 5
+	DefaultQuietMinutesAfter  = 20
+	maxQuietMinutes           = 120
+)
+
+// LoadMasjidLocation reads the masjid's coordinates from MASJID_LATITUDE and
+// MASJID_LONGITUDE. It returns nil when neither is set.
+func LoadMasjidLocation() (*Coordinates, error) {
+	latitude, longitude := os.Getenv("MASJID_LATITUDE"), os.Getenv("MASJID_LONGITUDE")
+	if latitude == "" && longitude == "" {
+		return nil, nil
+	}
+	var location Coordinates
+	var err error
+	if location.Latitude, err = strconv.ParseFloat(strings.TrimSpace(latitude), 64); err != nil {
+		return nil, fmt.Errorf("invalid MASJID_LATITUDE %q", latitude)
+	}
+	if location.Longitude, err = strconv.ParseFloat(strings.TrimSpace(longitude), 64); err != nil {
+		return nil, fmt.Errorf("invalid MASJID_LONGITUDE %q", longitude)
+	}
+	if !location.IsValid() {
+		return nil, fmt.Errorf("masjid coordinates %v, %v are out of range", location.Latitude, location.Longitude)
+	}
+	return &location, nil
+}
+
+// ValidateQuietHours checks a quiet hours setting. Coordinates are only
+// checked when the masjid location is not used.
+func ValidateQuietHours(settings domain.QuietHours) error {
+	if !settings.Method.IsValid() {
+		return apperror.New(apperror.CodeInvalidRequest, "method must be one of mwl, isna, egypt, makkah, karachi or kemenag")
+	}
+	if !settings.AsrSchool.IsValid() {
+		return apperror.New(apperror.CodeInvalidRequest, "asr_school must be standard or hanafi")
+	}
+	if !settings.UseMasjidLocation && !(Coordinates{Latitude: settings.Latitude, Longitude: settings.Longitude}).IsValid() {
+		return apperror.New(apperror.CodeInvalidRequest, "latitude must be within ±90 and longitude within ±180")
+	}
+	prayers := settings.PrayerList()
+	if len(prayers) == 0 {
+		return apperror.New(apperror.CodeInvalidRequest, "At least one prayer is required")
+	}
+	for _, prayer := range prayers {
+		if !prayer.IsValid() {
+			return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("Unknown prayer %q", prayer))
+		}
+	}
+	if settings.MinutesBefore < 0 || settings.MinutesBefore > maxQuietMinutes || settings.MinutesAfter < 0 || settings.MinutesAfter > maxQuietMinutes {
+		return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("minutes_before and minutes_after must be between 0 and %d", maxQuietMinutes))
+	}
+	if settings.MinutesBefore+settings.MinutesAfter == 0 {
+		return apperror.New(apperror.CodeInvalidRequest, "minutes_before and minutes_after cannot both be 0")
+	}
+	return nil
+}
+
+// PrayerWindow is the quiet period around one prayer.
+type PrayerWindow struct {
+	Prayer domain.Prayer `json:"prayer"`
+	Start  time.Time     `json:"start"`
+	End    time.Time     `json:"end"`
+}
+
+// QuietWindows returns the windows around the observed prayers of a day, in
+// order.
+func QuietWindows(settings domain.QuietHours, times PrayerTimes) []PrayerWindow {
+	observed := make(map[domain.Prayer]bool)
+	for _, prayer := range settings.PrayerList() {
+		observed[prayer] = true
+	}
+	before := time.Duration(settings.MinutesBefore) * time.Minute
+	after := time.Duration(settings.MinutesAfter) * time.Minute
+	var windows []PrayerWindow
+	for _, prayer := range domain.Prayers {
+		if !observed[prayer] {
+			continue
+		}
+		start := times.Of(prayer)
+		windows = append(windows, PrayerWindow{Prayer: prayer, Start: start.Add(-before), End: start.Add(after)})
+	}
+	return windows
+}
+
+// solarDate returns the calendar day at a longitude, by local mean time.
+func solarDate(t time.Time, longitude float64) time.Time {
+	local := t.UTC().Add(time.Duration(longitude / 15 * float64(time.Hour)))
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// QuietUntil returns the end of the prayer window t falls in, following
+// windows that overlap it. quiet is false when t is outside every window.
+func QuietUntil(settings domain.QuietHours, at Coordinates, t time.Time) (until time.Time, quiet bool, err error) {
+	date := solarDate(t, at.Longitude)
+	var windows []PrayerWindow
+	for offset := -1; offset <= 1; offset++ {
+		times, err := CalculatePrayerTimes(date.AddDate(0, 0, offset), at, settings.Method, settings.AsrSchool)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		windows = append(windows, QuietWindows(settings, times)...)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+
+	until = t
+	for _, window := range windows {
+		if !until.Before(window.Start) && until.Before(window.End) {
+			until, quiet = window.End, true
+		}
+	}
+	return until, quiet, nil
+}
+
+// PrayerSchedule is a day's prayer times at a user's location and the quiet
+// windows around them.
+type PrayerSchedule struct {
+	Date     string         `json:"date"`
+	Location Coordinates    `json:"location"`
+	Times    PrayerTimes    `json:"times"`
+	Windows  []PrayerWindow `json:"windows"`
+}
+
+type QuietHoursService interface {
+	Get(userID uuid.UUID) (*domain.QuietHours, error)
+	Set(userID uuid.UUID, settings domain.QuietHours) (*domain.QuietHours, error)
+	Delete(userID uuid.UUID) error
+	// Schedule returns the prayer times and quiet windows of a calendar day
+	// at the user's location, today there when date is zero.
+	Schedule(userID uuid.UUID, date time.Time) (*PrayerSchedule, error)
+	// DeferUntil returns when a push or email notification due at t may be
+	// delivered to the user: t itself, or the end of the prayer window it
+	// falls in. Live WebSocket delivery is never deferred.
+	DeferUntil(userID uuid.UUID, t time.Time) (time.Time, error)
+}
+
+type quietHoursService struct {
+	db     *gorm.DB
+	masjid *Coordinates
+}
+
+// NewQuietHoursService creates the service. masjid may be nil when no masjid
+// location is configured, in which case users must give their coordinates.
+func NewQuietHoursService(db *gorm.DB, masjid *Coordinates) QuietHoursService {
+	return &quietHoursService{db: db, masjid: masjid}
+}
+
+func (s *quietHoursService) location(settings *domain.QuietHours) (Coordinates, error) {
+	if !settings.UseMasjidLocation {
+		return Coordinates{Latitude: settings.Latitude, Longitude: settings.Longitude}, nil
+	}
+	if s.masjid == nil {
+		return Coordinates{}, apperror.New(apperror.CodeInvalidRequest, "No masjid location is configured, please give your coordinates")
+	}
+	return *s.masjid, nil
+}
+
+func (s *quietHoursService) Get(userID uuid.UUID) (*domain.QuietHours, error) {
+	var settings domain.QuietHours
+	if err := s.db.First(&settings, "user_id = ?", userID).Error; err != nil {
+		return nil, notFoundOrInternal("Quiet hours are not set up", err)
+	}
+	return &settings, nil
+}
+
+func (s *quietHoursService) Set(userID uuid.UUID, settings domain.QuietHours) (*domain.QuietHours, error) {
+	if err := ValidateQuietHours(settings); err != nil {
+		return nil, err
+	}
+	if _, err := s.location(&settings); err != nil {
+		return nil, err
+	}
+	prayers := make([]string, 0, len(domain.Prayers))
+	for _, prayer := range domain.Prayers {
+		for _, observed := range settings.PrayerList() {
+			if observed == prayer {
+				prayers = append(prayers, string(prayer))
+				break
+			}
+		}
+	}
+	settings.UserID = userID
+	settings.Prayers = strings.Join(prayers, ",")
+	settings.CreatedAt = now()
+	settings.UpdatedAt = now()
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"enabled", "use_masjid_location", "latitude", "longitude", "method", "asr_school",
+			"prayers", "minutes_before", "minutes_after", "updated_at",
+		}),
+	}).Create(&settings).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to save quiet hours", err)
+	}
+	log.Printf("Quiet hours of user %s set to %s with method %s, enabled: %t\n", userID, settings.Prayers, settings.Method, settings.Enabled)
+	return &settings, nil
+}
+
+func (s *quietHoursService) Delete(userID uuid.UUID) error {
+	if err := s.db.Delete(&domain.QuietHours{}, "user_id = ?", userID).Error; err != nil {
+		return apperror.Internal("Failed to delete quiet hours", err)
+	}
+	return nil
+}
+
+func (s *quietHoursService) Schedule(userID uuid.UUID, date time.Time) (*PrayerSchedule, error) {
+	settings, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	at, err := s.location(settings)
+	if err != nil {
+		return nil, err
+	}
+	if date.IsZero() {
+		date = solarDate(now(), at.Longitude)
+	}
+	times, err := CalculatePrayerTimes(date, at, settings.Method, settings.AsrSchool)
+	if err != nil {
+		return nil, apperror.Wrap(apperror.CodeInvalidRequest, "Prayer times cannot be calculated for this date and location", err)
+	}
+	return &PrayerSchedule{Date: date.Format(time.DateOnly), Location: at, Times: times, Windows: QuietWindows(*settings, times)}, nil
+}
+
+func (s *quietHoursService) DeferUntil(userID uuid.UUID, t time.Time) (time.Time, error) {
+	var settings domain.QuietHours
+	err := s.db.Where("user_id = ? AND enabled = ?", userID, true).Limit(1).Find(&settings).Error
+	if err != nil {
+		return t, apperror.Internal("Failed to load quiet hours", err)
+	}
+	if settings.UserID == uuid.Nil {
+		return t, nil
+	}
+	at, err := s.location(&settings)
+	if err != nil {
+		// The masjid location was removed after the user chose it.
+		return t, nil
+	}
+	until, quiet, err := QuietUntil(settings, at, t)
+	if err != nil {
+		log.Printf("Quiet hours of user %s not applied: %v\n", userID, err)
+		return t, nil
+	}
+	if quiet {
+		log.Printf("Notifications to user %s deferred until %s\n", userID, until.Format(time.RFC3339))
+	}
+	return until, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+)
+
+// reportContextSize is how many messages before and after a reported message
+// are shown to moderators.
+const reportContextSize = 5
+
+type ReportService interface {
+	ReportMessage(reporterID uuid.UUID, messageID uuid.UUID, reason domain.ReportReason, details string) (*domain.Report, error)
+	ReportUser(reporterID uuid.UUID, reportedUserID uuid.UUID, conversationID uuid.UUID, reason domain.ReportReason, details string) (*domain.Report, error)
+	ListOpenReports(moderatorID uuid.UUID, limit, offset int) ([]ReportWithContext, error)
+	ApplyAction(moderatorID uuid.UUID, reportID uuid.UUID, action domain.ModeratorAction, duration time.Duration, note string) (*domain.Report, error)
+}
+
+// ReportWithContext is a queued report together with the messages around it.
+type ReportWithContext struct {
+	Report   domain.Report
+	Messages []domain.Message
+}
+
+type reportService struct {
+	db       *gorm.DB
+	notifier LiveNotifier
+}
+
+func NewReportService(db *gorm.DB, notifier LiveNotifier) ReportService {
+	return &reportService{db: db, notifier: notifier}
+}
+
+func (s *reportService) ReportMessage(reporterID uuid.UUID, messageID uuid.UUID, reason domain.ReportReason, details string) (*domain.Report, error) {
+	if !reason.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Invalid report reason")
+	}
+	var message domain.Message
+	if err := s.db.First(&message, "id = ?", messageID).Error; err != nil {
+		return nil, notFoundOrInternal("Message not found", err)
+	}
+	if message.SenderID == reporterID {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "You cannot report your own message")
+	}
+	if _, err := requireParticipant(s.db, message.ConversationID, reporterID); err != nil {
+		return nil, err
+	}
+
+	report := newReport(reporterID, message.SenderID, message.ConversationID, reason, details)
+	report.MessageID = sql.NullString{String: messageID.String(), Valid: true}
+	return s.create(report)
+}
+
+func (s *reportService) ReportUser(reporterID uuid.UUID, reportedUserID uuid.UUID, conversationID uuid.UUID, reason domain.ReportReason, details string) (*domain.Report, error) {
+	if !reason.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Invalid report reason")
+	}
+	if reporterID == reportedUserID {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "You cannot report yourself")
+	}
+	if _, err := requireParticipant(s.db, conversationID, reporterID); err != nil {
+		return nil, err
+	}
+	var reported domain.ConversationParticipant
+	if err := s.db.Where("conversation_id = ? AND user_id = ?", conversationID, reportedUserID).First(&reported).Error; err != nil {
+		return nil, notFoundOrInternal("Reported user is not part of this conversation", err)
+	}
+
+	return s.create(newReport(reporterID, reportedUserID, conversationID, reason, details))
+}
+
+func newReport(reporterID uuid.UUID, reportedUserID uuid.UUID, conversationID uuid.UUID, reason domain.ReportReason, details string) domain.Report {
+	return domain.Report{
+		ID:             uuid.New(),
+		ReporterID:     reporterID,
+		ReportedUserID: reportedUserID,
+		ConversationID: conversationID,
+		Reason:         reason,
+		Details:        strings.TrimSpace(details),
+		Status:         domain.ReportStatusOpen,
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+	}
+}
+
+func (s *reportService) create(report domain.Report) (*domain.Report, error) {
+	if err := s.db.Create(&report).Error; err != nil {
+		return nil, apperror.Internal("Failed to save report", err)
+	}
+	log.Printf("Report %s opened against user %s in conversation %s (%s)\n", report.ID, report.ReportedUserID, report.ConversationID, report.Reason)
+	return &report, nil
+}
+
+func (s *reportService) ListOpenReports(moderatorID uuid.UUID, limit, offset int) ([]ReportWithContext, error) {
+	if err := requireStaffRole(s.db, moderatorID, domain.StaffRoleModerator); err != nil {
+		return nil, err
+	}
+
+	var reports []domain.Report
+	if err := s.db.Where("status = ?", domain.ReportStatusOpen).Order("created_at ASC").Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
+		return nil, apperror.Internal("Failed to list reports", err)
+	}
+
+	queue := make([]ReportWithContext, 0, len(reports))
+	for _, report := range reports {
+		messages, err := s.reportContext(report)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, ReportWithContext{Report: report, Messages: messages})
+	}
+	return queue, nil
+}
+
+// reportContext returns the messages around a reported message, or the most
+// recent messages of the conversation for a report against a user. Deleted
+// messages are included so moderators see what was removed.
+func (s *reportService) reportContext(report domain.Report) ([]domain.Message, error) {
+	query := s.db.Unscoped().Where("conversation_id = ?", report.ConversationID).Session(&gorm.Session{})
+	if !report.MessageID.Valid {
+		var recent []domain.Message
+		if err := query.Order("created_at DESC").Limit(reportContextSize * 2).Find(&recent).Error; err != nil {
+			return nil, apperror.Internal("Failed to load report context", err)
+		}
+		reverseMessages(recent)
+		return recent, nil
+	}
+
+	var reported domain.Message
+	if err := s.db.Unscoped().First(&reported, "id = ?", report.MessageID.String).Error; err != nil {
+		return nil, notFoundOrInternal("Reported message not found", err)
+	}
+	var before, after []domain.Message
+	if err := query.Where("created_at < ?", reported.CreatedAt).Order("created_at DESC").Limit(reportContextSize).Find(&before).Error; err != nil {
+		return nil, apperror.Internal("Failed to load report context", err)
+	}
+	if err := query.Where("created_at > ?", reported.CreatedAt).Order("created_at ASC").Limit(reportContextSize).Find(&after).Error; err != nil {
+		return nil, apperror.Internal("Failed to load report context", err)
+	}
+	reverseMessages(before)
+	return append(append(before, reported), after...), nil
+}
+
+func reverseMessages(messages []domain.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// ApplyAction resolves an open report. Deletions, mutes and bans take effect
+// on live connections immediately through the notifier.
+func (s *reportService) ApplyAction(moderatorID uuid.UUID, reportID uuid.UUID, action domain.ModeratorAction, duration time.Duration, note string) (*domain.Report, error) {
+	if err := requireStaffRole(s.db, moderatorID, domain.StaffRoleModerator); err != nil {
+		return nil, err
+	}
+	if !action.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Invalid moderator action")
+	}
+
+	var report domain.Report
+	if err := s.db.First(&report, "id = ?", reportID).Error; err != nil {
+		return nil, notFoundOrInternal("Report not found", err)
+	}
+	if report.Status != domain.ReportStatusOpen {
+		return nil, apperror.New(apperror.CodeConflict, "Report has already been resolved")
+	}
+	if action == domain.ModeratorActionDeleteMessage && !report.MessageID.Valid {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Report does not reference a message")
+	}
+
+	report.Status = domain.ReportStatusActioned
+	if action == domain.ModeratorActionDismiss {
+		report.Status = domain.ReportStatusDismissed
+	}
+	report.Resolution = string(action)
+	report.ResolvedBy = sql.NullString{String: moderatorID.String(), Valid: true}
+	report.ResolvedAt = sql.NullTime{Time: now(), Valid: true}
+	report.ModeratorNote = strings.TrimSpace(note)
+	report.UpdatedAt = now()
+
+	var sanction *domain.UserSanction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Another moderator may have resolved the report since it was
+		// loaded; only the update that still finds it open acts on it.
+		result := tx.Model(&domain.Report{}).Where("id = ? AND status = ?", report.ID, domain.ReportStatusOpen).Updates(map[string]interface{}{
+			"status":         report.Status,
+			"resolution":     report.Resolution,
+			"resolved_by":    report.ResolvedBy,
+			"resolved_at":    report.ResolvedAt,
+			"moderator_note": report.ModeratorNote,
+			"updated_at":     report.UpdatedAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperror.New(apperror.CodeConflict, "Report has already been resolved")
+		}
+
+		switch action {
+		case domain.ModeratorActionDeleteMessage:
+			if err := tx.Delete(&domain.Message{}, "id = ?", report.MessageID.String).Error; err != nil {
+				return err
+			}
+		case domain.ModeratorActionMuteUser, domain.ModeratorActionBanUser:
+			sanction = newSanction(report, moderatorID, action, duration)
+			if err := tx.Create(sanction).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		return nil, apperror.Internal("Failed to apply moderator action", err)
+	}
+
+	log.Printf("Moderator %s resolved report %s with %s\n", moderatorID, report.ID, action)
+	s.notify(report, action, sanction)
+	return &report, nil
+}
+
+func newSanction(report domain.Report, moderatorID uuid.UUID, action domain.ModeratorAction, duration time.Duration) *domain.UserSanction {
+	sanction := &domain.UserSanction{
+		ID:        uuid.New(),
+		UserID:    report.ReportedUserID,
+		Type:      domain.SanctionTypeMute,
+		ReportID:  sql.NullString{String: report.ID.String(), Valid: true},
+		Reason:    string(report.Reason),
+		CreatedBy: moderatorID,
+		CreatedAt: now(),
+	}
+	if action == domain.ModeratorActionBanUser {
+		sanction.Type = domain.SanctionTypeBan
+	}
+	if duration > 0 {
+		sanction.ExpiresAt = sql.NullTime{Time: now().Add(duration), Valid: true}
+	}
+	return sanction
+}
+
+func (s *reportService) notify(report domain.Report, action domain.ModeratorAction, sanction *domain.UserSanction) {
+	switch action {
+	case domain.ModeratorActionDeleteMessage:
+		s.notifier.BroadcastEvent(domain.Event{
+			Type:           domain.EventMessageDeleted,
+			ConversationID: report.ConversationID,
+			Data:           map[string]interface{}{"message_id": report.MessageID.String},
+		})
+	case domain.ModeratorActionMuteUser:
+		s.notifier.NotifyUser(report.ReportedUserID, domain.Event{Type: domain.EventUserMuted, Data: sanctionData(sanction)})
+	case domain.ModeratorActionBanUser:
+		s.notifier.DisconnectUser(report.ReportedUserID, domain.Event{Type: domain.EventUserBanned, Data: sanctionData(sanction)})
+	}
+}
+
+func sanctionData(sanction *domain.UserSanction) map[string]interface{} {
+	data := map[string]interface{}{"reason": sanction.Reason, "expires_at": nil}
+	if sanction.ExpiresAt.Valid {
+		data["expires_at"] = sanction.ExpiresAt.Time.Format(time.RFC3339)
+	}
+	return data
+}
+
+// ensureNotSanctioned rejects users who are banned or muted.
+func ensureNotSanctioned(db *gorm.DB, userID uuid.UUID, types ...domain.SanctionType) error {
+	for _, sanctionType := range types {
+		sanction, err := activeSanction(db, userID, sanctionType)
+		if err != nil {
+			return err
+		}
+		if sanction == nil {
+			continue
+		}
+		message := "You are muted"
+		if sanctionType == domain.SanctionTypeBan {
+			message = "You are banned"
+		}
+		if sanction.ExpiresAt.Valid {
+			message += " until " + sanction.ExpiresAt.Time.Format(time.RFC3339)
+		}
+		return apperror.New(apperror.CodeForbidden, message)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+)
+
+const maxSavedReplyTitleRunes = 100
+
+// Saved reply placeholders.
+const (
+	PlaceholderFirstName = "first_name"
+	PlaceholderPurpose   = "purpose"
+	PlaceholderDate      = "date"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+var knownPlaceholders = map[string]bool{
+	PlaceholderFirstName: true,
+	PlaceholderPurpose:   true,
+	PlaceholderDate:      true,
+}
+
+// ValidateSavedReplyContent rejects empty content and unknown placeholders.
+func ValidateSavedReplyContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return apperror.New(apperror.CodeInvalidRequest, "Content is required")
+	}
+	var unknown []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(content, -1) {
+		if !knownPlaceholders[match[1]] {
+			unknown = append(unknown, "{{"+match[1]+"}}")
+		}
+	}
+	if len(unknown) > 0 {
+		return apperror.New(apperror.CodeInvalidRequest, "Unknown placeholders: "+strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// ExpandSavedReply replaces each {{placeholder}} in content with its value.
+// Placeholders without a value are left as written.
+func ExpandSavedReply(content string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(content, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+type SavedReplyService interface {
+	Create(agentID uuid.UUID, scope domain.SavedReplyScope, title string, content string) (*domain.SavedReply, error)
+	Update(agentID uuid.UUID, replyID uuid.UUID, title string, content string) (*domain.SavedReply, error)
+	Delete(agentID uuid.UUID, replyID uuid.UUID) error
+	// List returns the agent's personal replies and the team's, optionally
+	// filtered by a search over title and content.
+	List(agentID uuid.UUID, search string, limit, offset int) ([]domain.SavedReply, error)
+}
+
+type savedReplyService struct {
+	db *gorm.DB
+}
+
+func NewSavedReplyService(db *gorm.DB) SavedReplyService {
+	return &savedReplyService{db: db}
+}
+
+// requireSupportStaff allows agents and supervisors.
+func requireSupportStaff(db *gorm.DB, userID uuid.UUID) error {
+	for _, role := range []domain.StaffRole{domain.StaffRoleAgent, domain.StaffRoleSupervisor} {
+		ok, err := hasStaffRole(db, userID, role)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return apperror.New(apperror.CodeForbidden, "Saved replies are available to support agents only")
+}
+
+func validateSavedReply(title string, content string) error {
+	if title = strings.TrimSpace(title); title == "" {
+		return apperror.New(apperror.CodeInvalidRequest, "Title is required")
+	}
+	if utf8.RuneCountInString(title) > maxSavedReplyTitleRunes {
+		return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("Title must be at most %d characters", maxSavedReplyTitleRunes))
+	}
+	return ValidateSavedReplyContent(content)
+}
+
+func (s *savedReplyService) Create(agentID uuid.UUID, scope domain.SavedReplyScope, title string, content string) (*domain.SavedReply, error) {
+	if !scope.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "scope must be personal or team")
+	}
+	if err := requireSupportStaff(s.db, agentID); err != nil {
+		return nil, err
+	}
+	if scope == domain.SavedReplyScopeTeam {
+		if err := requireStaffRole(s.db, agentID, domain.StaffRoleSupervisor); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateSavedReply(title, content); err != nil {
+		return nil, err
+	}
+
+	reply := domain.SavedReply{
+		ID:        uuid.New(),
+		Scope:     scope,
+		OwnerID:   agentID,
+		Title:     strings.TrimSpace(title),
+		Content:   content,
+		CreatedAt: now(),
+		UpdatedAt: now(),
+	}
+	if err := s.db.Create(&reply).Error; err != nil {
+		return nil, apperror.Internal("Failed to save reply", err)
+	}
+	return &reply, nil
+}
+
+// editable loads a reply the agent may change: their own personal replies,
+// and team replies for supervisors.
+func (s *savedReplyService) editable(agentID uuid.UUID, replyID uuid.UUID) (*domain.SavedReply, error) {
+	if err := requireSupportStaff(s.db, agentID); err != nil {
+		return nil, err
+	}
+	var reply domain.SavedReply
+	if err := s.db.First(&reply, "id = ?", replyID).Error; err != nil {
+		return nil, notFoundOrInternal("Saved reply not found", err)
+	}
+	if reply.Scope == domain.SavedReplyScopeTeam {
+		if err := requireStaffRole(s.db, agentID, domain.StaffRoleSupervisor); err != nil {
+			return nil, err
+		}
+	} else if reply.OwnerID != agentID {
+		return nil, apperror.New(apperror.CodeNotFound, "Saved reply not found")
+	}
+	return &reply, nil
+}
+
+func (s *savedReplyService) Update(agentID uuid.UUID, replyID uuid.UUID, title string, content string) (*domain.SavedReply, error) {
+	reply, err := s.editable(agentID, replyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSavedReply(title, content); err != nil {
+		return nil, err
+	}
+	reply.Title = strings.TrimSpace(title)
+	reply.Content = content
+	reply.UpdatedAt = now()
+	if err := s.db.Save(reply).Error; err != nil {
+		return nil, apperror.Internal("Failed to update reply", err)
+	}
+	return reply, nil
+}
+
+func (s *savedReplyService) Delete(agentID uuid.UUID, replyID uuid.UUID) error {
+	reply, err := s.editable(agentID, replyID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(reply).Error; err != nil {
+		return apperror.Internal("Failed to delete reply", err)
+	}
+	return nil
+}
+
+func (s *savedReplyService) List(agentID uuid.UUID, search string, limit, offset int) ([]domain.SavedReply, error) {
+	if err := requireSupportStaff(s.db, agentID); err != nil {
+		return nil, err
+	}
+	query := s.db.
+		Where("scope = ? OR owner_id = ?", domain.SavedReplyScopeTeam, agentID).
+		Order("title ASC").
+		Limit(limit).
+		Offset(offset)
+	if search = strings.TrimSpace(search); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("title ILIKE ? OR content ILIKE ?", pattern, pattern)
+	}
+	var replies []domain.SavedReply
+	if err := query.Find(&replies).Error; err != nil {
+		return nil, apperror.Internal("Failed to list saved replies", err)
+	}
+	return replies, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ExpandSavedReply returns a saved reply's content with its placeholders
+// filled in for a support conversation, ready to be sent as a text message.
+func (s *chatService) ExpandSavedReply(agentID uuid.UUID, conversationID uuid.UUID, replyID uuid.UUID) (string, error) {
+	if err := requireSupportStaff(s.db, agentID); err != nil {
+		return "", err
+	}
+	var conversation domain.Conversation
+	if err := s.db.First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return "", notFoundOrInternal("Conversation not found", err)
+	}
+	if !conversation.Purpose.IsSupport() {
+		return "", apperror.New(apperror.CodeForbidden, "Saved replies can only be used in support conversations")
+	}
+
+	var reply domain.SavedReply
+	err := s.db.Where("id = ? AND (scope = ? OR owner_id = ?)", replyID, domain.SavedReplyScopeTeam, agentID).First(&reply).Error
+	if err != nil {
+		return "", notFoundOrInternal("Saved reply not found", err)
+	}
+
+	values := map[string]string{
+		PlaceholderPurpose: strings.ReplaceAll(string(conversation.Purpose), "_", " "),
+		PlaceholderDate:    now().Format("2 January 2006"),
+	}
+	user, err := s.supportRequester(&conversation, agentID)
+	if err != nil {
+		return "", err
+	}
+	if user != nil {
+		values[PlaceholderFirstName] = user.FirstName
+	}
+	return ExpandSavedReply(reply.Content, values), nil
+}
+
+// supportRequester returns the user a support conversation is serving: the
+// ticket's requester, or else the earliest member other than the agent.
+func (s *chatService) supportRequester(conversation *domain.Conversation, agentID uuid.UUID) (*domain.User, error) {
+	var requesterID uuid.UUID
+	var ticket domain.SupportTicket
+	err := s.db.Where("conversation_id = ?", conversation.ID).First(&ticket).Error
+	switch {
+	case err == nil:
+		requesterID = ticket.RequesterID
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		var participant domain.ConversationParticipant
+		err := s.db.Where("conversation_id = ? AND user_id <> ? AND role <> ?", conversation.ID, agentID, domain.ParticipantRoleObserver).
+			Order("joined_at ASC").
+			First(&participant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, apperror.Internal("Failed to load participants", err)
+		}
+		requesterID = participant.UserID
+	default:
+		return nil, apperror.Internal("Failed to load support ticket", err)
+	}
+
+	var user domain.User
+	if err := s.db.First(&user, "id = ?", requesterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.Internal("Failed to load user", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+)
+
+// LiveNotifier pushes events to connected clients. The WebSocket hub
+// implements it; services only depend on this interface.
+type LiveNotifier interface {
+	// BroadcastMessage delivers a message stored by the server, such as a
+	// survey, to the clients connected to its conversation.
+	BroadcastMessage(message *domain.Message)
+	BroadcastEvent(event domain.Event)
+	NotifyUser(userID uuid.UUID, event domain.Event)
+	DisconnectUser(userID uuid.UUID, event domain.Event)
+	DisconnectParticipant(conversationID uuid.UUID, userID uuid.UUID, event domain.Event)
+}
+
+// DeferredNotifier forwards to a LiveNotifier attached after construction. It
+// lets the chat service, which the hub itself depends on, push events through
+// the hub. Events sent before Attach are dropped.
+type DeferredNotifier struct {
+	mu     sync.RWMutex
+	target LiveNotifier
+}
+
+func (n *DeferredNotifier) Attach(target LiveNotifier) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.target = target
+}
+
+func (n *DeferredNotifier) get() LiveNotifier {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.target
+}
+
+func (n *DeferredNotifier) BroadcastMessage(message *domain.Message) {
+	if target := n.get(); target != nil {
+		target.BroadcastMessage(message)
+	}
+}
+
+func (n *DeferredNotifier) BroadcastEvent(event domain.Event) {
+	if target := n.get(); target != nil {
+		target.BroadcastEvent(event)
+	}
+}
+
+func (n *DeferredNotifier) NotifyUser(userID uuid.UUID, event domain.Event) {
+	if target := n.get(); target != nil {
+		target.NotifyUser(userID, event)
+	}
+}
+
+func (n *DeferredNotifier) DisconnectUser(userID uuid.UUID, event domain.Event) {
+	if target := n.get(); target != nil {
+		target.DisconnectUser(userID, event)
+	}
+}
+
+func (n *DeferredNotifier) DisconnectParticipant(conversationID uuid.UUID, userID uuid.UUID, event domain.Event) {
+	if target := n.get(); target != nil {
+		target.DisconnectParticipant(conversationID, userID, event)
+	}
+}
+
+// isStaff reports whether the user holds any staff role.
+func isStaff(db *gorm.DB, userID uuid.UUID) (bool, error) {
+	var count int64
+	if err := db.Model(&domain.StaffMember{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return false, apperror.Internal("Failed to check staff role", err)
+	}
+	return count > 0, nil
+}
+
+func hasStaffRole(db *gorm.DB, userID uuid.UUID, role domain.StaffRole) (bool, error) {
+	var member domain.StaffMember
+	err := db.Where("user_id = ? AND role = ?", userID, role).First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, apperror.Internal("Failed to check staff role", err)
+	}
+	return true, nil
+}
+
+// requireStaffRole returns a forbidden error unless the user holds role.
+func requireStaffRole(db *gorm.DB, userID uuid.UUID, role domain.StaffRole) error {
+	ok, err := hasStaffRole(db, userID, role)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperror.New(apperror.CodeForbidden, "This action requires the "+string(role)+" role")
+	}
+	return nil
+}
+
+// activeSanction returns the user's active sanction of the given type, or nil.
+func activeSanction(db *gorm.DB, userID uuid.UUID, sanctionType domain.SanctionType) (*domain.UserSanction, error) {
+	var sanction domain.UserSanction
+	err := db.Where("user_id = ? AND type = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, sanctionType, now()).
+		Order("created_at DESC").
+		First(&sanction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.Internal("Failed to check sanctions", err)
+	}
+	return &sanction, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AgentCandidate is an available agent with spare capacity, as seen by an
+// assignment strategy. LastAssignedAt is zero for agents who never had a
+// ticket.
+type AgentCandidate struct {
+	AgentID        uuid.UUID
+	OpenTickets    int
+	LastAssignedAt time.Time
+}
+
+// AssignmentStrategy picks the agent for a queued ticket.
+type AssignmentStrategy interface {
+	Name() string
+	Pick(candidates []AgentCandidate) (AgentCandidate, bool)
+}
+
+// NewAssignmentStrategy returns the strategy with the given name; an empty
+// name selects least_load.
+func NewAssignmentStrategy(name string) (AssignmentStrategy, error) {
+	switch name {
+	case "", "least_load":
+		return LeastLoadStrategy{}, nil
+	case "round_robin":
+		return RoundRobinStrategy{}, nil
+	}
+	return nil, fmt.Errorf("unknown assignment strategy %q", name)
+}
+
+// RoundRobinStrategy picks the agent who has waited longest since their last
+// assignment, so tickets rotate through the available agents.
+type RoundRobinStrategy struct{}
+
+func (RoundRobinStrategy) Name() string {
+	return "round_robin"
+}
+
+func (RoundRobinStrategy) Pick(candidates []AgentCandidate) (AgentCandidate, bool) {
+	return pickAgent(candidates, func(a, b AgentCandidate) bool { return false })
+}
+
+// LeastLoadStrategy picks the agent with the fewest open tickets, falling back
+// to round robin between equally loaded agents.
+type LeastLoadStrategy struct{}
+
+func (LeastLoadStrategy) Name() string {
+	return "least_load"
+}
+
+func (LeastLoadStrategy) Pick(candidates []AgentCandidate) (AgentCandidate, bool) {
+	return pickAgent(candidates, func(a, b AgentCandidate) bool { return a.OpenTickets < b.OpenTickets })
+}
+
+// pickAgent returns the best candidate by prefer, breaking ties by the oldest
+// last assignment and then by agent ID so the choice is deterministic.
+func pickAgent(candidates []AgentCandidate, prefer func(a, b AgentCandidate) bool) (AgentCandidate, bool) {
+	if len(candidates) == 0 {
+		return AgentCandidate{}, false
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		switch {
+		case prefer(c, best):
+			best = c
+		case prefer(best, c):
+		case c.LastAssignedAt.Before(best.LastAssignedAt):
+			best = c
+		case c.LastAssignedAt.Equal(best.LastAssignedAt) && c.AgentID.String() < best.AgentID.String():
+			best = c
+		}
+	}
+	return best, true
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SupportService runs the support queue: users open tickets without choosing
+// a partner and agents are assigned to them.
+type SupportService interface {
+	OpenTicket(userID uuid.UUID, purpose domain.ConversationPurpose) (*domain.Conversation, error)
+	SetAvailability(agentID uuid.UUID, available bool) (*domain.SupportAgent, error)
+	ListTickets(agentID uuid.UUID, status domain.SupportTicketStatus, limit, offset int) ([]domain.SupportTicket, error)
+	Transfer(actorID uuid.UUID, ticketID uuid.UUID, toAgentID uuid.UUID) (*domain.SupportTicket, error)
+	// SLAReport summarises first response and resolution times of tickets
+	// opened in [from, to) for supervisors.
+	SLAReport(actorID uuid.UUID, from, to time.Time) ([]SLAReport, error)
+	TicketQueue
+	// Run escalates tickets that miss their SLA targets until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// TicketQueue is the part of the support queue the chat service needs, as
+// resolving or closing a ticket frees its agent for the next one.
+type TicketQueue interface {
+	// DrainQueue assigns queued tickets to agents with room.
+	DrainQueue()
+}
+
+type supportService struct {
+	db         *gorm.DB
+	notifier   LiveNotifier
+	strategy   AssignmentStrategy
+	maxTickets int
+	targets    SLATargets
+}
+
+// NewSupportService creates the service. maxTickets is how many assigned
+// tickets an agent can hold before new tickets wait in the queue; targets are
+// the SLA targets tickets are escalated against.
+func NewSupportService(db *gorm.DB, notifier LiveNotifier, strategy AssignmentStrategy, maxTickets int, targets SLATargets) SupportService {
+	return &supportService{db: db, notifier: notifier, strategy: strategy, maxTickets: maxTickets, targets: targets}
+}
+
+// OpenTicket returns the user's open support conversation for purpose, or
+// creates one and queues it. The ticket is assigned right away when an agent
+// is available.
+func (s *supportService) OpenTicket(userID uuid.UUID, purpose domain.ConversationPurpose) (*domain.Conversation, error) {
+	if !purpose.IsSupport() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Purpose "+string(purpose)+" is not a support purpose")
+	}
+	if err := ensureNotSanctioned(s.db, userID, domain.SanctionTypeBan); err != nil {
+		return nil, err
+	}
+
+	var ticket domain.SupportTicket
+	err := s.db.Where("requester_id = ? AND purpose = ? AND status IN ?", userID, purpose, []domain.SupportTicketStatus{domain.SupportTicketStatusQueued, domain.SupportTicketStatusAssigned, domain.SupportTicketStatusResolved}).
+		Order("created_at DESC").
+		First(&ticket).Error
+	if err == nil {
+		var conversation domain.Conversation
+		if err := s.db.First(&conversation, "id = ?", ticket.ConversationID).Error; err != nil {
+			return nil, notFoundOrInternal("Conversation not found", err)
+		}
+		log.Printf("User %s reconnected to support ticket %s.\n", userID, ticket.ID)
+		return &conversation, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.Internal("Failed to look up support ticket", err)
+	}
+
+	conversation := domain.Conversation{
+		ID:        uuid.New(),
+		CreatorID: userID,
+		Type:      domain.ConversationTypePrivate,
+		Purpose:   purpose,
+		Name:      sql.NullString{String: fmt.Sprintf("Support for %s - %s", userID.String()[:8], purpose), Valid: true},
+		CreatedAt: now(),
+		UpdatedAt: now(),
+	}
+	ticket = domain.SupportTicket{
+		ID:             uuid.New(),
+		ConversationID: conversation.ID,
+		RequesterID:    userID,
+		Purpose:        purpose,
+		Status:         domain.SupportTicketStatusQueued,
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&conversation).Error; err != nil {
+			return err
+		}
+		if err := addParticipant(tx, conversation.ID, userID, domain.ParticipantRoleMember); err != nil {
+			return err
+		}
+		return tx.Create(&ticket).Error
+	})
+	if err != nil {
+		return nil, apperror.Internal("Failed to open support ticket", err)
+	}
+	log.Printf("Support ticket %s queued for user %s (%s).\n", ticket.ID, userID, purpose)
+
+	if err := s.assign(&ticket); err != nil {
+		log.Printf("Warning: Failed to assign support ticket %s: %v", ticket.ID, err)
+	}
+	return &conversation, nil
+}
+
+// SetAvailability marks an agent as available or away. An agent who becomes
+// available takes queued tickets up to their capacity.
+func (s *supportService) SetAvailability(agentID uuid.UUID, available bool) (*domain.SupportAgent, error) {
+	if err := requireStaffRole(s.db, agentID, domain.StaffRoleAgent); err != nil {
+		return nil, err
+	}
+	agent := domain.SupportAgent{UserID: agentID, Available: available, UpdatedAt: now()}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"available", "updated_at"}),
+	}).Create(&agent).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to update availability", err)
+	}
+	log.Printf("Agent %s is now available: %t\n", agentID, available)
+
+	if available {
+		s.DrainQueue()
+	}
+	return &agent, nil
+}
+
+// DrainQueue assigns queued tickets, oldest first, until no agent has room.
+func (s *supportService) DrainQueue() {
+	var queued []domain.SupportTicket
+	if err := s.db.Where("status = ?", domain.SupportTicketStatusQueued).Order("created_at ASC").Find(&queued).Error; err != nil {
+		log.Printf("Warning: Failed to load the support queue: %v", err)
+		return
+	}
+	for i := range queued {
+		if err := s.assign(&queued[i]); err != nil {
+			log.Printf("Warning: Failed to assign support ticket %s: %v", queued[i].ID, err)
+			return
+		}
+		if queued[i].Status == domain.SupportTicketStatusQueued {
+			return
+		}
+	}
+}
+
+// assign gives a queued ticket to the agent picked by the strategy. The ticket
+// stays queued when every available agent is at capacity.
+func (s *supportService) assign(ticket *domain.SupportTicket) error {
+	var agentID uuid.UUID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the available agents serialises concurrent assignments so
+		// loads are counted consistently.
+		var agents []domain.SupportAgent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("available").Find(&agents).Error; err != nil {
+			return err
+		}
+		candidates, err := s.candidates(tx, agents)
+		if err != nil {
+			return err
+		}
+		candidate, ok := s.strategy.Pick(candidates)
+		if !ok {
+			return nil
+		}
+		agentID = candidate.AgentID
+
+		result := tx.Model(&domain.SupportTicket{}).
+			Where("id = ? AND status = ?", ticket.ID, domain.SupportTicketStatusQueued).
+			Updates(map[string]interface{}{
+				"status":      domain.SupportTicketStatusAssigned,
+				"agent_id":    agentID.String(),
+				"assigned_at": now(),
+				"updated_at":  now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Someone else assigned it in the meantime.
+			agentID = uuid.Nil
+			return nil
+		}
+		if err := tx.Model(&domain.SupportAgent{}).Where("user_id = ?", agentID).Update("last_assigned_at", now()).Error; err != nil {
+			return err
+		}
+		return addParticipant(tx, ticket.ConversationID, agentID, domain.ParticipantRoleMember)
+	})
+	if err != nil || agentID == uuid.Nil {
+		return err
+	}
+
+	ticket.Status = domain.SupportTicketStatusAssigned
+	ticket.AgentID = sql.NullString{String: agentID.String(), Valid: true}
+	log.Printf("Support ticket %s assigned to agent %s by %s.\n", ticket.ID, agentID, s.strategy.Name())
+	event := domain.Event{
+		Type:           domain.EventTicketAssigned,
+		ConversationID: ticket.ConversationID,
+		Data:           map[string]interface{}{"ticket_id": ticket.ID.String(), "agent_id": agentID.String()},
+	}
+	s.notifier.BroadcastEvent(event)
+	s.notifier.NotifyUser(agentID, event)
+	return nil
+}
+
+func (s *supportService) candidates(tx *gorm.DB, agents []domain.SupportAgent) ([]AgentCandidate, error) {
+	var candidates []AgentCandidate
+	for _, agent := range agents {
+		var open int64
+		if err := tx.Model(&domain.SupportTicket{}).
+			Where("agent_id = ? AND status = ?", agent.UserID, domain.SupportTicketStatusAssigned).
+			Count(&open).Error; err != nil {
+			return nil, err
+		}
+		if int(open) >= s.maxTickets {
+			continue
+		}
+		candidate := AgentCandidate{AgentID: agent.UserID, OpenTickets: int(open)}
+		if agent.LastAssignedAt.Valid {
+			candidate.LastAssignedAt = agent.LastAssignedAt.Time
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// ListTickets lists tickets for agents. Agents see the queue and their own
+// assigned tickets; supervisors see every assigned ticket.
+func (s *supportService) ListTickets(agentID uuid.UUID, status domain.SupportTicketStatus, limit, offset int) ([]domain.SupportTicket, error) {
+	if status != "" && !status.IsValid() {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "Invalid ticket status")
+	}
+	isSupervisor, err := hasStaffRole(s.db, agentID, domain.StaffRoleSupervisor)
+	if err != nil {
+		return nil, err
+	}
+	if !isSupervisor {
+		if err := requireStaffRole(s.db, agentID, domain.StaffRoleAgent); err != nil {
+			return nil, err
+		}
+	}
+
+	query := s.db.Order("created_at ASC").Limit(limit).Offset(offset)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if !isSupervisor {
+		query = query.Where("status = ? OR agent_id = ?", domain.SupportTicketStatusQueued, agentID)
+	}
+	var tickets []domain.SupportTicket
+	if err := query.Find(&tickets).Error; err != nil {
+		return nil, apperror.Internal("Failed to list tickets", err)
+	}
+	return tickets, nil
+}
+
+// Transfer hands a ticket to another agent on behalf of its agent or a
+// supervisor.
+func (s *supportService) Transfer(actorID uuid.UUID, ticketID uuid.UUID, toAgentID uuid.UUID) (*domain.SupportTicket, error) {
+	var ticket domain.SupportTicket
+	if err := s.db.First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return nil, notFoundOrInternal("Ticket not found", err)
+	}
+	if ticket.AgentID.Valid && ticket.AgentID.String == toAgentID.String() {
+		return nil, apperror.New(apperror.CodeConflict, "The ticket is already assigned to this agent")
+	}
+	if !ticket.AgentID.Valid || ticket.AgentID.String != actorID.String() {
+		if err := requireStaffRole(s.db, actorID, domain.StaffRoleSupervisor); err != nil {
+			return nil, apperror.New(apperror.CodeForbidden, "Only the assigned agent or a supervisor can transfer this ticket")
+		}
+	}
+	if err := requireStaffRole(s.db, toAgentID, domain.StaffRoleAgent); err != nil {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "The ticket can only be transferred to an agent")
+	}
+
+	if _, err := s.moveTicket(&ticket, toAgentID, actorID); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// moveTicket assigns a queued or assigned ticket to another agent. The
+// conversation and its history move with it; the previous agent leaves the
+// conversation and both sides are told through a system message and a
+// ticket_transferred event.
+func (s *supportService) moveTicket(ticket *domain.SupportTicket, toAgentID uuid.UUID, actorID uuid.UUID) (*domain.Message, error) {
+	if ticket.Status != domain.SupportTicketStatusQueued && ticket.Status != domain.SupportTicketStatusAssigned {
+		return nil, apperror.New(apperror.CodeConflict, "Only queued or assigned tickets can be transferred")
+	}
+	var previousID uuid.UUID
+	if ticket.AgentID.Valid {
+		previousID, _ = uuid.Parse(ticket.AgentID.String)
+	}
+	content := "The conversation was transferred to another agent."
+	var message *domain.Message
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// The ticket may have been resolved or moved since it was loaded;
+		// only the update that still finds it as it was read wins. Other
+		// columns, such as first_response_at, are left as they are.
+		query := tx.Model(&domain.SupportTicket{}).Where("id = ? AND status = ?", ticket.ID, ticket.Status)
+		if ticket.AgentID.Valid {
+			query = query.Where("agent_id = ?", ticket.AgentID.String)
+		} else {
+			query = query.Where("agent_id IS NULL")
+		}
+		assignedAt := now()
+		result := query.Updates(map[string]interface{}{
+			"status":      domain.SupportTicketStatusAssigned,
+			"agent_id":    toAgentID.String(),
+			"assigned_at": assignedAt,
+			"updated_at":  assignedAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperror.New(apperror.CodeConflict, "The ticket changed in the meantime, please try again")
+		}
+		ticket.Status = domain.SupportTicketStatusAssigned
+		ticket.AgentID = sql.NullString{String: toAgentID.String(), Valid: true}
+		ticket.AssignedAt = sql.NullTime{Time: assignedAt, Valid: true}
+		ticket.UpdatedAt = assignedAt
+
+		if previousID != uuid.Nil {
+			if err := tx.Model(&domain.ConversationParticipant{}).
+				Where("conversation_id = ? AND user_id = ?", ticket.ConversationID, previousID).
+				Update("left_at", now()).Error; err != nil {
+				return err
+			}
+		}
+		if err := addParticipant(tx, ticket.ConversationID, toAgentID, domain.ParticipantRoleMember); err != nil {
+			return err
+		}
+		var err error
+		message, err = createSystemMessage(tx, ticket.ConversationID, actorID, content)
+		return err
+	})
+	if err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		return nil, apperror.Internal("Failed to transfer ticket", err)
+	}
+
+	log.Printf("Support ticket %s transferred from %s to %s by %s.\n", ticket.ID, previousID, toAgentID, actorID)
+	event := domain.Event{
+		Type:           domain.EventTicketTransferred,
+		ConversationID: ticket.ConversationID,
+		Data: map[string]interface{}{
+			"ticket_id":  ticket.ID.String(),
+			"agent_id":   toAgentID.String(),
+			"message_id": message.ID.String(),
+			"content":    content,
+		},
+	}
+	s.notifier.BroadcastEvent(event)
+	s.notifier.NotifyUser(toAgentID, event)
+	if previousID != uuid.Nil {
+		s.notifier.DisconnectParticipant(ticket.ConversationID, previousID, event)
+	}
+	return message, nil
+}
+
+// addParticipant adds a user to a conversation, or brings them back if they
+// had left it.
+func addParticipant(tx *gorm.DB, conversationID uuid.UUID, userID uuid.UUID, role string) error {
+	participant := domain.ConversationParticipant{
+		ConversationID: conversationID,
+		UserID:         userID,
+		JoinedAt:       now(),
+		Role:           role,
+	}
+	return tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"left_at": nil, "joined_at": participant.JoinedAt, "role": role}),
+	}).Create(&participant).Error
+}
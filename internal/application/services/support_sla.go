@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SLATarget is the promised first response and resolution time for a support
+// purpose. A zero duration is not tracked.
+type SLATarget struct {
+	FirstResponse time.Duration
+	Resolution    time.Duration
+}
+
+// SLATargets holds the target of each support purpose.
+type SLATargets map[domain.ConversationPurpose]SLATarget
+
+var defaultSLATargets = SLATargets{
+	domain.ConversationPurposeAdminSupport:   {FirstResponse: time.Hour, Resolution: 24 * time.Hour},
+	domain.ConversationPurposeGeneralSupport: {FirstResponse: 4 * time.Hour, Resolution: 72 * time.Hour},
+}
+
+// LoadSLATargets returns the default targets overridden by
+// SUPPORT_SLA_FIRST_RESPONSE_<PURPOSE> and SUPPORT_SLA_RESOLUTION_<PURPOSE>,
+// e.g. SUPPORT_SLA_FIRST_RESPONSE_ADMIN_SUPPORT=30m. "none" stops tracking.
+func LoadSLATargets() (SLATargets, error) {
+	targets := make(SLATargets)
+	for _, purpose := range domain.ConversationPurposes() {
+		if !purpose.IsSupport() {
+			continue
+		}
+		target := defaultSLATargets[purpose]
+		suffix := strings.ToUpper(string(purpose))
+		for key, field := range map[string]*time.Duration{
+			"SUPPORT_SLA_FIRST_RESPONSE_" + suffix: &target.FirstResponse,
+			"SUPPORT_SLA_RESOLUTION_" + suffix:     &target.Resolution,
+		} {
+			value, ok := os.LookupEnv(key)
+			if !ok {
+				continue
+			}
+			if value = strings.TrimSpace(value); value == "" || value == "none" {
+				*field = 0
+				continue
+			}
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid duration %q in %s", value, key)
+			}
+			*field = d
+		}
+		targets[purpose] = target
+	}
+	return targets, nil
+}
+
+// Breaches returns the targets a ticket has missed as of now. A response or
+// resolution that has not happened yet counts as taking until now.
+func (t SLATarget) Breaches(ticket domain.SupportTicket, now time.Time) []domain.SLAKind {
+	var breaches []domain.SLAKind
+	if t.FirstResponse > 0 && elapsed(ticket.CreatedAt, ticket.FirstResponseAt, now) > t.FirstResponse {
+		breaches = append(breaches, domain.SLAKindFirstResponse)
+	}
+	if t.Resolution > 0 && elapsed(ticket.CreatedAt, ticket.ResolvedAt, now) > t.Resolution {
+		breaches = append(breaches, domain.SLAKindResolution)
+	}
+	return breaches
+}
+
+func elapsed(start time.Time, end sql.NullTime, now time.Time) time.Duration {
+	if end.Valid {
+		return end.Time.Sub(start)
+	}
+	return now.Sub(start)
+}
+
+// SLAReport summarises the tickets of one support purpose. Averages are in
+// seconds and only cover tickets that were responded to or resolved.
+type SLAReport struct {
+	Purpose                     domain.ConversationPurpose `json:"purpose"`
+	FirstResponseTargetSeconds  int64                      `json:"first_response_target_seconds"`
+	ResolutionTargetSeconds     int64                      `json:"resolution_target_seconds"`
+	Tickets                     int                        `json:"tickets"`
+	Responded                   int                        `json:"responded"`
+	AverageFirstResponseSeconds int64                      `json:"average_first_response_seconds"`
+	FirstResponseBreaches       int                        `json:"first_response_breaches"`
+	Resolved                    int                        `json:"resolved"`
+	AverageResolutionSeconds    int64                      `json:"average_resolution_seconds"`
+	ResolutionBreaches          int                        `json:"resolution_breaches"`
+	Escalations                 int                        `json:"escalations"`
+}
+
+// SummarizeSLA builds one report per purpose found in tickets, ordered by
+// purpose.
+func SummarizeSLA(tickets []domain.SupportTicket, targets SLATargets, now time.Time) []SLAReport {
+	reports := make(map[domain.ConversationPurpose]*SLAReport)
+	firstResponseTotal := make(map[domain.ConversationPurpose]time.Duration)
+	resolutionTotal := make(map[domain.ConversationPurpose]time.Duration)
+	for _, ticket := range tickets {
+		report, ok := reports[ticket.Purpose]
+		if !ok {
+			target := targets[ticket.Purpose]
+			report = &SLAReport{
+				Purpose:                    ticket.Purpose,
+				FirstResponseTargetSeconds: int64(target.FirstResponse / time.Second),
+				ResolutionTargetSeconds:    int64(target.Resolution / time.Second),
+			}
+			reports[ticket.Purpose] = report
+		}
+		report.Tickets++
+		if ticket.FirstResponseAt.Valid {
+			report.Responded++
+			firstResponseTotal[ticket.Purpose] += ticket.FirstResponseAt.Time.Sub(ticket.CreatedAt)
+		}
+		if ticket.ResolvedAt.Valid {
+			report.Resolved++
+			resolutionTotal[ticket.Purpose] += ticket.ResolvedAt.Time.Sub(ticket.CreatedAt)
+		}
+		for _, breach := range targets[ticket.Purpose].Breaches(ticket, now) {
+			switch breach {
+			case domain.SLAKindFirstResponse:
+				report.FirstResponseBreaches++
+			case domain.SLAKindResolution:
+				report.ResolutionBreaches++
+			}
+		}
+	}
+
+	result := make([]SLAReport, 0, len(reports))
+	for purpose, report := range reports {
+		if report.Responded > 0 {
+			report.AverageFirstResponseSeconds = int64(firstResponseTotal[purpose]/time.Duration(report.Responded)) / int64(time.Second)
+		}
+		if report.Resolved > 0 {
+			report.AverageResolutionSeconds = int64(resolutionTotal[purpose]/time.Duration(report.Resolved)) / int64(time.Second)
+		}
+		result = append(result, *report)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Purpose < result[j].Purpose })
+	return result
+}
+
+// recordFirstResponse stamps the first reply to a support ticket from anyone
+// but its requester.
+func recordFirstResponse(db *gorm.DB, message *domain.Message) error {
+	return db.Model(&domain.SupportTicket{}).
+		Where("conversation_id = ? AND requester_id <> ? AND first_response_at IS NULL", message.ConversationID, message.SenderID).
+		Update("first_response_at", message.CreatedAt).Error
+}
+
+// SLAReport summarises tickets opened in [from, to) for supervisors.
+func (s *supportService) SLAReport(actorID uuid.UUID, from, to time.Time) ([]SLAReport, error) {
+	if err := requireStaffRole(s.db, actorID, domain.StaffRoleSupervisor); err != nil {
+		return nil, err
+	}
+	if !from.Before(to) {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "from must be before to")
+	}
+	var tickets []domain.SupportTicket
+	if err := s.db.Where("created_at >= ? AND created_at < ?", from, to).Find(&tickets).Error; err != nil {
+		return nil, apperror.Internal("Failed to load tickets", err)
+	}
+	reports := SummarizeSLA(tickets, s.targets, now())
+
+	var counts []struct {
+		Purpose domain.ConversationPurpose
+		Count   int
+	}
+	err := s.db.Model(&domain.SupportEscalation{}).
+		Select("support_tickets.purpose AS purpose, COUNT(*) AS count").
+		Joins("JOIN support_tickets ON support_tickets.id = support_escalations.ticket_id").
+		Where("support_tickets.created_at >= ? AND support_tickets.created_at < ?", from, to).
+		Group("support_tickets.purpose").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, apperror.Internal("Failed to count escalations", err)
+	}
+	for _, count := range counts {
+		for i := range reports {
+			if reports[i].Purpose == count.Purpose {
+				reports[i].Escalations = count.Count
+			}
+		}
+	}
+	return reports, nil
+}
+
+func (s *supportService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.escalateBreaches()
+		}
+	}
+}
+
+// escalateBreaches escalates open tickets that have missed a target they were
+// not yet escalated for.
+func (s *supportService) escalateBreaches() {
+	var tickets []domain.SupportTicket
+	if err := s.db.Where("status IN ?", []domain.SupportTicketStatus{domain.SupportTicketStatusQueued, domain.SupportTicketStatusAssigned}).
+		Order("created_at ASC").
+		Find(&tickets).Error; err != nil {
+		log.Printf("Warning: Failed to load open tickets for SLA checks: %v", err)
+		return
+	}
+	for i := range tickets {
+		for _, kind := range s.targets[tickets[i].Purpose].Breaches(tickets[i], now()) {
+			if err := s.escalate(&tickets[i], kind); err != nil {
+				log.Printf("Warning: Failed to escalate ticket %s (%s): %v", tickets[i].ID, kind, err)
+			}
+		}
+	}
+}
+
+var escalationMessages = map[domain.SLAKind]string{
+	domain.SLAKindFirstResponse: "This conversation was escalated because it was not answered in time.",
+	domain.SLAKindResolution:    "This conversation was escalated because it was not resolved in time.",
+}
+
+// escalate handles one missed target: a ticket not answered in time moves to
+// another available agent, supervisors are notified and the conversation gets
+// a system message. A ticket is escalated once per target.
+func (s *supportService) escalate(ticket *domain.SupportTicket, kind domain.SLAKind) error {
+	escalation := domain.SupportEscalation{
+		ID:              uuid.New(),
+		TicketID:        ticket.ID,
+		Kind:            kind,
+		PreviousAgentID: ticket.AgentID,
+		CreatedAt:       now(),
+	}
+	// The escalation is recorded with its system message, so one that
+	// failed halfway is tried again on the next check.
+	content := escalationMessages[kind]
+	var message *domain.Message
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&escalation)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		ticket.EscalatedAt = sql.NullTime{Time: now(), Valid: true}
+		if err := tx.Model(&domain.SupportTicket{}).Where("id = ?", ticket.ID).Update("escalated_at", ticket.EscalatedAt).Error; err != nil {
+			return err
+		}
+		var err error
+		message, err = createSystemMessage(tx, ticket.ConversationID, systemActorID, content)
+		return err
+	})
+	if err != nil || message == nil {
+		return err
+	}
+	log.Printf("Support ticket %s missed its %s target.\n", ticket.ID, kind)
+
+	if kind == domain.SLAKindFirstResponse {
+		if err := s.reassignEscalated(ticket, &escalation); err != nil {
+			log.Printf("Warning: Failed to reassign escalated ticket %s: %v", ticket.ID, err)
+		}
+	}
+
+	event := domain.Event{
+		Type:           domain.EventTicketEscalated,
+		ConversationID: ticket.ConversationID,
+		Data: map[string]interface{}{
+			"ticket_id":  ticket.ID.String(),
+			"kind":       kind,
+			"agent_id":   ticket.AgentID.String,
+			"message_id": message.ID.String(),
+			"content":    content,
+		},
+	}
+	s.notifier.BroadcastEvent(event)
+
+	var supervisors []domain.StaffMember
+	if err := s.db.Where("role = ?", domain.StaffRoleSupervisor).Find(&supervisors).Error; err != nil {
+		return err
+	}
+	for _, supervisor := range supervisors {
+		s.notifier.NotifyUser(supervisor.UserID, event)
+	}
+	return nil
+}
+
+// reassignEscalated moves an unanswered ticket to the available agent the
+// strategy picks among everyone but its current agent. Queued tickets are
+// simply assigned if anyone has room.
+func (s *supportService) reassignEscalated(ticket *domain.SupportTicket, escalation *domain.SupportEscalation) error {
+	if ticket.Status == domain.SupportTicketStatusQueued {
+		if err := s.assign(ticket); err != nil {
+			return err
+		}
+	} else {
+		var agents []domain.SupportAgent
+		if err := s.db.Where("available AND user_id <> ?", ticket.AgentID.String).Find(&agents).Error; err != nil {
+			return err
+		}
+		candidates, err := s.candidates(s.db, agents)
+		if err != nil {
+			return err
+		}
+		candidate, ok := s.strategy.Pick(candidates)
+		if !ok {
+			return nil
+		}
+		if _, err := s.moveTicket(ticket, candidate.AgentID, systemActorID); err != nil {
+			return err
+		}
+	}
+	if ticket.AgentID.Valid {
+		escalation.AgentID = ticket.AgentID
+		return s.db.Model(escalation).Update("agent_id", ticket.AgentID).Error
+	}
+	return nil
+}
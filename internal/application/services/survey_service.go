@@ -0,0 +1,245 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MinSurveyRating       = 1
+	MaxSurveyRating       = 5
+	maxSurveyCommentRunes = 1000
+
+	surveyQuestion = "How satisfied are you with the help you received? Please rate it from 1 to 5."
+)
+
+// Survey report groupings.
+const (
+	SurveyGroupByAgent   = "agent"
+	SurveyGroupByPurpose = "purpose"
+)
+
+// ValidateSurveyResponse checks a rating and its optional comment.
+func ValidateSurveyResponse(rating int, comment string) error {
+	if rating < MinSurveyRating || rating > MaxSurveyRating {
+		return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("rating must be between %d and %d", MinSurveyRating, MaxSurveyRating))
+	}
+	if utf8.RuneCountInString(comment) > maxSurveyCommentRunes {
+		return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("comment must be at most %d characters", maxSurveyCommentRunes))
+	}
+	return nil
+}
+
+// surveyParties returns who a resolved conversation's survey goes to and who
+// it rates: the requester and agent of a support ticket, or the revert and
+// mentor of a mentorship. ok is false for conversations without a survey.
+func surveyParties(db *gorm.DB, conversation *domain.Conversation) (respondentID uuid.UUID, agentID sql.NullString, ok bool, err error) {
+	switch {
+	case conversation.Purpose.IsSupport():
+		var ticket domain.SupportTicket
+		err = db.Where("conversation_id = ?", conversation.ID).First(&ticket).Error
+		if err == nil {
+			return ticket.RequesterID, ticket.AgentID, true, nil
+		}
+	case conversation.Purpose == domain.ConversationPurposeRevertService:
+		var mentorship domain.Mentorship
+		err = db.Where("conversation_id = ?", conversation.ID).Order("created_at DESC").First(&mentorship).Error
+		if err == nil {
+			return mentorship.RevertID, mentorship.MentorID, true, nil
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return conversation.CreatorID, sql.NullString{}, true, nil
+		}
+	default:
+		return uuid.Nil, sql.NullString{}, false, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, sql.NullString{}, false, nil
+	}
+	return uuid.Nil, sql.NullString{}, false, err
+}
+
+// sendSurvey posts a survey message to a resolved conversation and records
+// the survey it asks about.
+func (s *chatService) sendSurvey(conversation *domain.Conversation, actorID uuid.UUID) error {
+	respondentID, agentID, ok, err := surveyParties(s.db, conversation)
+	if err != nil || !ok {
+		return err
+	}
+
+	survey := domain.SatisfactionSurvey{
+		ID:             uuid.New(),
+		ConversationID: conversation.ID,
+		Purpose:        conversation.Purpose,
+		RespondentID:   respondentID,
+		AgentID:        agentID,
+		CreatedAt:      now(),
+	}
+	metadata, err := json.Marshal(map[string]interface{}{
+		"survey_id":     survey.ID.String(),
+		"respondent_id": respondentID.String(),
+		"min_rating":    MinSurveyRating,
+		"max_rating":    MaxSurveyRating,
+	})
+	if err != nil {
+		return err
+	}
+	message := domain.Message{
+		ID:             uuid.New(),
+		ConversationID: conversation.ID,
+		SenderID:       actorID,
+		Content:        surveyQuestion,
+		MessageType:    string(domain.MessageTypeSurvey),
+		Metadata:       metadata,
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+	}
+	survey.MessageID = message.ID
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&message).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.Conversation{}).Where("id = ?", conversation.ID).UpdateColumn("last_message_id", message.ID.String()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&survey).Error
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Satisfaction survey %s sent to %s in conversation %s\n", survey.ID, respondentID, conversation.ID)
+	s.notifier.BroadcastMessage(&message)
+	return nil
+}
+
+// SubmitSurveyResponse records the respondent's rating of a survey sent to
+// the conversation. A survey can be answered once.
+func (s *chatService) SubmitSurveyResponse(userID uuid.UUID, conversationID uuid.UUID, surveyID uuid.UUID, rating int, comment string) (*domain.SatisfactionSurvey, error) {
+	if err := ValidateSurveyResponse(rating, comment); err != nil {
+		return nil, err
+	}
+	var survey domain.SatisfactionSurvey
+	if err := s.db.Where("id = ? AND conversation_id = ?", surveyID, conversationID).First(&survey).Error; err != nil {
+		return nil, notFoundOrInternal("Survey not found", err)
+	}
+	if survey.RespondentID != userID {
+		return nil, apperror.New(apperror.CodeForbidden, "This survey was not sent to you")
+	}
+
+	answeredAt := now()
+	result := s.db.Model(&domain.SatisfactionSurvey{}).
+		Where("id = ? AND answered_at IS NULL", survey.ID).
+		Updates(map[string]interface{}{"rating": rating, "comment": comment, "answered_at": answeredAt})
+	if result.Error != nil {
+		return nil, apperror.Internal("Failed to save survey response", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, apperror.New(apperror.CodeConflict, "This survey was already answered")
+	}
+	survey.Rating = sql.NullInt16{Int16: int16(rating), Valid: true}
+	survey.Comment = comment
+	survey.AnsweredAt = sql.NullTime{Time: answeredAt, Valid: true}
+
+	log.Printf("Survey %s answered by %s with rating %d\n", survey.ID, userID, rating)
+	s.notifier.BroadcastEvent(domain.Event{
+		Type:           domain.EventSurveyAnswered,
+		ConversationID: conversationID,
+		Data:           map[string]interface{}{"survey_id": survey.ID.String(), "rating": rating},
+	})
+	return &survey, nil
+}
+
+// SurveySummary aggregates the surveys of one agent or purpose. Ratings
+// counts answers per rating, from 1 to 5.
+type SurveySummary struct {
+	Group         string               `json:"group"`
+	Sent          int                  `json:"sent"`
+	Answered      int                  `json:"answered"`
+	AverageRating float64              `json:"average_rating"`
+	Ratings       [MaxSurveyRating]int `json:"ratings"`
+}
+
+// SummarizeSurveys groups surveys by agent or by purpose, ordered by group.
+// Surveys without an agent are grouped as "unassigned".
+func SummarizeSurveys(surveys []domain.SatisfactionSurvey, groupBy string) ([]SurveySummary, error) {
+	if groupBy != SurveyGroupByAgent && groupBy != SurveyGroupByPurpose {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "group_by must be agent or purpose")
+	}
+	summaries := make(map[string]*SurveySummary)
+	totals := make(map[string]int)
+	for _, survey := range surveys {
+		group := string(survey.Purpose)
+		if groupBy == SurveyGroupByAgent {
+			group = "unassigned"
+			if survey.AgentID.Valid {
+				group = survey.AgentID.String
+			}
+		}
+		summary, ok := summaries[group]
+		if !ok {
+			summary = &SurveySummary{Group: group}
+			summaries[group] = summary
+		}
+		summary.Sent++
+		if !survey.Rating.Valid {
+			continue
+		}
+		rating := int(survey.Rating.Int16)
+		if rating < MinSurveyRating || rating > MaxSurveyRating {
+			continue
+		}
+		summary.Answered++
+		summary.Ratings[rating-1]++
+		totals[group] += rating
+	}
+
+	result := make([]SurveySummary, 0, len(summaries))
+	for group, summary := range summaries {
+		if summary.Answered > 0 {
+			summary.AverageRating = float64(totals[group]) / float64(summary.Answered)
+		}
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Group < result[j].Group })
+	return result, nil
+}
+
+type SurveyService interface {
+	// Report summarises surveys sent in [from, to) for supervisors.
+	Report(actorID uuid.UUID, groupBy string, from, to time.Time) ([]SurveySummary, error)
+}
+
+type surveyService struct {
+	db *gorm.DB
+}
+
+func NewSurveyService(db *gorm.DB) SurveyService {
+	return &surveyService{db: db}
+}
+
+func (s *surveyService) Report(actorID uuid.UUID, groupBy string, from, to time.Time) ([]SurveySummary, error) {
+	if err := requireStaffRole(s.db, actorID, domain.StaffRoleSupervisor); err != nil {
+		return nil, err
+	}
+	if !from.Before(to) {
+		return nil, apperror.New(apperror.CodeInvalidRequest, "from must be before to")
+	}
+	var surveys []domain.SatisfactionSurvey
+	if err := s.db.Where("created_at >= ? AND created_at < ?", from, to).Find(&surveys).Error; err != nil {
+		return nil, apperror.Internal("Failed to load surveys", err)
+	}
+	return SummarizeSurveys(surveys, groupBy)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/gorm"
+)
+
+// threadRootOf returns the root of the thread a reply to targetID joins: the
+// target itself, or the root of the thread the target is a reply in. The
+// target must be a message of the conversation that was not deleted.
+func threadRootOf(db *gorm.DB, conversationID uuid.UUID, targetID uuid.UUID) (*domain.Message, error) {
+	var target domain.Message
+	err := db.Where("id = ? AND conversation_id = ?", targetID, conversationID).First(&target).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.Wrap(apperror.CodeInvalidRequest, "The message you replied to is not in this conversation", err)
+		}
+		return nil, apperror.Internal("Failed to load replied message", err)
+	}
+	if !target.ThreadRootID.Valid {
+		return &target, nil
+	}
+	var root domain.Message
+	if err := db.Unscoped().First(&root, "id = ?", target.ThreadRootID.String).Error; err != nil {
+		return nil, apperror.Internal("Failed to load thread", err)
+	}
+	return &root, nil
+}
+
+// addThreadReply counts a reply on its thread root. It runs in the
+// transaction that stores the reply.
+func addThreadReply(tx *gorm.DB, root *domain.Message, reply *domain.Message) error {
+	if err := tx.Model(&domain.Message{}).Unscoped().Where("id = ?", root.ID).UpdateColumns(map[string]interface{}{
+		"reply_count":   gorm.Expr("reply_count + 1"),
+		"last_reply_at": reply.CreatedAt,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Select("reply_count").First(root, "id = ?", root.ID).Error; err != nil {
+		return err
+	}
+	root.LastReplyAt = sql.NullTime{Time: reply.CreatedAt, Valid: true}
+	return nil
+}
+
+// GetThreadRoot returns the root of the thread a message starts or belongs
+// to, for a participant of its conversation.
+func (s *chatService) GetThreadRoot(userID uuid.UUID, messageID uuid.UUID) (*domain.Message, error) {
+	var message domain.Message
+	if err := s.db.First(&message, "id = ?", messageID).Error; err != nil {
+		return nil, notFoundOrInternal("Message not found", err)
+	}
+	if _, err := requireParticipant(s.db, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	if !message.ThreadRootID.Valid {
+		return &message, nil
+	}
+	var root domain.Message
+	if err := s.db.First(&root, "id = ?", message.ThreadRootID.String).Error; err != nil {
+		return nil, notFoundOrInternal("Thread not found", err)
+	}
+	return &root, nil
+}
+
+// GetThread returns the root of a thread and a page of its replies, oldest
+// first.
+func (s *chatService) GetThread(userID uuid.UUID, messageID uuid.UUID, limit, offset int) (*domain.Message, []domain.Message, error) {
+	root, err := s.GetThreadRoot(userID, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var replies []domain.Message
+	if err := s.db.Where("thread_root_id = ?", root.ID.String()).
+		Order("created_at ASC").Limit(limit).Offset(offset).
+		Find(&replies).Error; err != nil {
+		return nil, nil, apperror.Internal("Failed to get thread", err)
+	}
+	return root, replies, nil
+}
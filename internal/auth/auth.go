@@ -12,6 +12,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
 )
 
 type AuthContextKey string
@@ -85,6 +86,25 @@ func GetUserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
 }
 
 func VerifyJWTForWebSocket(r *http.Request) (uuid.UUID, error) {
+	return VerifyJWT(r)
+}
+
+// RequireAuth verifies the bearer token of a REST request and stores the user
+// ID in the request context, where GetUserIDFromContext can read it.
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := VerifyJWT(r)
+		if err != nil {
+			log.Printf("HTTP authentication failed for %s %s: %v", r.Method, r.URL.Path, err)
+			apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeUnauthorized, "Unauthorized", err))
+			return
+		}
+		ctx := context.WithValue(r.Context(), UserIDContextKey, userID.String())
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func VerifyJWT(r *http.Request) (uuid.UUID, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		return uuid.Nil, fmt.Errorf("authorization header required")
@@ -98,7 +118,7 @@ func VerifyJWTForWebSocket(r *http.Request) (uuid.UUID, error) {
 	tokenString := bearerToken[1]
 	accessSecret := os.Getenv("ACCESS_SECRET")
 	if accessSecret == "" {
-		log.Println("ACCESS_SECRET not set for WebSocket authentication")
+		log.Println("ACCESS_SECRET not set for authentication")
 		return uuid.Nil, fmt.Errorf("server configuration error: ACCESS_SECRET not set")
 	}
 
@@ -110,7 +130,7 @@ func VerifyJWTForWebSocket(r *http.Request) (uuid.UUID, error) {
 	})
 
 	if err != nil {
-		log.Printf("Error parsing token (WebSocket): %v", err)
+		log.Printf("Error parsing token: %v", err)
 		return uuid.Nil, fmt.Errorf("invalid token: %w", err)
 	}
 
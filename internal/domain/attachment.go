@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AttachmentVariant string
+
+const (
+	AttachmentVariantOriginal  AttachmentVariant = ""
+	AttachmentVariantThumbnail AttachmentVariant = "thumbnail"
+)
+
+func (v AttachmentVariant) IsValid() bool {
+	switch v {
+	case AttachmentVariantOriginal, AttachmentVariantThumbnail:
+		return true
+	}
+	return false
+}
+
+type Attachment struct {
+	ID             uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	ConversationID uuid.UUID      `gorm:"column:conversation_id;not null;type:char(36);index" json:"conversation_id"`
+	UploaderID     uuid.UUID      `gorm:"column:uploader_id;not null;type:char(36)" json:"uploader_id"`
+	FileName       string         `gorm:"column:file_name;type:varchar(255);not null" json:"file_name"`
+	MimeType       string         `gorm:"column:mime_type;type:varchar(255);not null" json:"mime_type"`
+	Size           int64          `gorm:"column:size;not null" json:"size"`
+	Checksum       string         `gorm:"column:checksum;type:char(64);not null" json:"checksum"` // hex-encoded SHA-256
+	StorageKey     string         `gorm:"column:storage_key;type:varchar(512);not null" json:"-"`
+	Width          int            `gorm:"column:width" json:"width,omitempty"`
+	Height         int            `gorm:"column:height" json:"height,omitempty"`
+	ThumbnailKey   string         `gorm:"column:thumbnail_key;type:varchar(512)" json:"-"`
+	Preview        string         `gorm:"column:preview;type:text" json:"preview,omitempty"` // blurred data URI placeholder
+	CreatedAt      time.Time      `json:"created_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (a *Attachment) HasThumbnail() bool {
+	return a.ThumbnailKey != ""
+}
@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserBlock records that BlockerID does not want to hear from BlockedID.
+type UserBlock struct {
+	BlockerID uuid.UUID `gorm:"column:blocker_id;primaryKey;type:char(36)" json:"blocker_id"`
+	BlockedID uuid.UUID `gorm:"column:blocked_id;primaryKey;type:char(36);index" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutOfHoursReply remembers the last out-of-hours auto-reply posted in a
+// conversation, so further messages get no new one until SuppressedUntil,
+// when the team is back.
+type OutOfHoursReply struct {
+	ConversationID  uuid.UUID `gorm:"column:conversation_id;primaryKey;type:char(36)" json:"conversation_id"`
+	SuppressedUntil time.Time `gorm:"column:suppressed_until;not null" json:"suppressed_until"`
+	SentAt          time.Time `gorm:"column:sent_at;not null" json:"sent_at"`
+}
@@ -33,6 +33,14 @@ const (
 	ConversationPurposeAdminSupport   ConversationPurpose = "admin_support"
 )
 
+// ConversationPurposes lists every valid conversation purpose.
+func ConversationPurposes() []ConversationPurpose {
+	return []ConversationPurpose{
+		ConversationPurposeNikkah, ConversationPurposeRevertService,
+		ConversationPurposeGeneralSupport, ConversationPurposeAdminSupport,
+	}
+}
+
 func (cp ConversationPurpose) IsValid() bool {
 	switch cp {
 	case ConversationPurposeNikkah, ConversationPurposeRevertService,
@@ -42,6 +50,48 @@ func (cp ConversationPurpose) IsValid() bool {
 	return false
 }
 
+type ConversationStatus string
+
+const (
+	ConversationStatusOpen     ConversationStatus = "open"
+	ConversationStatusPending  ConversationStatus = "pending"
+	ConversationStatusResolved ConversationStatus = "resolved"
+	ConversationStatusClosed   ConversationStatus = "closed"
+)
+
+func (cs ConversationStatus) IsValid() bool {
+	switch cs {
+	case ConversationStatusOpen, ConversationStatusPending, ConversationStatusResolved, ConversationStatusClosed:
+		return true
+	}
+	return false
+}
+
+// conversationStatusTransitions lists the statuses each status can move to.
+// Pending means waiting for the user; resolved conversations reopen when the
+// user writes again; closed ones only reopen explicitly.
+var conversationStatusTransitions = map[ConversationStatus][]ConversationStatus{
+	ConversationStatusOpen:     {ConversationStatusPending, ConversationStatusResolved, ConversationStatusClosed},
+	ConversationStatusPending:  {ConversationStatusOpen, ConversationStatusResolved, ConversationStatusClosed},
+	ConversationStatusResolved: {ConversationStatusOpen, ConversationStatusClosed},
+	ConversationStatusClosed:   {ConversationStatusOpen},
+}
+
+func (cs ConversationStatus) CanTransitionTo(to ConversationStatus) bool {
+	for _, allowed := range conversationStatusTransitions[cs] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSupport reports whether conversations of this purpose are support tickets
+// answered by agents rather than chats with a chosen partner.
+func (cp ConversationPurpose) IsSupport() bool {
+	return cp == ConversationPurposeGeneralSupport || cp == ConversationPurposeAdminSupport
+}
+
 type Conversation struct {
 	ID            uuid.UUID                 `gorm:"primaryKey;type:char(36)" json:"id"`
 	CreatorID     uuid.UUID                 `gorm:"column:creator_id;not null;type:char(36)" json:"creator_id"`
@@ -51,6 +101,8 @@ type Conversation struct {
 	Description   sql.NullString            `gorm:"column:description" json:"description"`
 	Creator       User                      `gorm:"foreignKey:CreatorID;references:ID"`
 	LastMessageID sql.NullString            `gorm:"column:last_message_id" json:"last_message_id"`
+	ApprovedAt    sql.NullTime              `gorm:"column:approved_at" json:"approved_at"` // until set, nikkah chats withhold contact details
+	Status        ConversationStatus        `gorm:"column:status;type:varchar(20);not null;default:'open';index" json:"status"`
 	CreatedAt     time.Time                 `json:"created_at"`
 	UpdatedAt     time.Time                 `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt            `gorm:"index" json:"-"`
@@ -65,5 +117,11 @@ func (c *Conversation) BeforeSave(tx *gorm.DB) (err error) {
 	if !c.Purpose.IsValid() {
 		return fmt.Errorf("invalid conversation purpose: %s", c.Purpose)
 	}
+	if c.Status == "" {
+		c.Status = ConversationStatusOpen
+	}
+	if !c.Status.IsValid() {
+		return fmt.Errorf("invalid conversation status: %s", c.Status)
+	}
 	return nil
 }
@@ -6,6 +6,13 @@ import (
 	"time"
 )
 
+const (
+	ParticipantRoleMember = "member"
+	// ParticipantRoleObserver can read a conversation but not write to it. It
+	// is used for guardians in nikkah conversations.
+	ParticipantRoleObserver = "observer"
+)
+
 type ConversationParticipant struct {
 	ConversationID uuid.UUID    `gorm:"column:conversation_id;primaryKey;type:char(36)" json:"conversation_id"`
 	UserID         uuid.UUID    `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
@@ -13,6 +20,20 @@ type ConversationParticipant struct {
 	LeftAt         sql.NullTime `gorm:"column:left_at" json:"left_at"`
 	Role           string       `gorm:"column:role;type:varchar(50);default:'member'" json:"role"`
 
+	// ApprovalRequired is set for observers whose approval the conversation
+	// needs before messages can be sent; ApprovedAt records that approval.
+	ApprovalRequired bool         `gorm:"column:approval_required;not null;default:false" json:"approval_required"`
+	ApprovedAt       sql.NullTime `gorm:"column:approved_at" json:"approved_at"`
+
+	// NotificationLevel overrides the user's level for this conversation when
+	// set; MutedUntil silences it until then.
+	NotificationLevel NotificationLevel `gorm:"column:notification_level;type:varchar(20);not null;default:''" json:"notification_level,omitempty"`
+	MutedUntil        sql.NullTime      `gorm:"column:muted_until" json:"muted_until"`
+
+	// DigestedUntil is the creation time of the newest message included in
+	// an email digest to this participant.
+	DigestedUntil sql.NullTime `gorm:"column:digested_until" json:"-"`
+
 	LastReadMessageID sql.NullString `gorm:"column:last_read_message_id" json:"last_read_message_id"`
 	LastReadMessage   *Message       `gorm:"foreignKey:LastReadMessageID;references:ID"`
 
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailDigest records an email summarising a user's unread messages.
+type EmailDigest struct {
+	ID                uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID            uuid.UUID `gorm:"column:user_id;not null;type:char(36);index" json:"user_id"`
+	Email             string    `gorm:"column:email;type:varchar(320);not null" json:"email"`
+	MessageCount      int       `gorm:"column:message_count;not null" json:"message_count"`
+	ConversationCount int       `gorm:"column:conversation_count;not null" json:"conversation_count"`
+	SentAt            time.Time `gorm:"column:sent_at;not null;index" json:"sent_at"`
+}
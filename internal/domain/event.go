@@ -0,0 +1,39 @@
+package domain
+
+import "github.com/google/uuid"
+
+type EventType string
+
+const (
+	EventMessageDeleted EventType = "message_deleted"
+	EventUserMuted      EventType = "user_muted"
+	EventUserBanned     EventType = "user_banned"
+
+	EventConversationApproved EventType = "conversation_approved"
+	EventObserverLeft         EventType = "observer_left"
+
+	EventTicketAssigned    EventType = "ticket_assigned"
+	EventTicketTransferred EventType = "ticket_transferred"
+	EventTicketEscalated   EventType = "ticket_escalated"
+
+	EventConversationStatusChanged EventType = "conversation_status_changed"
+	EventSurveyAnswered            EventType = "survey_answered"
+
+	EventMentorshipProposed   EventType = "mentorship_proposed"
+	EventMentorshipAccepted   EventType = "mentorship_accepted"
+	EventMentorshipReassigned EventType = "mentorship_reassigned"
+
+	EventMentioned     EventType = "mentioned"
+	EventThreadUpdated EventType = "thread_updated"
+
+	EventMessagePinned   EventType = "message_pinned"
+	EventMessageUnpinned EventType = "message_unpinned"
+)
+
+// Event is a server-initiated frame pushed to connected clients, as opposed
+// to a chat message. Clients tell them apart by the "event" key.
+type Event struct {
+	Type           EventType   `json:"event"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	Data           interface{} `json:"data,omitempty"`
+}
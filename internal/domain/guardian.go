@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Guardian designates the wali or mahram of a user. The guardian is added as
+// an observer to every private nikkah conversation the user starts or is
+// invited to.
+type Guardian struct {
+	UserID          uuid.UUID `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
+	GuardianID      uuid.UUID `gorm:"column:guardian_id;not null;type:char(36);index" json:"guardian_id"`
+	RequireApproval bool      `gorm:"column:require_approval;not null;default:false" json:"require_approval"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MatchStatus string
+
+const (
+	MatchStatusPending  MatchStatus = "pending"
+	MatchStatusApproved MatchStatus = "approved"
+	MatchStatusEnded    MatchStatus = "ended"
+)
+
+func (s MatchStatus) IsValid() bool {
+	switch s {
+	case MatchStatusPending, MatchStatusApproved, MatchStatusEnded:
+		return true
+	}
+	return false
+}
+
+// Match pairs two users for a conversation purpose, e.g. prospective spouses
+// introduced by the nikkah service. The pair is unordered.
+type Match struct {
+	ID         uuid.UUID           `gorm:"type:char(36);primaryKey" json:"id"`
+	Purpose    ConversationPurpose `gorm:"column:purpose;type:varchar(50);not null;index" json:"purpose"`
+	UserID     uuid.UUID           `gorm:"column:user_id;not null;type:char(36);index" json:"user_id"`
+	PartnerID  uuid.UUID           `gorm:"column:partner_id;not null;type:char(36);index" json:"partner_id"`
+	Status     MatchStatus         `gorm:"column:status;type:varchar(20);not null" json:"status"`
+	ApprovedAt sql.NullTime        `gorm:"column:approved_at" json:"approved_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
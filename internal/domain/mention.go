@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageMention records that a message mentions a participant, so their
+// mentions can be listed. The message's metadata holds the same mentions with
+// their position in the content.
+type MessageMention struct {
+	MessageID      uuid.UUID `gorm:"column:message_id;primaryKey;type:char(36)" json:"message_id"`
+	UserID         uuid.UUID `gorm:"column:user_id;primaryKey;type:char(36);index" json:"user_id"`
+	ConversationID uuid.UUID `gorm:"column:conversation_id;not null;type:char(36)" json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
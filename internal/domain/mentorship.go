@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MentorProfile is a mentor's offer to guide new Muslims. Languages is a
+// comma separated list of lower-case language codes; Gender is copied from
+// the Limestone profile at registration.
+type MentorProfile struct {
+	UserID        uuid.UUID    `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
+	Gender        Gender       `gorm:"column:gender;type:varchar(20);not null" json:"gender"`
+	Languages     string       `gorm:"column:languages;type:varchar(255);not null" json:"languages"`
+	Capacity      int          `gorm:"column:capacity;not null" json:"capacity"`
+	Active        bool         `gorm:"column:active;not null" json:"active"`
+	LastMatchedAt sql.NullTime `gorm:"column:last_matched_at" json:"last_matched_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// LanguageList splits Languages.
+func (p MentorProfile) LanguageList() []string {
+	return SplitLanguages(p.Languages)
+}
+
+// SplitLanguages parses a comma separated language list, dropping blanks.
+func SplitLanguages(languages string) []string {
+	var list []string
+	for _, language := range strings.Split(languages, ",") {
+		if language = strings.ToLower(strings.TrimSpace(language)); language != "" {
+			list = append(list, language)
+		}
+	}
+	return list
+}
+
+type MentorshipStatus string
+
+const (
+	// MentorshipStatusRequested waits for a mentor to be proposed, either for
+	// a new request or after the previous mentor became unavailable.
+	MentorshipStatusRequested MentorshipStatus = "requested"
+	MentorshipStatusProposed  MentorshipStatus = "proposed"
+	MentorshipStatusActive    MentorshipStatus = "active"
+	MentorshipStatusEnded     MentorshipStatus = "ended"
+)
+
+func (s MentorshipStatus) IsValid() bool {
+	switch s {
+	case MentorshipStatusRequested, MentorshipStatusProposed, MentorshipStatusActive, MentorshipStatusEnded:
+		return true
+	}
+	return false
+}
+
+// Mentorship pairs a revert with a mentor. The conversation is created when
+// the first mentor accepts and is kept when a mentor is replaced.
+type Mentorship struct {
+	ID             uuid.UUID        `gorm:"type:char(36);primaryKey" json:"id"`
+	RevertID       uuid.UUID        `gorm:"column:revert_id;not null;type:char(36);index" json:"revert_id"`
+	Gender         Gender           `gorm:"column:gender;type:varchar(20);not null" json:"gender"`
+	Languages      string           `gorm:"column:languages;type:varchar(255);not null" json:"languages"`
+	Status         MentorshipStatus `gorm:"column:status;type:varchar(20);not null;index" json:"status"`
+	MentorID       sql.NullString   `gorm:"column:mentor_id;type:char(36);index" json:"mentor_id"`
+	ConversationID sql.NullString   `gorm:"column:conversation_id;type:char(36)" json:"conversation_id"`
+	ProposedAt     sql.NullTime     `gorm:"column:proposed_at" json:"proposed_at"`
+	AcceptedAt     sql.NullTime     `gorm:"column:accepted_at" json:"accepted_at"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// MentorshipDecline remembers mentors who declined, let a proposal lapse or
+// were replaced, so they are not proposed for the same mentorship again.
+type MentorshipDecline struct {
+	MentorshipID uuid.UUID `gorm:"column:mentorship_id;primaryKey;type:char(36)" json:"mentorship_id"`
+	MentorID     uuid.UUID `gorm:"column:mentor_id;primaryKey;type:char(36)" json:"mentor_id"`
+	Reason       string    `gorm:"column:reason;type:varchar(50);not null" json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 )
 
+// Message is a chat message. A reply belongs to the thread started by
+// ThreadRootID, and a thread root counts its replies.
 type Message struct {
 	ID               uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
 	ConversationID   uuid.UUID       `gorm:"column:conversation_id;not null;type:char(36)" json:"conversation_id"`
@@ -17,6 +19,10 @@ type Message struct {
 	MediaURL         sql.NullString  `gorm:"column:media_url" json:"media_url"`
 	Metadata         json.RawMessage `gorm:"column:metadata;type:jsonb" json:"metadata"`
 	ReplyToMessageID sql.NullString  `gorm:"column:reply_to_message_id" json:"reply_to_message_id"`
+	ThreadRootID     sql.NullString  `gorm:"column:thread_root_id;type:char(36);index" json:"thread_root_id"`
+	ReplyCount       int             `gorm:"column:reply_count;not null;default:0" json:"reply_count"`
+	LastReplyAt      sql.NullTime    `gorm:"column:last_reply_at" json:"last_reply_at"`
+	AttachmentID     sql.NullString  `gorm:"column:attachment_id;type:char(36)" json:"attachment_id"`
 	CreatedAt        time.Time       `json:"created_at"`
 	UpdatedAt        time.Time       `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt  `gorm:"index" json:"-"`
@@ -24,6 +30,8 @@ type Message struct {
 	Conversation   Conversation `gorm:"foreignKey:ConversationID;references:ID"`
 	Sender         User         `gorm:"foreignKey:SenderID;references:ID"`
 	ReplyToMessage *Message     `gorm:"foreignKey:ReplyToMessageID;references:ID"`
+	ThreadRoot     *Message     `gorm:"foreignKey:ThreadRootID;references:ID" json:"-"`
+	Attachment     *Attachment  `gorm:"foreignKey:AttachmentID;references:ID"`
 
 	MessageReads []MessageRead `gorm:"foreignKey:MessageID" json:"-"`
 }
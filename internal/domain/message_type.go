@@ -0,0 +1,14 @@
+package domain
+
+type MessageType string
+
+const (
+	MessageTypeText        MessageType = "text"
+	MessageTypeImage       MessageType = "image"
+	MessageTypeFile        MessageType = "file"
+	MessageTypeAudio       MessageType = "audio"
+	MessageTypeLocation    MessageType = "location"
+	MessageTypeContactCard MessageType = "contact_card"
+	MessageTypeSystem      MessageType = "system"
+	MessageTypeSurvey      MessageType = "survey"
+)
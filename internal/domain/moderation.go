@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ModerationAction string
+
+// Actions are ordered by severity; the pipeline keeps the most severe one.
+const (
+	ModerationActionAllow  ModerationAction = "allow"
+	ModerationActionMask   ModerationAction = "mask"
+	ModerationActionFlag   ModerationAction = "flag"
+	ModerationActionReject ModerationAction = "reject"
+)
+
+func (a ModerationAction) Severity() int {
+	switch a {
+	case ModerationActionMask:
+		return 1
+	case ModerationActionFlag:
+		return 2
+	case ModerationActionReject:
+		return 3
+	}
+	return 0
+}
+
+// ModerationDecision is the audit record of a filter acting on a message.
+// MessageID is empty when the message was rejected and never stored.
+type ModerationDecision struct {
+	ID              uuid.UUID        `gorm:"type:char(36);primaryKey" json:"id"`
+	MessageID       sql.NullString   `gorm:"column:message_id;type:char(36);index" json:"message_id"`
+	ConversationID  uuid.UUID        `gorm:"column:conversation_id;not null;type:char(36);index" json:"conversation_id"`
+	SenderID        uuid.UUID        `gorm:"column:sender_id;not null;type:char(36)" json:"sender_id"`
+	Filter          string           `gorm:"column:filter;type:varchar(50);not null" json:"filter"`
+	Action          ModerationAction `gorm:"column:action;type:varchar(20);not null" json:"action"`
+	Reason          string           `gorm:"column:reason;not null" json:"reason"`
+	OriginalContent string           `gorm:"column:original_content" json:"original_content"`
+	CreatedAt       time.Time        `json:"created_at"`
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationType string
+
+const (
+	// NotificationTypeMessage tells a recipient who was offline that a
+	// message arrived.
+	NotificationTypeMessage NotificationType = "message"
+	// NotificationTypeMention tells a participant they were mentioned.
+	NotificationTypeMention NotificationType = "mention"
+)
+
+// NotificationLevel says which notifications a user wants.
+type NotificationLevel string
+
+const (
+	NotificationLevelAll      NotificationLevel = "all"
+	NotificationLevelMentions NotificationLevel = "mentions"
+	NotificationLevelNone     NotificationLevel = "none"
+)
+
+func (l NotificationLevel) IsValid() bool {
+	switch l {
+	case NotificationLevelAll, NotificationLevelMentions, NotificationLevelNone:
+		return true
+	}
+	return false
+}
+
+// Allows reports whether the level lets notifications of a type through.
+func (l NotificationLevel) Allows(notificationType NotificationType) bool {
+	switch l {
+	case NotificationLevelAll:
+		return true
+	case NotificationLevelMentions:
+		return notificationType == NotificationTypeMention
+	}
+	return false
+}
+
+// NotificationSetting is a user's notification level for every conversation
+// that does not set its own, and an optional mute of everything until a
+// time.
+type NotificationSetting struct {
+	UserID     uuid.UUID         `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
+	Level      NotificationLevel `gorm:"column:level;type:varchar(20);not null" json:"level"`
+	MutedUntil sql.NullTime      `gorm:"column:muted_until" json:"muted_until"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending   NotificationStatus = "pending"
+	NotificationStatusDelivered NotificationStatus = "delivered"
+	// NotificationStatusSkipped notifications were not sent because the
+	// recipient saw the message live or muted it, or no gateway is
+	// configured.
+	NotificationStatusSkipped NotificationStatus = "skipped"
+	// NotificationStatusDead notifications failed every attempt, or were
+	// rejected by the gateway, and wait for a supervisor to retry them.
+	NotificationStatusDead NotificationStatus = "dead"
+)
+
+func (s NotificationStatus) IsValid() bool {
+	switch s {
+	case NotificationStatusPending, NotificationStatusDelivered, NotificationStatusSkipped, NotificationStatusDead:
+		return true
+	}
+	return false
+}
+
+// OutboxNotification is a notification to one recipient, written in the same
+// transaction as the message it is about and delivered to the push gateway
+// by the dispatcher. NextAttemptAt is when the dispatcher picks it up next.
+type OutboxNotification struct {
+	ID             uuid.UUID          `gorm:"type:char(36);primaryKey" json:"id"`
+	Type           NotificationType   `gorm:"column:type;type:varchar(50);not null" json:"type"`
+	RecipientID    uuid.UUID          `gorm:"column:recipient_id;not null;type:char(36);index" json:"recipient_id"`
+	ConversationID uuid.UUID          `gorm:"column:conversation_id;not null;type:char(36)" json:"conversation_id"`
+	MessageID      uuid.UUID          `gorm:"column:message_id;not null;type:char(36)" json:"message_id"`
+	Status         NotificationStatus `gorm:"column:status;type:varchar(20);not null;index:idx_outbox_notifications_due,priority:1" json:"status"`
+	Attempts       int                `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time          `gorm:"column:next_attempt_at;not null;index:idx_outbox_notifications_due,priority:2" json:"next_attempt_at"`
+	LastError      string             `gorm:"column:last_error;type:text" json:"last_error,omitempty"`
+	DeliveredAt    sql.NullTime       `gorm:"column:delivered_at" json:"delivered_at"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
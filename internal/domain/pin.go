@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PinnedMessage is a message pinned at the top of its conversation, such as
+// the documents needed for a nikkah.
+type PinnedMessage struct {
+	ConversationID uuid.UUID `gorm:"column:conversation_id;primaryKey;type:char(36)" json:"conversation_id"`
+	MessageID      uuid.UUID `gorm:"column:message_id;primaryKey;type:char(36)" json:"message_id"`
+	PinnedBy       uuid.UUID `gorm:"column:pinned_by;not null;type:char(36)" json:"pinned_by"`
+	PinnedAt       time.Time `gorm:"column:pinned_at;not null" json:"pinned_at"`
+
+	// Message is loaded by the pin service when listing pins.
+	Message *Message `gorm:"-" json:"-"`
+}
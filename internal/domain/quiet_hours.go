@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PrayerMethod is a standard set of sun angles used to calculate prayer
+// times.
+type PrayerMethod string
+
+const (
+	// PrayerMethodMWL is the Muslim World League: Fajr 18°, Isha 17°.
+	PrayerMethodMWL PrayerMethod = "mwl"
+	// PrayerMethodISNA is the Islamic Society of North America: Fajr 15°,
+	// Isha 15°.
+	PrayerMethodISNA PrayerMethod = "isna"
+	// PrayerMethodEgypt is the Egyptian General Authority of Survey: Fajr
+	// 19.5°, Isha 17.5°.
+	PrayerMethodEgypt PrayerMethod = "egypt"
+	// PrayerMethodMakkah is Umm al-Qura, Makkah: Fajr 18.5°, Isha 90 minutes
+	// after Maghrib.
+	PrayerMethodMakkah PrayerMethod = "makkah"
+	// PrayerMethodKarachi is the University of Islamic Sciences, Karachi:
+	// Fajr 18°, Isha 18°.
+	PrayerMethodKarachi PrayerMethod = "karachi"
+	// PrayerMethodKemenag is the Ministry of Religious Affairs of Indonesia:
+	// Fajr 20°, Isha 18°.
+	PrayerMethodKemenag PrayerMethod = "kemenag"
+)
+
+func (m PrayerMethod) IsValid() bool {
+	switch m {
+	case PrayerMethodMWL, PrayerMethodISNA, PrayerMethodEgypt, PrayerMethodMakkah, PrayerMethodKarachi, PrayerMethodKemenag:
+		return true
+	}
+	return false
+}
+
+// AsrSchool decides when Asr begins: when an object's shadow equals its
+// length plus its noon shadow (standard, the Shafi'i, Maliki and Hanbali
+// view) or twice its length plus its noon shadow (Hanafi).
+type AsrSchool string
+
+const (
+	AsrSchoolStandard AsrSchool = "standard"
+	AsrSchoolHanafi   AsrSchool = "hanafi"
+)
+
+func (s AsrSchool) IsValid() bool {
+	switch s {
+	case AsrSchoolStandard, AsrSchoolHanafi:
+		return true
+	}
+	return false
+}
+
+type Prayer string
+
+const (
+	PrayerFajr    Prayer = "fajr"
+	PrayerDhuhr   Prayer = "dhuhr"
+	PrayerAsr     Prayer = "asr"
+	PrayerMaghrib Prayer = "maghrib"
+	PrayerIsha    Prayer = "isha"
+)
+
+// Prayers lists the five daily prayers in order.
+var Prayers = []Prayer{PrayerFajr, PrayerDhuhr, PrayerAsr, PrayerMaghrib, PrayerIsha}
+
+func (p Prayer) IsValid() bool {
+	switch p {
+	case PrayerFajr, PrayerDhuhr, PrayerAsr, PrayerMaghrib, PrayerIsha:
+		return true
+	}
+	return false
+}
+
+// QuietHours holds a user's wish not to receive push or email notifications
+// around prayer times. Times are calculated at the user's coordinates, or at
+// the masjid's when UseMasjidLocation is set. Prayers is a comma separated
+// list of the prayers observed.
+type QuietHours struct {
+	UserID            uuid.UUID    `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
+	Enabled           bool         `gorm:"column:enabled;not null" json:"enabled"`
+	UseMasjidLocation bool         `gorm:"column:use_masjid_location;not null;default:false" json:"use_masjid_location"`
+	Latitude          float64      `gorm:"column:latitude;not null" json:"latitude"`
+	Longitude         float64      `gorm:"column:longitude;not null" json:"longitude"`
+	Method            PrayerMethod `gorm:"column:method;type:varchar(20);not null" json:"method"`
+	AsrSchool         AsrSchool    `gorm:"column:asr_school;type:varchar(20);not null" json:"asr_school"`
+	Prayers           string       `gorm:"column:prayers;type:varchar(100);not null" json:"prayers"`
+	MinutesBefore     int          `gorm:"column:minutes_before;not null" json:"minutes_before"`
+	MinutesAfter      int          `gorm:"column:minutes_after;not null" json:"minutes_after"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+}
+
+// PrayerList splits Prayers.
+func (q QuietHours) PrayerList() []Prayer {
+	var list []Prayer
+	for _, prayer := range strings.Split(q.Prayers, ",") {
+		if prayer = strings.ToLower(strings.TrimSpace(prayer)); prayer != "" {
+			list = append(list, Prayer(prayer))
+		}
+	}
+	return list
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReportReason string
+
+const (
+	ReportReasonSpam          ReportReason = "spam"
+	ReportReasonHarassment    ReportReason = "harassment"
+	ReportReasonInappropriate ReportReason = "inappropriate_content"
+	ReportReasonScam          ReportReason = "scam"
+	ReportReasonImpersonation ReportReason = "impersonation"
+	ReportReasonOther         ReportReason = "other"
+	// ReportReasonAutoFlagged is used for reports raised by the moderation
+	// pipeline rather than by a user.
+	ReportReasonAutoFlagged ReportReason = "auto_flagged"
+)
+
+func (r ReportReason) IsValid() bool {
+	switch r {
+	case ReportReasonSpam, ReportReasonHarassment, ReportReasonInappropriate,
+		ReportReasonScam, ReportReasonImpersonation, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusDismissed ReportStatus = "dismissed"
+	ReportStatusActioned  ReportStatus = "actioned"
+)
+
+type ModeratorAction string
+
+const (
+	ModeratorActionDismiss       ModeratorAction = "dismiss"
+	ModeratorActionDeleteMessage ModeratorAction = "delete_message"
+	ModeratorActionMuteUser      ModeratorAction = "mute_user"
+	ModeratorActionBanUser       ModeratorAction = "ban_user"
+)
+
+func (a ModeratorAction) IsValid() bool {
+	switch a {
+	case ModeratorActionDismiss, ModeratorActionDeleteMessage, ModeratorActionMuteUser, ModeratorActionBanUser:
+		return true
+	}
+	return false
+}
+
+// Report is raised against a message or, when MessageID is empty, against a
+// user in the context of a conversation they share with the reporter.
+// ReporterID is uuid.Nil for reports raised by the moderation pipeline.
+type Report struct {
+	ID             uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	ReporterID     uuid.UUID      `gorm:"column:reporter_id;not null;type:char(36)" json:"reporter_id"`
+	ReportedUserID uuid.UUID      `gorm:"column:reported_user_id;not null;type:char(36);index" json:"reported_user_id"`
+	ConversationID uuid.UUID      `gorm:"column:conversation_id;not null;type:char(36)" json:"conversation_id"`
+	MessageID      sql.NullString `gorm:"column:message_id;type:char(36)" json:"message_id"`
+	Reason         ReportReason   `gorm:"column:reason;type:varchar(50);not null" json:"reason"`
+	Details        string         `gorm:"column:details" json:"details"`
+	Status         ReportStatus   `gorm:"column:status;type:varchar(20);not null;index" json:"status"`
+	Resolution     string         `gorm:"column:resolution;type:varchar(50)" json:"resolution,omitempty"`
+	ResolvedBy     sql.NullString `gorm:"column:resolved_by;type:char(36)" json:"resolved_by"`
+	ResolvedAt     sql.NullTime   `gorm:"column:resolved_at" json:"resolved_at"`
+	ModeratorNote  string         `gorm:"column:moderator_note" json:"moderator_note,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+type SanctionType string
+
+const (
+	SanctionTypeMute SanctionType = "mute"
+	SanctionTypeBan  SanctionType = "ban"
+)
+
+// UserSanction mutes or bans a user across all conversations. A sanction
+// without ExpiresAt lasts until it is revoked.
+type UserSanction struct {
+	ID        uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID      `gorm:"column:user_id;not null;type:char(36);index" json:"user_id"`
+	Type      SanctionType   `gorm:"column:type;type:varchar(20);not null" json:"type"`
+	ReportID  sql.NullString `gorm:"column:report_id;type:char(36)" json:"report_id"`
+	Reason    string         `gorm:"column:reason" json:"reason"`
+	CreatedBy uuid.UUID      `gorm:"column:created_by;not null;type:char(36)" json:"created_by"`
+	ExpiresAt sql.NullTime   `gorm:"column:expires_at" json:"expires_at"`
+	RevokedAt sql.NullTime   `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedAt time.Time      `json:"created_at"`
+}
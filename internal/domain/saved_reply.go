@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SavedReplyScope string
+
+const (
+	// SavedReplyScopePersonal replies are visible to their owner only.
+	SavedReplyScopePersonal SavedReplyScope = "personal"
+	// SavedReplyScopeTeam replies are shared by every agent and managed by
+	// supervisors.
+	SavedReplyScopeTeam SavedReplyScope = "team"
+)
+
+func (s SavedReplyScope) IsValid() bool {
+	switch s {
+	case SavedReplyScopePersonal, SavedReplyScopeTeam:
+		return true
+	}
+	return false
+}
+
+// SavedReply is a canned answer support agents insert into a conversation.
+// Content may contain placeholders such as {{first_name}}, expanded when the
+// reply is sent.
+type SavedReply struct {
+	ID        uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	Scope     SavedReplyScope `gorm:"column:scope;type:varchar(20);not null;index" json:"scope"`
+	OwnerID   uuid.UUID       `gorm:"column:owner_id;not null;type:char(36);index" json:"owner_id"`
+	Title     string          `gorm:"column:title;type:varchar(100);not null" json:"title"`
+	Content   string          `gorm:"column:content;type:text;not null" json:"content"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
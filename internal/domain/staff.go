@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StaffRole string
+
+const (
+	StaffRoleModerator  StaffRole = "moderator"
+	StaffRoleAgent      StaffRole = "agent"
+	StaffRoleSupervisor StaffRole = "supervisor"
+	StaffRoleMentor     StaffRole = "mentor"
+)
+
+// StaffMember grants a Limestone user a staff role in the chat service. A user
+// can hold several roles, one row each.
+type StaffMember struct {
+	UserID    uuid.UUID `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
+	Role      StaffRole `gorm:"column:role;primaryKey;type:varchar(50)" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SupportTicketStatus string
+
+const (
+	SupportTicketStatusQueued   SupportTicketStatus = "queued"
+	SupportTicketStatusAssigned SupportTicketStatus = "assigned"
+	SupportTicketStatusResolved SupportTicketStatus = "resolved"
+	SupportTicketStatusClosed   SupportTicketStatus = "closed"
+)
+
+func (s SupportTicketStatus) IsValid() bool {
+	switch s {
+	case SupportTicketStatusQueued, SupportTicketStatusAssigned, SupportTicketStatusResolved, SupportTicketStatusClosed:
+		return true
+	}
+	return false
+}
+
+// SupportTicket tracks a support conversation from the queue to the agent
+// answering it.
+type SupportTicket struct {
+	ID             uuid.UUID           `gorm:"type:char(36);primaryKey" json:"id"`
+	ConversationID uuid.UUID           `gorm:"column:conversation_id;not null;type:char(36);uniqueIndex" json:"conversation_id"`
+	RequesterID    uuid.UUID           `gorm:"column:requester_id;not null;type:char(36);index" json:"requester_id"`
+	Purpose        ConversationPurpose `gorm:"column:purpose;type:varchar(50);not null" json:"purpose"`
+	Status         SupportTicketStatus `gorm:"column:status;type:varchar(20);not null;index" json:"status"`
+	AgentID        sql.NullString      `gorm:"column:agent_id;type:char(36);index" json:"agent_id"`
+	AssignedAt     sql.NullTime        `gorm:"column:assigned_at" json:"assigned_at"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+
+	// FirstResponseAt is when someone other than the requester first wrote in
+	// the conversation, ResolvedAt when it was last resolved or closed, and
+	// EscalatedAt when it last missed an SLA target.
+	FirstResponseAt sql.NullTime `gorm:"column:first_response_at" json:"first_response_at"`
+	ResolvedAt      sql.NullTime `gorm:"column:resolved_at" json:"resolved_at"`
+	EscalatedAt     sql.NullTime `gorm:"column:escalated_at" json:"escalated_at"`
+}
+
+// SupportAgent is the availability of a user with the agent staff role.
+type SupportAgent struct {
+	UserID         uuid.UUID    `gorm:"column:user_id;primaryKey;type:char(36)" json:"user_id"`
+	Available      bool         `gorm:"column:available;not null;default:false" json:"available"`
+	LastAssignedAt sql.NullTime `gorm:"column:last_assigned_at" json:"last_assigned_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+type SLAKind string
+
+const (
+	SLAKindFirstResponse SLAKind = "first_response"
+	SLAKindResolution    SLAKind = "resolution"
+)
+
+// SupportEscalation records a ticket missing one of its SLA targets. Each
+// target escalates a ticket at most once.
+type SupportEscalation struct {
+	ID              uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	TicketID        uuid.UUID      `gorm:"column:ticket_id;not null;type:char(36);uniqueIndex:idx_escalation_ticket_kind" json:"ticket_id"`
+	Kind            SLAKind        `gorm:"column:kind;type:varchar(20);not null;uniqueIndex:idx_escalation_ticket_kind" json:"kind"`
+	PreviousAgentID sql.NullString `gorm:"column:previous_agent_id;type:char(36)" json:"previous_agent_id"`
+	AgentID         sql.NullString `gorm:"column:agent_id;type:char(36)" json:"agent_id"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
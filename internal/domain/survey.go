@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SatisfactionSurvey is sent to the user who was helped when a support or
+// revert conversation is resolved. AgentID is the agent or mentor who helped
+// them; Rating stays null until the survey is answered.
+type SatisfactionSurvey struct {
+	ID             uuid.UUID           `gorm:"type:char(36);primaryKey" json:"id"`
+	ConversationID uuid.UUID           `gorm:"column:conversation_id;not null;type:char(36);index" json:"conversation_id"`
+	MessageID      uuid.UUID           `gorm:"column:message_id;not null;type:char(36)" json:"message_id"`
+	Purpose        ConversationPurpose `gorm:"column:purpose;type:varchar(50);not null;index" json:"purpose"`
+	RespondentID   uuid.UUID           `gorm:"column:respondent_id;not null;type:char(36);index" json:"respondent_id"`
+	AgentID        sql.NullString      `gorm:"column:agent_id;type:char(36);index" json:"agent_id"`
+	Rating         sql.NullInt16       `gorm:"column:rating" json:"rating"`
+	Comment        string              `gorm:"column:comment;type:text" json:"comment"`
+	AnsweredAt     sql.NullTime        `gorm:"column:answered_at" json:"answered_at"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,6 +9,19 @@ import (
 
 type Gender string
 
+const (
+	GenderMale   Gender = "male"
+	GenderFemale Gender = "female"
+)
+
+func (g Gender) IsValid() bool {
+	switch g {
+	case GenderMale, GenderFemale:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID             uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
 	Email          string    `gorm:"type:varchar(320);uniqueIndex;not null" json:"email"`
@@ -21,3 +35,10 @@ type User struct {
 	CreatedAt      time.Time `gorm:"autoCreateTime"`
 	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
 }
+
+// GenderValue returns the user's gender normalised to a Gender. Limestone
+// stores it as free text, so anything unrecognised is returned as is and
+// fails IsValid.
+func (u User) GenderValue() Gender {
+	return Gender(strings.ToLower(strings.TrimSpace(u.Gender)))
+}
@@ -0,0 +1,198 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// maxPixels guards against decompression bombs: a small file that decodes
+	// into an enormous bitmap.
+	maxPixels = 50_000_000
+
+	thumbnailSize = 320
+	previewSize   = 24
+	jpegQuality   = 85
+)
+
+// Processed is the result of preparing an uploaded image for storage.
+type Processed struct {
+	// Image is the re-encoded original. Re-encoding drops EXIF, GPS and any
+	// other metadata segments because the standard encoders never write them.
+	Image    []byte
+	MimeType string
+	Width    int
+	Height   int
+	// Thumbnail is a JPEG no larger than thumbnailSize on its longest side.
+	Thumbnail []byte
+	// Preview is a tiny, blurred JPEG as a data URI that clients can show
+	// while the thumbnail loads.
+	Preview string
+}
+
+// SupportedTypes are the image MIME types Process can handle. Images of any
+// other type cannot be stripped of their metadata.
+var SupportedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// IsSupported reports whether Process can handle the MIME type.
+func IsSupported(mimeType string) bool {
+	for _, supported := range SupportedTypes {
+		if mimeType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Process strips metadata from an image, applies its EXIF orientation and
+// generates the thumbnail and blurred preview.
+func Process(data []byte, mimeType string) (*Processed, error) {
+	if !IsSupported(mimeType) {
+		return nil, fmt.Errorf("unsupported image type %s", mimeType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels exceeds the limit", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if mimeType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	processed := &Processed{MimeType: mimeType}
+	if processed.Image, err = encode(img, mimeType, data); err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	processed.Width, processed.Height = bounds.Dx(), bounds.Dy()
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, flatten(resize(img, thumbnailSize)), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	processed.Thumbnail = thumb.Bytes()
+
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, blur(flatten(resize(img, previewSize)), 2), &jpeg.Options{Quality: 50}); err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %w", err)
+	}
+	processed.Preview = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(preview.Bytes())
+
+	return processed, nil
+}
+
+func encode(img image.Image, mimeType string, original []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch mimeType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to re-encode jpeg: %w", err)
+		}
+	case "image/png":
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to re-encode png: %w", err)
+		}
+	case "image/gif":
+		// GIF has no EXIF support and re-encoding would drop the animation, so
+		// only the comment and application extensions need to go.
+		all, err := gif.DecodeAll(bytes.NewReader(original))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gif: %w", err)
+		}
+		if err := gif.EncodeAll(&buf, all); err != nil {
+			return nil, fmt.Errorf("failed to re-encode gif: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// resize scales img down so its longest side is at most size, averaging every
+// source pixel that falls into a destination pixel. Images that are already
+// small enough are returned unchanged.
+func resize(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	dw, dh := size, h*size/w
+	if h > w {
+		dw, dh = w*size/h, size
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		sy0, sy1 := bounds.Min.Y+dy*h/dh, bounds.Min.Y+(dy+1)*h/dh
+		for dx := 0; dx < dw; dx++ {
+			sx0, sx1 := bounds.Min.X+dx*w/dw, bounds.Min.X+(dx+1)*w/dw
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.SetRGBA64(dx, dy, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// blur applies a 3x3 box blur the given number of times.
+func blur(img *image.RGBA, passes int) *image.RGBA {
+	bounds := img.Bounds()
+	for i := 0; i < passes; i++ {
+		dst := image.NewRGBA(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				var r, g, b, a, n int
+				for ky := -1; ky <= 1; ky++ {
+					for kx := -1; kx <= 1; kx++ {
+						p := image.Pt(x+kx, y+ky)
+						if !p.In(bounds) {
+							continue
+						}
+						c := img.RGBAAt(p.X, p.Y)
+						r, g, b, a, n = r+int(c.R), g+int(c.G), b+int(c.B), a+int(c.A), n+1
+					}
+				}
+				dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+			}
+		}
+		img = dst
+	}
+	return img
+}
+
+// flatten draws img onto a white background, since JPEG has no alpha channel.
+func flatten(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return dst
+}
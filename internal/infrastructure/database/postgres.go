@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/masjids-io/limestone-chat/internal/domain"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -29,6 +30,69 @@ func NewPostgreSQLDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
 	log.Println("Database connection and migration successful!")
 	return db, nil
 }
+
+// migrate creates the tables owned by the chat service and adds columns that
+// were introduced after the original schema.
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&domain.Attachment{},
+		&domain.ModerationDecision{},
+		&domain.Report{},
+		&domain.UserSanction{},
+		&domain.StaffMember{},
+		&domain.UserBlock{},
+		&domain.Guardian{},
+		&domain.Match{},
+		&domain.SupportTicket{},
+		&domain.SupportAgent{},
+		&domain.SupportEscalation{},
+		&domain.MentorProfile{},
+		&domain.Mentorship{},
+		&domain.MentorshipDecline{},
+		&domain.SavedReply{},
+		&domain.SatisfactionSurvey{},
+		&domain.OutOfHoursReply{},
+		&domain.QuietHours{},
+		&domain.OutboxNotification{},
+		&domain.NotificationSetting{},
+		&domain.EmailDigest{},
+		&domain.MessageMention{},
+		&domain.PinnedMessage{},
+	); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := addMissingColumns(db, &domain.Conversation{}, "ApprovedAt", "Status"); err != nil {
+		return err
+	}
+	if err := addMissingColumns(db, &domain.ConversationParticipant{}, "ApprovalRequired", "ApprovedAt", "NotificationLevel", "MutedUntil", "DigestedUntil"); err != nil {
+		return err
+	}
+	if err := addMissingColumns(db, &domain.Message{}, "AttachmentID", "ThreadRootID", "ReplyCount", "LastReplyAt"); err != nil {
+		return err
+	}
+	if !db.Migrator().HasIndex(&domain.Message{}, "ThreadRootID") {
+		if err := db.Migrator().CreateIndex(&domain.Message{}, "ThreadRootID"); err != nil {
+			return fmt.Errorf("failed to index messages by thread: %w", err)
+		}
+	}
+	return nil
+}
+
+func addMissingColumns(db *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if db.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(model, field); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", field, err)
+		}
+	}
+	return nil
+}
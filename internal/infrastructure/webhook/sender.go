@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of "<timestamp>.<body>" under
+// the shared secret; TimestampHeader carries the Unix timestamp, so the
+// gateway can reject replays.
+const (
+	SignatureHeader = "X-Limestone-Signature"
+	TimestampHeader = "X-Limestone-Timestamp"
+)
+
+// Sender posts notifications as JSON to the push gateway's webhook.
+type Sender struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func NewSender(url string, secret string, timeout time.Duration) *Sender {
+	return &Sender{url: url, secret: []byte(secret), client: &http.Client{Timeout: timeout}}
+}
+
+// Send posts one notification. Any 2xx response is a delivery. Client errors
+// other than 408 and 429 mean the gateway will never accept the request and
+// wrap services.ErrNotificationRejected; everything else can be retried.
+func (s *Sender) Send(ctx context.Context, payload services.NotificationPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", services.ErrNotificationRejected, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", services.ErrNotificationRejected, err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: status %d: %s", services.ErrNotificationRejected, resp.StatusCode, detail)
+	default:
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, detail)
+	}
+}
+
+// Sign returns the signature the gateway should expect for a request.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+// maxFrameReadLimit is the hard ceiling handed to gorilla. Frames above the
+// configured per-purpose limit but below this ceiling are drained and rejected
+// with an error frame; only frames above it close the connection.
+const maxFrameReadLimit = 1 << 20
+
+// MessageLimits bounds what a client may send in a conversation. Frame size is
+// measured in bytes of the raw WebSocket frame, content in runes so that
+// Arabic-script text is not penalised, and metadata in bytes of encoded JSON.
+type MessageLimits struct {
+	MaxFrameBytes    int
+	MaxContentRunes  int
+	MaxMetadataBytes int
+}
+
+var defaultMessageLimits = MessageLimits{
+	MaxFrameBytes:    16 * 1024,
+	MaxContentRunes:  4000,
+	MaxMetadataBytes: 4 * 1024,
+}
+
+// LoadMessageLimits reads the limits for every conversation purpose from the
+// environment. WS_MAX_FRAME_BYTES, WS_MAX_CONTENT_RUNES and
+// WS_MAX_METADATA_BYTES set the defaults, and each can be overridden for one
+// purpose by appending the upper-cased purpose, e.g.
+// WS_MAX_CONTENT_RUNES_NIKKAH_SERVICE.
+func LoadMessageLimits() map[domain.ConversationPurpose]MessageLimits {
+	base := MessageLimits{
+		MaxFrameBytes:    envFrameLimit("WS_MAX_FRAME_BYTES", defaultMessageLimits.MaxFrameBytes),
+		MaxContentRunes:  envLimit("WS_MAX_CONTENT_RUNES", defaultMessageLimits.MaxContentRunes),
+		MaxMetadataBytes: envLimit("WS_MAX_METADATA_BYTES", defaultMessageLimits.MaxMetadataBytes),
+	}
+
+	limits := make(map[domain.ConversationPurpose]MessageLimits)
+	for _, purpose := range domain.ConversationPurposes() {
+		suffix := "_" + strings.ToUpper(string(purpose))
+		limits[purpose] = MessageLimits{
+			MaxFrameBytes:    envFrameLimit("WS_MAX_FRAME_BYTES"+suffix, base.MaxFrameBytes),
+			MaxContentRunes:  envLimit("WS_MAX_CONTENT_RUNES"+suffix, base.MaxContentRunes),
+			MaxMetadataBytes: envLimit("WS_MAX_METADATA_BYTES"+suffix, base.MaxMetadataBytes),
+		}
+	}
+	return limits
+}
+
+func envLimit(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || limit <= 0 {
+		log.Printf("Invalid value %q for %s, using default %d.\n", value, key, fallback)
+		return fallback
+	}
+	return limit
+}
+
+func envFrameLimit(key string, fallback int) int {
+	limit := envLimit(key, fallback)
+	if limit > maxFrameReadLimit {
+		log.Printf("%s=%d exceeds the hard frame limit, using %d.\n", key, limit, maxFrameReadLimit)
+		return maxFrameReadLimit
+	}
+	return limit
+}
+
+// Check rejects content or metadata that exceed the limits.
+func (l MessageLimits) Check(content string, metadata []byte) error {
+	if runes := utf8.RuneCountInString(content); runes > l.MaxContentRunes {
+		return apperror.New(apperror.CodeTooLarge, fmt.Sprintf("Message content is %d characters, the limit is %d", runes, l.MaxContentRunes))
+	}
+	if len(metadata) > l.MaxMetadataBytes {
+		return apperror.New(apperror.CodeTooLarge, fmt.Sprintf("Message metadata is %d bytes, the limit is %d", len(metadata), l.MaxMetadataBytes))
+	}
+	return nil
+}
+
+func (l MessageLimits) frameTooLarge() error {
+	return apperror.New(apperror.CodeTooLarge, fmt.Sprintf("Message frame exceeds the limit of %d bytes", l.MaxFrameBytes))
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"gorm.io/gorm"
+	"io"
 	"log"
 	"net/http"
 	"sync"
@@ -12,25 +13,38 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
 	"github.com/masjids-io/limestone-chat/internal/application/services"
 	"github.com/masjids-io/limestone-chat/internal/domain"
 )
 
 const (
-	writeWait      = 10 * time.Second
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
 )
 
 type Hub struct {
 	clients     map[uuid.UUID]map[*Client]bool
 	broadcast   chan *domain.Message
+	events      chan domain.Event
+	userEvents  chan userEvent
 	register    chan *Client
 	unregister  chan *Client
 	mu          sync.RWMutex
 	chatService services.ChatService
 	db          *gorm.DB
+	limits      map[domain.ConversationPurpose]MessageLimits
+}
+
+// userEvent is an event for every connection of one user, optionally closing
+// those connections once the event has been queued. A non-nil conversationID
+// limits it to the user's connections to that conversation.
+type userEvent struct {
+	userID         uuid.UUID
+	conversationID uuid.UUID
+	event          domain.Event
+	disconnect     bool
 }
 
 type Client struct {
@@ -39,6 +53,13 @@ type Client struct {
 	send           chan []byte
 	userID         uuid.UUID
 	conversationID uuid.UUID
+	limits         MessageLimits
+	// threads are the thread roots the client subscribed to, guarded by
+	// hub.mu.
+	threads map[uuid.UUID]bool
+	// done is closed when the hub drops the client. send is never closed,
+	// as readPump may still be writing to it.
+	done chan struct{}
 }
 
 var upgrader = websocket.Upgrader{
@@ -53,11 +74,14 @@ var upgrader = websocket.Upgrader{
 func NewHub(chatSvc services.ChatService, database *gorm.DB) *Hub {
 	hub := &Hub{
 		broadcast:   make(chan *domain.Message),
+		events:      make(chan domain.Event),
+		userEvents:  make(chan userEvent),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		clients:     make(map[uuid.UUID]map[*Client]bool),
 		chatService: chatSvc,
 		db:          database,
+		limits:      LoadMessageLimits(),
 	}
 	go hub.run()
 	return hub
@@ -77,89 +101,240 @@ func (h *Hub) run() {
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if clientsInConv, ok := h.clients[client.conversationID]; ok {
-				if _, ok := clientsInConv[client]; ok {
-					delete(clientsInConv, client)
-					close(client.send)
-					if len(clientsInConv) == 0 {
-						delete(h.clients, client.conversationID)
-					}
-					log.Printf("Client %s disconnected from conversation %s. Remaining clients in this conversation: %d\n", client.userID.String(), client.conversationID.String(), len(h.clients[client.conversationID]))
-				}
+			if h.remove(client) {
+				log.Printf("Client %s disconnected from conversation %s. Remaining clients in this conversation: %d\n", client.userID.String(), client.conversationID.String(), len(h.clients[client.conversationID]))
 			}
 			h.mu.Unlock()
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			if clientsInConv, ok := h.clients[message.ConversationID]; ok {
-				responseMessage := map[string]interface{}{
-					"id":                  message.ID.String(),
-					"conversation_id":     message.ConversationID.String(),
-					"sender_id":           message.SenderID.String(),
-					"content":             message.Content,
-					"type":                message.MessageType,
-					"media_url":           message.MediaURL.String,
-					"metadata":            json.RawMessage(message.Metadata),
-					"reply_to_message_id": message.ReplyToMessageID.String,
-					"created_at":          message.CreatedAt.Format(time.RFC3339),
-				}
-				if !message.MediaURL.Valid {
-					responseMessage["media_url"] = nil
-				}
-				if !message.ReplyToMessageID.Valid {
-					responseMessage["reply_to_message_id"] = nil
-				}
+			responseMessage := map[string]interface{}{
+				"id":                  message.ID.String(),
+				"conversation_id":     message.ConversationID.String(),
+				"sender_id":           message.SenderID.String(),
+				"content":             message.Content,
+				"type":                message.MessageType,
+				"media_url":           message.MediaURL.String,
+				"metadata":            json.RawMessage(message.Metadata),
+				"reply_to_message_id": message.ReplyToMessageID.String,
+				"thread_root_id":      message.ThreadRootID.String,
+				"attachment_id":       message.AttachmentID.String,
+				"created_at":          message.CreatedAt.Format(time.RFC3339),
+			}
+			if !message.MediaURL.Valid {
+				responseMessage["media_url"] = nil
+			}
+			if !message.ReplyToMessageID.Valid {
+				responseMessage["reply_to_message_id"] = nil
+			}
+			if !message.AttachmentID.Valid {
+				responseMessage["attachment_id"] = nil
+			}
+			if !message.ThreadRootID.Valid {
+				responseMessage["thread_root_id"] = nil
+			}
 
-				responseBytes, err := json.Marshal(responseMessage)
-				if err != nil {
-					log.Printf("Error marshaling saved message for broadcast in Hub: %v\n", err)
-					continue
+			responseBytes, err := json.Marshal(responseMessage)
+			if err != nil {
+				log.Printf("Error marshaling saved message for broadcast in Hub: %v\n", err)
+				continue
+			}
+
+			// Every client gets a thread reply; the thread's subscribers
+			// also get its new reply count.
+			var threadEvent []byte
+			if message.ThreadRoot != nil {
+				if threadEvent, err = json.Marshal(threadUpdatedEvent(message)); err != nil {
+					log.Printf("Error marshaling thread update for message %s in Hub: %v\n", message.ID.String(), err)
+					threadEvent = nil
 				}
+			}
 
+			h.mu.Lock()
+			if clientsInConv, ok := h.clients[message.ConversationID]; ok {
+			clients:
 				for client := range clientsInConv {
-					select {
-					case client.send <- responseBytes:
-					default:
-						close(client.send)
-						delete(clientsInConv, client)
-						log.Printf("Client %s's send channel blocked, unregistering.", client.userID.String())
+					payloads := [][]byte{responseBytes}
+					if threadEvent != nil && client.threads[message.ThreadRoot.ID] {
+						payloads = append(payloads, threadEvent)
+					}
+					for _, payload := range payloads {
+						if !h.queue(client, payload) {
+							continue clients
+						}
 					}
 				}
 			} else {
 				log.Printf("No active clients for conversation %s to broadcast message %s.\n", message.ConversationID.String(), message.ID.String())
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
+
+		case event := <-h.events:
+			eventBytes, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error marshaling %s event for broadcast in Hub: %v\n", event.Type, err)
+				continue
+			}
+			h.mu.Lock()
+			for client := range h.clients[event.ConversationID] {
+				h.queue(client, eventBytes)
+			}
+			h.mu.Unlock()
+
+		case userEvent := <-h.userEvents:
+			h.mu.Lock()
+			for conversationID, clientsInConv := range h.clients {
+				for client := range clientsInConv {
+					if client.userID != userEvent.userID {
+						continue
+					}
+					if userEvent.conversationID != uuid.Nil && conversationID != userEvent.conversationID {
+						continue
+					}
+					event := userEvent.event
+					event.ConversationID = conversationID
+					eventBytes, err := json.Marshal(event)
+					if err != nil {
+						log.Printf("Error marshaling %s event for user %s in Hub: %v\n", event.Type, client.userID.String(), err)
+						continue
+					}
+					if h.queue(client, eventBytes) && userEvent.disconnect {
+						h.remove(client)
+						log.Printf("Client %s disconnected from conversation %s by the server (%s).\n", client.userID.String(), conversationID.String(), event.Type)
+					}
+				}
+			}
+			h.mu.Unlock()
 		}
 	}
 }
 
+// queue sends bytes to a client without blocking the hub, dropping the client
+// when its buffer is full. It must be called with h.mu held for writing and
+// reports whether the client is still registered.
+func (h *Hub) queue(client *Client, payload []byte) bool {
+	select {
+	case client.send <- payload:
+		return true
+	default:
+		h.remove(client)
+		log.Printf("Client %s's send channel blocked, unregistering.", client.userID.String())
+		return false
+	}
+}
+
+// remove unregisters a client and closes its done channel, which makes
+// writePump flush what is queued and close the connection. It must be called
+// with h.mu held for writing and reports whether the client was registered.
+func (h *Hub) remove(client *Client) bool {
+	clientsInConv := h.clients[client.conversationID]
+	if _, ok := clientsInConv[client]; !ok {
+		return false
+	}
+	delete(clientsInConv, client)
+	close(client.done)
+	if len(clientsInConv) == 0 {
+		delete(h.clients, client.conversationID)
+	}
+	return true
+}
+
+// BroadcastEvent sends an event to every client in event.ConversationID.
+func (h *Hub) BroadcastEvent(event domain.Event) {
+	h.events <- event
+}
+
+// BroadcastMessage sends a message the server stored itself to the
+// conversation, as if a client had sent it.
+func (h *Hub) BroadcastMessage(message *domain.Message) {
+	h.broadcast <- message
+}
+
+// IsConnected reports whether the user has a live connection to the
+// conversation, in which case messages reach them without a push.
+func (h *Hub) IsConnected(conversationID uuid.UUID, userID uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients[conversationID] {
+		if client.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyUser sends an event to every connection of a user, whatever
+// conversation it belongs to.
+func (h *Hub) NotifyUser(userID uuid.UUID, event domain.Event) {
+	h.userEvents <- userEvent{userID: userID, event: event}
+}
+
+// DisconnectUser sends a final event to every connection of a user and then
+// closes them.
+func (h *Hub) DisconnectUser(userID uuid.UUID, event domain.Event) {
+	h.userEvents <- userEvent{userID: userID, event: event, disconnect: true}
+}
+
+// DisconnectParticipant sends a final event to a user's connections to one
+// conversation and then closes them, e.g. after they left it.
+func (h *Hub) DisconnectParticipant(conversationID uuid.UUID, userID uuid.UUID, event domain.Event) {
+	h.userEvents <- userEvent{userID: userID, conversationID: conversationID, event: event, disconnect: true}
+}
+
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if err := hub.chatService.EnsureCanConnect(userID); err != nil {
+		log.Printf("User %s may not connect: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	if conversationIDStr := r.URL.Query().Get("conversation_id"); conversationIDStr != "" {
+		serveExistingConversation(hub, w, r, userID, conversationIDStr)
+		return
+	}
+
 	purposeStr := r.URL.Query().Get("purpose")
 	if purposeStr == "" {
-		http.Error(w, "Conversation purpose is required", http.StatusBadRequest)
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Conversation purpose is required"))
 		return
 	}
 
 	purpose := domain.ConversationPurpose(purposeStr)
 	if !purpose.IsValid() {
-		http.Error(w, "Invalid conversation purpose", http.StatusBadRequest)
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid conversation purpose"))
 		return
 	}
 
 	partnerIDStr := r.URL.Query().Get("partner_id")
 	if partnerIDStr == "" {
-		http.Error(w, "Partner ID is required for this conversation type", http.StatusBadRequest)
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Partner ID is required for this conversation type"))
 		return
 	}
 
 	partnerID, err := uuid.Parse(partnerIDStr)
 	if err != nil {
-		http.Error(w, "Invalid partner ID format", http.StatusBadRequest)
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid partner ID format"))
 		return
 	}
 
 	if userID == partnerID {
-		http.Error(w, "Cannot chat with yourself", http.StatusBadRequest)
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Cannot chat with yourself"))
+		return
+	}
+
+	if err := hub.chatService.EvaluateConversationPolicy(userID, partnerID, purpose); err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	blocked, err := hub.chatService.HasBlocked(partnerID, userID)
+	if err != nil {
+		log.Printf("Error checking whether %s blocked %s: %v", partnerID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	if blocked {
+		log.Printf("User %s refused a conversation with %s, who blocked them.\n", userID.String(), partnerID.String())
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeForbidden, "You cannot chat with this user"))
 		return
 	}
 
@@ -169,6 +344,7 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 		Joins("JOIN conversation_participants cp1 ON conversations.id = cp1.conversation_id").
 		Joins("JOIN conversation_participants cp2 ON conversations.id = cp2.conversation_id").
 		Where("cp1.user_id = ? AND cp2.user_id = ? AND conversations.purpose = ?", userID, partnerID, purpose).
+		Where("cp1.role <> ? AND cp2.role <> ?", domain.ParticipantRoleObserver, domain.ParticipantRoleObserver).
 		First(&existingConversation).Error
 
 	if err != nil {
@@ -188,14 +364,14 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 			tx := hub.db.Begin()
 			if tx.Error != nil {
 				log.Printf("Failed to begin transaction for new conversation: %v", tx.Error)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Internal server error", tx.Error))
 				return
 			}
 
 			if err := tx.Create(&newConversation).Error; err != nil {
 				tx.Rollback()
 				log.Printf("Failed to create new conversation: %v", err)
-				http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Failed to create conversation", err))
 				return
 			}
 
@@ -208,7 +384,7 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 			if err := tx.Create(&participant1).Error; err != nil {
 				tx.Rollback()
 				log.Printf("Failed to add user %s as participant: %v", userID.String(), err)
-				http.Error(w, "Failed to add participant", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Failed to add participant", err))
 				return
 			}
 
@@ -221,13 +397,46 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 			if err := tx.Create(&participant2).Error; err != nil {
 				tx.Rollback()
 				log.Printf("Failed to add partner user %s as participant: %v", partnerID.String(), err)
-				http.Error(w, "Failed to add partner participant", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Failed to add partner participant", err))
 				return
 			}
 
+			if purpose == domain.ConversationPurposeNikkah {
+				observers, err := hub.chatService.GuardianObservers(newConversation.ID, userID, partnerID)
+				if err != nil {
+					tx.Rollback()
+					log.Printf("Failed to load guardians for conversation %s: %v", newConversation.ID.String(), err)
+					apperror.WriteHTTP(w, err)
+					return
+				}
+				for _, observer := range observers {
+					if err := tx.Create(&observer).Error; err != nil {
+						tx.Rollback()
+						log.Printf("Failed to add guardian %s as observer: %v", observer.UserID.String(), err)
+						apperror.WriteHTTP(w, apperror.Internal("Failed to add guardian", err))
+						return
+					}
+					log.Printf("Guardian %s added as observer to conversation %s.\n", observer.UserID.String(), newConversation.ID.String())
+				}
+				approvalRequired := false
+				for _, observer := range observers {
+					approvalRequired = approvalRequired || observer.ApprovalRequired
+				}
+				// Without a guardian to approve it, the conversation is
+				// approved from the start.
+				if !approvalRequired {
+					if err := tx.Model(&newConversation).Update("approved_at", time.Now()).Error; err != nil {
+						tx.Rollback()
+						log.Printf("Failed to approve conversation %s: %v", newConversation.ID.String(), err)
+						apperror.WriteHTTP(w, apperror.Internal("Failed to create conversation", err))
+						return
+					}
+				}
+			}
+
 			if err := tx.Commit().Error; err != nil {
 				log.Printf("Failed to commit transaction: %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Internal server error", err))
 				return
 			}
 
@@ -236,7 +445,7 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 
 		} else {
 			log.Printf("Error finding existing conversation: %v\n", err)
-			http.Error(w, "Error finding conversation", http.StatusInternalServerError)
+			apperror.WriteHTTP(w, apperror.Internal("Error finding conversation", err))
 			return
 		}
 	} else {
@@ -255,13 +464,13 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 				}
 				if err := hub.db.Create(&newParticipant).Error; err != nil {
 					log.Printf("Failed to add reconnecting user %s as participant to existing conversation %s: %v", userID.String(), conversationID.String(), err)
-					http.Error(w, "Failed to add reconnecting participant", http.StatusInternalServerError)
+					apperror.WriteHTTP(w, apperror.Internal("Failed to add reconnecting participant", err))
 					return
 				}
 				log.Printf("User %s re-added as participant to existing conversation %s.\n", userID.String(), conversationID.String())
 			} else {
 				log.Printf("Error checking participant status for user %s in conversation %s: %v", userID.String(), conversationID.String(), err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Internal server error", err))
 				return
 			}
 		}
@@ -278,45 +487,113 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID)
 				}
 				if err := hub.db.Create(&newPartnerParticipant).Error; err != nil {
 					log.Printf("Failed to add missing partner %s as participant to existing conversation %s: %v", partnerID.String(), conversationID.String(), err)
-					http.Error(w, "Failed to add missing partner participant", http.StatusInternalServerError)
+					apperror.WriteHTTP(w, apperror.Internal("Failed to add missing partner participant", err))
 					return
 				}
 				log.Printf("Partner %s added as participant to existing conversation %s.\n", partnerID.String(), conversationID.String())
 			} else {
 				log.Printf("Error checking partner participant status for user %s in conversation %s: %v", partnerID.String(), conversationID.String(), err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				apperror.WriteHTTP(w, apperror.Internal("Internal server error", err))
 				return
 			}
 		}
 	}
 
+	if client := upgradeClient(hub, w, r, userID, conversationID, purpose); client != nil {
+		log.Printf("Incoming WebSocket connection from User ID: %s to Conversation ID: %s (Partner: %s)\n", client.userID.String(), client.conversationID.String(), partnerID.String())
+	}
+}
+
+// serveExistingConversation connects a user to a conversation they already
+// take part in by ID, which is how guardians observe nikkah conversations.
+func serveExistingConversation(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID, conversationIDStr string) {
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid conversation ID format"))
+		return
+	}
+	conversation, err := hub.chatService.GetParticipantConversation(userID, conversationID)
+	if err != nil {
+		log.Printf("User %s may not join conversation %s: %v", userID.String(), conversationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	ServeConversation(hub, w, r, userID, conversation)
+}
+
+// ServeConversation upgrades the request and connects the user to a
+// conversation that has already been resolved and authorised, such as a
+// support ticket.
+func ServeConversation(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID, conversation *domain.Conversation) {
+	if client := upgradeClient(hub, w, r, userID, conversation.ID, conversation.Purpose); client != nil {
+		log.Printf("Incoming WebSocket connection from User ID: %s to existing Conversation ID: %s\n", client.userID.String(), client.conversationID.String())
+	}
+}
+
+func upgradeClient(hub *Hub, w http.ResponseWriter, r *http.Request, userID uuid.UUID, conversationID uuid.UUID, purpose domain.ConversationPurpose) *Client {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
-		return
+		return nil
 	}
 
 	client := &Client{
 		hub:            hub,
 		conn:           conn,
 		send:           make(chan []byte, 256),
+		done:           make(chan struct{}),
 		userID:         userID,
 		conversationID: conversationID,
+		limits:         hub.limits[purpose],
+		threads:        make(map[uuid.UUID]bool),
 	}
 	client.hub.register <- client
 
-	log.Printf("Incoming WebSocket connection from User ID: %s to Conversation ID: %s (Partner: %s)\n", client.userID.String(), client.conversationID.String(), partnerID.String())
-
 	go client.writePump()
 	go client.readPump()
+	return client
 }
 
+// Socket ops other than sending a message as written.
+const (
+	// opInsertSavedReply sends a saved reply, expanded for the conversation,
+	// as a text message.
+	opInsertSavedReply = "insert_saved_reply"
+	// opRateSurvey answers a satisfaction survey; nothing is sent.
+	opRateSurvey = "rate_survey"
+	// opSubscribeThread and opUnsubscribeThread start and stop delivering a
+	// thread's updates to the connection.
+	opSubscribeThread   = "subscribe_thread"
+	opUnsubscribeThread = "unsubscribe_thread"
+)
+
+// maxThreadSubscriptions bounds the threads one connection follows.
+const maxThreadSubscriptions = 50
+
 type IncomingChatMessage struct {
+	RequestID        string                 `json:"request_id"`
+	Op               string                 `json:"op"`
+	SavedReplyID     *uuid.UUID             `json:"saved_reply_id"`
+	SurveyID         *uuid.UUID             `json:"survey_id"`
+	Rating           int                    `json:"rating"`
+	Comment          string                 `json:"comment"`
+	ThreadID         *uuid.UUID             `json:"thread_id"`
 	Type             string                 `json:"type"`
 	Content          string                 `json:"content"`
 	MediaURL         string                 `json:"media_url"`
 	Metadata         map[string]interface{} `json:"metadata"`
 	ReplyToMessageID *uuid.UUID             `json:"reply_to_message_id"`
+	AttachmentID     *uuid.UUID             `json:"attachment_id"`
+}
+
+// sendError queues an error frame for the client. Only the code and the public
+// message are sent; the wrapped error stays in the server logs. The frame is
+// dropped once the hub let go of the client.
+func (c *Client) sendError(err error, requestID string) {
+	select {
+	case c.send <- apperror.Marshal(err, requestID):
+	case <-c.done:
+	}
 }
 
 func (c *Client) writePump() {
@@ -328,38 +605,22 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				log.Printf("Error getting next writer for client %s: %v\n", c.userID.String(), err)
+		case message := <-c.send:
+			if !c.write(message) {
 				return
 			}
 
-			if _, err := w.Write(message); err != nil {
-				log.Printf("Error writing message to client %s: %v\n", c.userID.String(), err)
-				return
-			}
-
-			/*
-			   n := len(c.send)
-			   for i := 0; i < n; i++ {
-			      if _, err := w.Write(<-c.send); err != nil {
-			         log.Printf("Error writing queued message to client %s: %v\n", c.userID.String(), err)
-			         return
-			      }
-			   }
-			*/
-
-			if err := w.Close(); err != nil {
-				log.Printf("Error closing writer for client %s: %v\n", c.userID.String(), err)
-				return
+		case <-c.done:
+			// Flush what was queued before the hub let go of the client,
+			// such as the event saying why.
+			for len(c.send) > 0 {
+				if !c.write(<-c.send) {
+					return
+				}
 			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
 
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
@@ -371,13 +632,65 @@ func (c *Client) writePump() {
 	}
 }
 
+// write writes one frame and reports whether the connection is still usable.
+func (c *Client) write(message []byte) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		log.Printf("Error getting next writer for client %s: %v\n", c.userID.String(), err)
+		return false
+	}
+
+	if _, err := w.Write(message); err != nil {
+		log.Printf("Error writing message to client %s: %v\n", c.userID.String(), err)
+		return false
+	}
+
+	/*
+	   n := len(c.send)
+	   for i := 0; i < n; i++ {
+	      if _, err := w.Write(<-c.send); err != nil {
+	         log.Printf("Error writing queued message to client %s: %v\n", c.userID.String(), err)
+	         return
+	      }
+	   }
+	*/
+
+	if err := w.Close(); err != nil {
+		log.Printf("Error closing writer for client %s: %v\n", c.userID.String(), err)
+		return false
+	}
+	return true
+}
+
+// readFrame reads the next frame, draining and discarding it when it exceeds
+// the conversation's frame limit so the connection can stay open. A nil slice
+// with a nil error means the frame was rejected.
+func (c *Client) readFrame() ([]byte, error) {
+	_, r, err := c.conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	messageBytes, err := io.ReadAll(io.LimitReader(r, int64(c.limits.MaxFrameBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(messageBytes) > c.limits.MaxFrameBytes {
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return messageBytes, nil
+}
+
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadLimit(maxFrameReadLimit)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		log.Printf("Received Pong from client %s, resetting read deadline.", c.userID.String())
@@ -386,31 +699,52 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, messageBytes, err := c.conn.ReadMessage()
+		messageBytes, err := c.readFrame()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Error reading message from client %s: %v\n", c.userID.String(), err)
 			}
 			break
 		}
+		if messageBytes == nil {
+			log.Printf("Rejected oversize frame from client %s in conversation %s.\n", c.userID.String(), c.conversationID.String())
+			c.sendError(c.limits.frameTooLarge(), "")
+			continue
+		}
 
 		var incomingMsg IncomingChatMessage
 		if err := json.Unmarshal(messageBytes, &incomingMsg); err != nil {
 			log.Printf("Error unmarshaling incoming message from %s: %v, raw message: %s\n", c.userID.String(), err, string(messageBytes))
-			c.send <- []byte(fmt.Sprintf(`{"error": "Invalid message format: %v"}`, err.Error()))
+			c.sendError(apperror.Wrap(apperror.CodeInvalidRequest, "Invalid message format", err), "")
 			continue
 		}
 
+		if incomingMsg.Op != "" {
+			send, err := c.applyOp(&incomingMsg)
+			if err != nil {
+				c.sendError(err, incomingMsg.RequestID)
+				continue
+			}
+			if !send {
+				continue
+			}
+		}
+
 		var metadataBytes []byte
 		if incomingMsg.Metadata != nil {
 			metadataBytes, err = json.Marshal(incomingMsg.Metadata)
 			if err != nil {
 				log.Printf("Error marshaling metadata for message from %s: %v\n", c.userID.String(), err)
-				c.send <- []byte(`{"error": "Failed to process metadata"}`)
+				c.sendError(apperror.Wrap(apperror.CodeInvalidRequest, "Failed to process metadata", err), incomingMsg.RequestID)
 				continue
 			}
 		}
 
+		if err := c.limits.Check(incomingMsg.Content, metadataBytes); err != nil {
+			c.sendError(err, incomingMsg.RequestID)
+			continue
+		}
+
 		mediaURL := incomingMsg.MediaURL
 		var replyToMessageID *uuid.UUID
 		if incomingMsg.ReplyToMessageID != nil {
@@ -425,16 +759,98 @@ func (c *Client) readPump() {
 			mediaURL,
 			metadataBytes,
 			replyToMessageID,
+			incomingMsg.AttachmentID,
 		)
 
 		if err != nil {
 			log.Printf("Failed to save message from %s to conversation %s: %v\n", c.userID.String(), c.conversationID.String(), err)
-			c.send <- []byte(fmt.Sprintf(`{"error": "Failed to send message: %v"}`, err.Error()))
+			c.sendError(err, incomingMsg.RequestID)
 			continue
 		}
 
 		log.Printf("Message saved successfully from %s to conversation %s (Msg ID: %s)\n", c.userID.String(), c.conversationID.String(), savedMessage.ID.String())
 
 		c.hub.broadcast <- savedMessage
+
+		if err := c.hub.chatService.AutoReplyOutOfHours(savedMessage); err != nil {
+			log.Printf("Failed to auto-reply out of hours in conversation %s: %v\n", c.conversationID.String(), err)
+		}
+	}
+}
+
+// applyOp carries out an incoming op. Ops that stand for a message rewrite it
+// into that message and return true, so it goes through the same limits and
+// checks as one typed by the client.
+func (c *Client) applyOp(incomingMsg *IncomingChatMessage) (bool, error) {
+	switch incomingMsg.Op {
+	case opInsertSavedReply:
+		if incomingMsg.SavedReplyID == nil {
+			return false, apperror.New(apperror.CodeInvalidRequest, "saved_reply_id is required")
+		}
+		content, err := c.hub.chatService.ExpandSavedReply(c.userID, c.conversationID, *incomingMsg.SavedReplyID)
+		if err != nil {
+			return false, err
+		}
+		incomingMsg.Type = string(domain.MessageTypeText)
+		incomingMsg.Content = content
+		incomingMsg.MediaURL = ""
+		incomingMsg.AttachmentID = nil
+		if incomingMsg.Metadata == nil {
+			incomingMsg.Metadata = map[string]interface{}{}
+		}
+		incomingMsg.Metadata["saved_reply_id"] = incomingMsg.SavedReplyID.String()
+		return true, nil
+
+	case opRateSurvey:
+		if incomingMsg.SurveyID == nil {
+			return false, apperror.New(apperror.CodeInvalidRequest, "survey_id is required")
+		}
+		_, err := c.hub.chatService.SubmitSurveyResponse(c.userID, c.conversationID, *incomingMsg.SurveyID, incomingMsg.Rating, incomingMsg.Comment)
+		return false, err
+
+	case opSubscribeThread, opUnsubscribeThread:
+		if incomingMsg.ThreadID == nil {
+			return false, apperror.New(apperror.CodeInvalidRequest, "thread_id is required")
+		}
+		return false, c.subscribeThread(*incomingMsg.ThreadID, incomingMsg.Op == opSubscribeThread)
+	}
+	return false, apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("Unsupported op %q", incomingMsg.Op))
+}
+
+// subscribeThread starts or stops delivering updates of the thread a message
+// starts or belongs to, which must be in the client's conversation.
+func (c *Client) subscribeThread(messageID uuid.UUID, subscribe bool) error {
+	root, err := c.hub.chatService.GetThreadRoot(c.userID, messageID)
+	if err != nil {
+		return err
+	}
+	if root.ConversationID != c.conversationID {
+		return apperror.New(apperror.CodeInvalidRequest, "Thread is not in this conversation")
+	}
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	if !subscribe {
+		delete(c.threads, root.ID)
+		return nil
+	}
+	if !c.threads[root.ID] && len(c.threads) >= maxThreadSubscriptions {
+		return apperror.New(apperror.CodeInvalidRequest, fmt.Sprintf("Cannot follow more than %d threads at once", maxThreadSubscriptions))
+	}
+	c.threads[root.ID] = true
+	return nil
+}
+
+// threadUpdatedEvent tells a thread's subscribers that it got a reply.
+func threadUpdatedEvent(reply *domain.Message) domain.Event {
+	root := reply.ThreadRoot
+	return domain.Event{
+		Type:           domain.EventThreadUpdated,
+		ConversationID: reply.ConversationID,
+		Data: map[string]interface{}{
+			"thread_id":     root.ID.String(),
+			"message_id":    reply.ID.String(),
+			"reply_count":   root.ReplyCount,
+			"last_reply_at": root.LastReplyAt.Time.Format(time.RFC3339),
+		},
 	}
 }
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type AttachmentHandler struct {
+	attachmentService services.AttachmentService
+	maxUploadBytes    int64
+}
+
+func NewAttachmentHandler(attachmentSvc services.AttachmentService, maxUploadBytes int64) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentSvc,
+		maxUploadBytes:    maxUploadBytes,
+	}
+}
+
+// Upload handles POST /attachments with a multipart form holding
+// conversation_id and file. The returned attachment ID is what messages send
+// as attachment_id.
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart headers and the conversation_id field.
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadBytes+64*1024)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeInvalidRequest, "Expected a multipart/form-data upload", err))
+		return
+	}
+
+	var conversationIDStr string
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeTooLarge, "Upload is too large", err))
+				return
+			}
+			apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeInvalidRequest, "Upload must contain conversation_id followed by file", err))
+			return
+		}
+
+		switch part.FormName() {
+		case "conversation_id":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeInvalidRequest, "Failed to read conversation_id", err))
+				return
+			}
+			conversationIDStr = strings.TrimSpace(string(value))
+		case "file":
+			conversationID, ok := uuidParam(w, conversationIDStr, "conversation ID")
+			if !ok {
+				return
+			}
+			attachment, err := h.attachmentService.Upload(userID, conversationID, part.FileName(), part)
+			if err != nil {
+				log.Printf("Attachment upload by %s failed: %v", userID.String(), err)
+				apperror.WriteHTTP(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, attachment)
+			return
+		}
+	}
+}
+
+// DownloadURL handles GET /attachments/{id}/url and returns a short-lived
+// download URL scoped to the requesting participant. Pass variant=thumbnail
+// for the thumbnail of an image.
+func (h *AttachmentHandler) DownloadURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	attachmentID, ok := uuidParam(w, r.PathValue("id"), "attachment ID")
+	if !ok {
+		return
+	}
+
+	variant, ok := variantParam(w, r)
+	if !ok {
+		return
+	}
+
+	attachment, err := h.attachmentService.GetParticipantAttachment(attachmentID, userID)
+	if err != nil {
+		log.Printf("User %s cannot access attachment %s: %v", userID.String(), attachmentID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	signedURL, err := h.attachmentService.SignDownloadURL(attachment, userID, variant)
+	if err != nil {
+		log.Printf("Failed to sign download URL for attachment %s and user %s: %v", attachmentID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, signedURL)
+}
+
+// Download handles GET /attachments/{id}/download. It is authenticated by the
+// URL signature rather than a bearer token so the URL can be used directly by
+// image tags and download managers.
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	attachmentID, ok := uuidParam(w, r.PathValue("id"), "attachment ID")
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	userID, ok := uuidParam(w, query.Get("user_id"), "user ID")
+	if !ok {
+		return
+	}
+
+	variant, ok := variantParam(w, r)
+	if !ok {
+		return
+	}
+
+	attachment, err := h.attachmentService.AuthorizeDownload(attachmentID, userID, variant, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		log.Printf("Download of attachment %s by %s refused: %v", attachmentID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	rc, err := h.attachmentService.Open(attachment, variant)
+	if err != nil {
+		log.Printf("Failed to open attachment %s: %v", attachment.ID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	defer rc.Close()
+
+	if variant == domain.AttachmentVariantThumbnail {
+		w.Header().Set("Content-Type", "image/jpeg")
+	} else {
+		w.Header().Set("Content-Type", attachment.MimeType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, rc); err != nil {
+		log.Printf("Error streaming attachment %s to %s: %v", attachment.ID.String(), userID.String(), err)
+	}
+}
+
+func variantParam(w http.ResponseWriter, r *http.Request) (domain.AttachmentVariant, bool) {
+	variant := domain.AttachmentVariant(r.URL.Query().Get("variant"))
+	if !variant.IsValid() {
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid attachment variant"))
+		return "", false
+	}
+	return variant, true
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+type BlockHandler struct {
+	blockService services.BlockService
+}
+
+func NewBlockHandler(blockSvc services.BlockService) *BlockHandler {
+	return &BlockHandler{blockService: blockSvc}
+}
+
+// List handles GET /blocks and returns the users the caller has blocked.
+func (h *BlockHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	blocks, err := h.blockService.ListBlocked(userID)
+	if err != nil {
+		log.Printf("Failed to list blocks for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, blocks)
+}
+
+// Block handles PUT /blocks/{user_id}. Blocking an already blocked user is
+// not an error.
+func (h *BlockHandler) Block(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	blockedID, ok := uuidParam(w, r.PathValue("user_id"), "user ID")
+	if !ok {
+		return
+	}
+	if err := h.blockService.Block(userID, blockedID); err != nil {
+		log.Printf("Failed to block user %s for %s: %v", blockedID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unblock handles DELETE /blocks/{user_id}.
+func (h *BlockHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	blockedID, ok := uuidParam(w, r.PathValue("user_id"), "user ID")
+	if !ok {
+		return
+	}
+	if err := h.blockService.Unblock(userID, blockedID); err != nil {
+		log.Printf("Failed to unblock user %s for %s: %v", blockedID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+type ConversationHandler struct {
+	chatService       services.ChatService
+	attachmentService services.AttachmentService
+}
+
+func NewConversationHandler(chatSvc services.ChatService, attachmentSvc services.AttachmentService) *ConversationHandler {
+	return &ConversationHandler{
+		chatService:       chatSvc,
+		attachmentService: attachmentSvc,
+	}
+}
+
+type attachmentResponse struct {
+	ID           string     `json:"id"`
+	FileName     string     `json:"file_name"`
+	MimeType     string     `json:"mime_type"`
+	Size         int64      `json:"size"`
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	Preview      string     `json:"preview,omitempty"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	URLExpiresAt *time.Time `json:"thumbnail_url_expires_at,omitempty"`
+}
+
+type messageResponse struct {
+	ID               string              `json:"id"`
+	ConversationID   string              `json:"conversation_id"`
+	SenderID         string              `json:"sender_id"`
+	Content          string              `json:"content"`
+	Type             string              `json:"type"`
+	MediaURL         *string             `json:"media_url"`
+	Metadata         json.RawMessage     `json:"metadata"`
+	ReplyToMessageID *string             `json:"reply_to_message_id"`
+	ThreadRootID     *string             `json:"thread_root_id"`
+	ReplyCount       int                 `json:"reply_count"`
+	LastReplyAt      *time.Time          `json:"last_reply_at,omitempty"`
+	AttachmentID     *string             `json:"attachment_id"`
+	Attachment       *attachmentResponse `json:"attachment,omitempty"`
+	CreatedAt        string              `json:"created_at"`
+}
+
+func newMessageResponse(message domain.Message) messageResponse {
+	response := messageResponse{
+		ID:             message.ID.String(),
+		ConversationID: message.ConversationID.String(),
+		SenderID:       message.SenderID.String(),
+		Content:        message.Content,
+		Type:           message.MessageType,
+		Metadata:       message.Metadata,
+		ReplyCount:     message.ReplyCount,
+		CreatedAt:      message.CreatedAt.Format(time.RFC3339),
+	}
+	if len(response.Metadata) == 0 {
+		response.Metadata = json.RawMessage("null")
+	}
+	if message.MediaURL.Valid {
+		response.MediaURL = &message.MediaURL.String
+	}
+	if message.ReplyToMessageID.Valid {
+		response.ReplyToMessageID = &message.ReplyToMessageID.String
+	}
+	if message.ThreadRootID.Valid {
+		response.ThreadRootID = &message.ThreadRootID.String
+	}
+	if message.LastReplyAt.Valid {
+		response.LastReplyAt = &message.LastReplyAt.Time
+	}
+	if message.AttachmentID.Valid {
+		response.AttachmentID = &message.AttachmentID.String
+	}
+	return response
+}
+
+type conversationResponse struct {
+	ID            string                     `json:"id"`
+	Type          domain.ConversationType    `json:"type"`
+	Purpose       domain.ConversationPurpose `json:"purpose"`
+	Name          *string                    `json:"name"`
+	Status        domain.ConversationStatus  `json:"status"`
+	LastMessageID *string                    `json:"last_message_id"`
+	ApprovedAt    *time.Time                 `json:"approved_at,omitempty"`
+	Notifications *notificationsResponse     `json:"notifications,omitempty"`
+	CreatedAt     string                     `json:"created_at"`
+	UpdatedAt     string                     `json:"updated_at"`
+}
+
+// notificationsResponse is the caller's notification settings for one
+// conversation. Level "default" follows their own settings.
+type notificationsResponse struct {
+	Level      domain.NotificationLevel `json:"level"`
+	MutedUntil *time.Time               `json:"muted_until"`
+}
+
+func newNotificationsResponse(participant domain.ConversationParticipant) *notificationsResponse {
+	response := &notificationsResponse{Level: participant.NotificationLevel}
+	if response.Level == "" {
+		response.Level = "default"
+	}
+	if participant.MutedUntil.Valid && participant.MutedUntil.Time.After(time.Now()) {
+		response.MutedUntil = &participant.MutedUntil.Time
+	}
+	return response
+}
+
+type changeStatusRequest struct {
+	Status domain.ConversationStatus `json:"status"`
+}
+
+func newConversationResponse(conversation domain.Conversation) conversationResponse {
+	response := conversationResponse{
+		ID:        conversation.ID.String(),
+		Type:      conversation.Type,
+		Purpose:   conversation.Purpose,
+		Status:    conversation.Status,
+		CreatedAt: conversation.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: conversation.UpdatedAt.Format(time.RFC3339),
+	}
+	if response.Status == "" {
+		response.Status = domain.ConversationStatusOpen
+	}
+	if conversation.Name.Valid {
+		response.Name = &conversation.Name.String
+	}
+	if conversation.LastMessageID.Valid {
+		response.LastMessageID = &conversation.LastMessageID.String
+	}
+	if conversation.ApprovedAt.Valid {
+		response.ApprovedAt = &conversation.ApprovedAt.Time
+	}
+	return response
+}
+
+// List handles GET /conversations?status=&limit=&offset= and returns the
+// caller's conversations, most recently active first.
+func (h *ConversationHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	status := domain.ConversationStatus(r.URL.Query().Get("status"))
+	conversations, err := h.chatService.ListConversations(userID, status, limit, offset)
+	if err != nil {
+		log.Printf("Failed to list conversations for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	responses := make([]conversationResponse, 0, len(conversations))
+	for _, conversation := range conversations {
+		response := newConversationResponse(conversation)
+		for _, participant := range conversation.Participants {
+			if participant.UserID == userID {
+				response.Notifications = newNotificationsResponse(participant)
+			}
+		}
+		responses = append(responses, response)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversations": responses})
+}
+
+// ChangeStatus handles POST /conversations/{id}/status with {"status": "resolved"}.
+func (h *ConversationHandler) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	var req changeStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	conversation, err := h.chatService.ChangeStatus(userID, conversationID, req.Status)
+	if err != nil {
+		log.Printf("User %s failed to change status of conversation %s: %v", userID.String(), conversationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newConversationResponse(*conversation))
+}
+
+// Messages handles GET /conversations/{id}/messages?limit=&offset=. Image
+// attachments come with a blurred preview and a signed thumbnail URL so the
+// list can be rendered without downloading the full images.
+func (h *ConversationHandler) Messages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := h.chatService.GetConversationHistory(userID, conversationID, limit, offset)
+	if err != nil {
+		log.Printf("Failed to get history of conversation %s for user %s: %v", conversationID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	responses := make([]messageResponse, 0, len(messages))
+	for _, message := range messages {
+		response := newMessageResponse(message)
+		if message.Attachment != nil {
+			response.Attachment = h.newAttachmentResponse(message.Attachment, userID)
+		}
+		responses = append(responses, response)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": responses})
+}
+
+// Mentions handles GET /mentions?limit=&offset= and returns the messages
+// mentioning the caller, newest first.
+func (h *ConversationHandler) Mentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := h.chatService.ListMentions(userID, limit, offset)
+	if err != nil {
+		log.Printf("Failed to list mentions of user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	responses := make([]messageResponse, 0, len(messages))
+	for _, message := range messages {
+		responses = append(responses, newMessageResponse(message))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": responses})
+}
+
+// Thread handles GET /messages/{id}/thread?limit=&offset= and returns the
+// thread the message starts or belongs to: its root and replies, oldest
+// first.
+func (h *ConversationHandler) Thread(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	messageID, ok := uuidParam(w, r.PathValue("id"), "message ID")
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	root, replies, err := h.chatService.GetThread(userID, messageID, limit, offset)
+	if err != nil {
+		log.Printf("Failed to get thread of message %s for user %s: %v", messageID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	responses := make([]messageResponse, 0, len(replies))
+	for _, reply := range replies {
+		responses = append(responses, newMessageResponse(reply))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"root": newMessageResponse(*root), "replies": responses})
+}
+
+func (h *ConversationHandler) newAttachmentResponse(attachment *domain.Attachment, userID uuid.UUID) *attachmentResponse {
+	response := &attachmentResponse{
+		ID:       attachment.ID.String(),
+		FileName: attachment.FileName,
+		MimeType: attachment.MimeType,
+		Size:     attachment.Size,
+		Width:    attachment.Width,
+		Height:   attachment.Height,
+		Preview:  attachment.Preview,
+	}
+	if attachment.HasThumbnail() {
+		signedURL, err := h.attachmentService.SignDownloadURL(attachment, userID, domain.AttachmentVariantThumbnail)
+		if err != nil {
+			log.Printf("Failed to sign thumbnail URL for attachment %s: %v", attachment.ID.String(), err)
+			return response
+		}
+		response.ThumbnailURL = signedURL.URL
+		response.URLExpiresAt = &signedURL.ExpiresAt
+	}
+	return response
+}
+
+// pageParams reads limit and offset query parameters.
+func pageParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	limit, offset := defaultHistoryLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid limit"))
+			return 0, 0, false
+		}
+		limit = min(parsed, maxHistoryLimit)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid offset"))
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+	return limit, offset, true
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+type GuardianHandler struct {
+	guardianService services.GuardianService
+}
+
+func NewGuardianHandler(guardianSvc services.GuardianService) *GuardianHandler {
+	return &GuardianHandler{guardianService: guardianSvc}
+}
+
+type setGuardianRequest struct {
+	GuardianID      uuid.UUID `json:"guardian_id"`
+	RequireApproval bool      `json:"require_approval"`
+}
+
+// Get handles GET /guardian.
+func (h *GuardianHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	guardian, err := h.guardianService.GetGuardian(userID)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, guardian)
+}
+
+// Set handles PUT /guardian with {"guardian_id": "...", "require_approval": true}.
+func (h *GuardianHandler) Set(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req setGuardianRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.GuardianID == uuid.Nil {
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "guardian_id is required"))
+		return
+	}
+	guardian, err := h.guardianService.SetGuardian(userID, req.GuardianID, req.RequireApproval)
+	if err != nil {
+		log.Printf("Failed to set guardian for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, guardian)
+}
+
+// Remove handles DELETE /guardian.
+func (h *GuardianHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.guardianService.RemoveGuardian(userID); err != nil {
+		log.Printf("Failed to remove guardian for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Approve handles POST /conversations/{id}/approval by a guardian.
+func (h *GuardianHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	conversation, err := h.guardianService.ApproveConversation(userID, conversationID)
+	if err != nil {
+		log.Printf("Guardian %s failed to approve conversation %s: %v", userID.String(), conversationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"approved":        conversation.ApprovedAt.Valid,
+	})
+}
+
+// RemoveObserver handles DELETE /conversations/{id}/observers/{user_id}.
+func (h *GuardianHandler) RemoveObserver(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	observerID, ok := uuidParam(w, r.PathValue("user_id"), "user ID")
+	if !ok {
+		return
+	}
+	if err := h.guardianService.RemoveObserver(userID, conversationID, observerID); err != nil {
+		log.Printf("User %s failed to remove observer %s from conversation %s: %v", userID.String(), observerID.String(), conversationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
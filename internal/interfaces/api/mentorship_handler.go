@@ -0,0 +1,134 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+type MentorshipHandler struct {
+	mentorshipService services.MentorshipService
+}
+
+func NewMentorshipHandler(mentorshipSvc services.MentorshipService) *MentorshipHandler {
+	return &MentorshipHandler{mentorshipService: mentorshipSvc}
+}
+
+type mentorProfileRequest struct {
+	Capacity  int      `json:"capacity"`
+	Languages []string `json:"languages"`
+	Active    *bool    `json:"active"`
+}
+
+type mentorshipRequest struct {
+	Languages []string `json:"languages"`
+}
+
+// RegisterMentor handles PUT /mentors/me with
+// {"capacity": 3, "languages": ["en", "ar"], "active": true}.
+func (h *MentorshipHandler) RegisterMentor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req mentorProfileRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	active := req.Active == nil || *req.Active
+	profile, err := h.mentorshipService.RegisterMentor(userID, req.Capacity, req.Languages, active)
+	if err != nil {
+		log.Printf("Failed to register mentor %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// MentorProfile handles GET /mentors/me.
+func (h *MentorshipHandler) MentorProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	profile, err := h.mentorshipService.GetMentorProfile(userID)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// Request handles POST /mentorships with {"languages": ["en"]}.
+func (h *MentorshipHandler) Request(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req mentorshipRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	mentorship, err := h.mentorshipService.RequestMentor(userID, req.Languages)
+	if err != nil {
+		log.Printf("Failed to request a mentor for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, mentorship)
+}
+
+// List handles GET /mentorships, returning those of the caller as revert or
+// mentor.
+func (h *MentorshipHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	mentorships, err := h.mentorshipService.ListMentorships(userID)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"mentorships": mentorships})
+}
+
+// Accept handles POST /mentorships/{id}/accept.
+func (h *MentorshipHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	mentorshipID, ok := uuidParam(w, r.PathValue("id"), "mentorship ID")
+	if !ok {
+		return
+	}
+	mentorship, err := h.mentorshipService.Accept(userID, mentorshipID)
+	if err != nil {
+		log.Printf("Mentor %s failed to accept mentorship %s: %v", userID.String(), mentorshipID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mentorship)
+}
+
+// Decline handles POST /mentorships/{id}/decline.
+func (h *MentorshipHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	mentorshipID, ok := uuidParam(w, r.PathValue("id"), "mentorship ID")
+	if !ok {
+		return
+	}
+	mentorship, err := h.mentorshipService.Decline(userID, mentorshipID)
+	if err != nil {
+		log.Printf("Mentor %s failed to decline mentorship %s: %v", userID.String(), mentorshipID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mentorship)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type NotificationHandler struct {
+	notificationService services.NotificationService
+}
+
+func NewNotificationHandler(notificationSvc services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationSvc}
+}
+
+type notificationSettingsRequest struct {
+	Level      domain.NotificationLevel `json:"level"`
+	MutedUntil *time.Time               `json:"muted_until"`
+}
+
+// Settings handles GET /notifications/settings.
+func (h *NotificationHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	setting, err := h.notificationService.GetSettings(userID)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, setting)
+}
+
+// UpdateSettings handles PUT /notifications/settings with
+// {"level": "mentions", "muted_until": "2026-10-19T06:00:00Z"}.
+func (h *NotificationHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req notificationSettingsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Level == "" {
+		req.Level = domain.NotificationLevelAll
+	}
+	setting, err := h.notificationService.UpdateSettings(userID, req.Level, req.MutedUntil)
+	if err != nil {
+		log.Printf("Failed to update notification settings for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, setting)
+}
+
+// UpdateConversationSettings handles PUT /conversations/{id}/notifications
+// with {"level": "none"} or {"muted_until": "2026-10-19T06:00:00Z"}. An empty
+// level follows the user's own settings.
+func (h *NotificationHandler) UpdateConversationSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	var req notificationSettingsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	participant, err := h.notificationService.UpdateConversationSettings(userID, conversationID, req.Level, req.MutedUntil)
+	if err != nil {
+		log.Printf("Failed to update notification settings of conversation %s for user %s: %v", conversationID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newNotificationsResponse(*participant))
+}
+
+// DeadLetters handles GET /notifications/dead-letters for supervisors.
+func (h *NotificationHandler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	notifications, err := h.notificationService.DeadLetters(userID, limit, offset)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notifications": notifications})
+}
+
+// Retry handles POST /notifications/{id}/retry for supervisors.
+func (h *NotificationHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	notificationID, ok := uuidParam(w, r.PathValue("id"), "notification ID")
+	if !ok {
+		return
+	}
+	notification, err := h.notificationService.Retry(userID, notificationID)
+	if err != nil {
+		log.Printf("User %s failed to retry notification %s: %v", userID.String(), notificationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notification)
+}
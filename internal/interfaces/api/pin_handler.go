@@ -0,0 +1,98 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type PinHandler struct {
+	pinService services.PinService
+}
+
+func NewPinHandler(pinSvc services.PinService) *PinHandler {
+	return &PinHandler{pinService: pinSvc}
+}
+
+type pinResponse struct {
+	Message  messageResponse `json:"message"`
+	PinnedBy string          `json:"pinned_by"`
+	PinnedAt time.Time       `json:"pinned_at"`
+}
+
+func newPinResponse(pin domain.PinnedMessage) pinResponse {
+	return pinResponse{Message: newMessageResponse(*pin.Message), PinnedBy: pin.PinnedBy.String(), PinnedAt: pin.PinnedAt}
+}
+
+// List handles GET /conversations/{id}/pins and returns the pinned messages,
+// most recently pinned first.
+func (h *PinHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	pins, err := h.pinService.List(userID, conversationID)
+	if err != nil {
+		log.Printf("Failed to list pins of conversation %s for user %s: %v", conversationID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	responses := make([]pinResponse, 0, len(pins))
+	for _, pin := range pins {
+		responses = append(responses, newPinResponse(pin))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pins": responses})
+}
+
+// Pin handles PUT /conversations/{id}/pins/{message_id}.
+func (h *PinHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	messageID, ok := uuidParam(w, r.PathValue("message_id"), "message ID")
+	if !ok {
+		return
+	}
+	pin, err := h.pinService.Pin(userID, conversationID, messageID)
+	if err != nil {
+		log.Printf("User %s failed to pin message %s in conversation %s: %v", userID.String(), messageID.String(), conversationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPinResponse(*pin))
+}
+
+// Unpin handles DELETE /conversations/{id}/pins/{message_id}.
+func (h *PinHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	conversationID, ok := uuidParam(w, r.PathValue("id"), "conversation ID")
+	if !ok {
+		return
+	}
+	messageID, ok := uuidParam(w, r.PathValue("message_id"), "message ID")
+	if !ok {
+		return
+	}
+	if err := h.pinService.Unpin(userID, conversationID, messageID); err != nil {
+		log.Printf("User %s failed to unpin message %s in conversation %s: %v", userID.String(), messageID.String(), conversationID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
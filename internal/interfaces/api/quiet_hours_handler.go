@@ -0,0 +1,136 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type QuietHoursHandler struct {
+	quietHoursService services.QuietHoursService
+}
+
+func NewQuietHoursHandler(quietHoursSvc services.QuietHoursService) *QuietHoursHandler {
+	return &QuietHoursHandler{quietHoursService: quietHoursSvc}
+}
+
+type quietHoursRequest struct {
+	Enabled           *bool               `json:"enabled"`
+	UseMasjidLocation bool                `json:"use_masjid_location"`
+	Latitude          float64             `json:"latitude"`
+	Longitude         float64             `json:"longitude"`
+	Method            domain.PrayerMethod `json:"method"`
+	AsrSchool         domain.AsrSchool    `json:"asr_school"`
+	Prayers           []domain.Prayer     `json:"prayers"`
+	MinutesBefore     *int                `json:"minutes_before"`
+	MinutesAfter      *int                `json:"minutes_after"`
+}
+
+// settings fills in the defaults: enabled, MWL, standard Asr, all five
+// prayers, from 5 minutes before to 20 minutes after each.
+func (req quietHoursRequest) settings() domain.QuietHours {
+	settings := domain.QuietHours{
+		Enabled:           req.Enabled == nil || *req.Enabled,
+		UseMasjidLocation: req.UseMasjidLocation,
+		Latitude:          req.Latitude,
+		Longitude:         req.Longitude,
+		Method:            req.Method,
+		AsrSchool:         req.AsrSchool,
+		MinutesBefore:     services.DefaultQuietMinutesBefore,
+		MinutesAfter:      services.DefaultQuietMinutesAfter,
+	}
+	if settings.Method == "" {
+		settings.Method = domain.PrayerMethodMWL
+	}
+	if settings.AsrSchool == "" {
+		settings.AsrSchool = domain.AsrSchoolStandard
+	}
+	prayers := req.Prayers
+	if len(prayers) == 0 {
+		prayers = domain.Prayers
+	}
+	names := make([]string, len(prayers))
+	for i, prayer := range prayers {
+		names[i] = string(prayer)
+	}
+	settings.Prayers = strings.Join(names, ",")
+	if req.MinutesBefore != nil {
+		settings.MinutesBefore = *req.MinutesBefore
+	}
+	if req.MinutesAfter != nil {
+		settings.MinutesAfter = *req.MinutesAfter
+	}
+	return settings
+}
+
+// Get handles GET /quiet-hours.
+func (h *QuietHoursHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	settings, err := h.quietHoursService.Get(userID)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// Set handles PUT /quiet-hours with
+// {"latitude": -6.2, "longitude": 106.8, "method": "kemenag", "prayers": ["fajr", "maghrib"]}.
+func (h *QuietHoursHandler) Set(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req quietHoursRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	settings, err := h.quietHoursService.Set(userID, req.settings())
+	if err != nil {
+		log.Printf("Failed to set quiet hours for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// Delete handles DELETE /quiet-hours.
+func (h *QuietHoursHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.quietHoursService.Delete(userID); err != nil {
+		log.Printf("Failed to delete quiet hours for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PrayerTimes handles GET /quiet-hours/prayer-times?date=2026-10-18. The
+// default is today at the user's location.
+func (h *QuietHoursHandler) PrayerTimes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	date, ok := dateParam(w, r.URL.Query().Get("date"), "date", time.Time{})
+	if !ok {
+		return
+	}
+	schedule, err := h.quietHoursService.Schedule(userID, date)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schedule)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type ReportHandler struct {
+	reportService services.ReportService
+}
+
+func NewReportHandler(reportSvc services.ReportService) *ReportHandler {
+	return &ReportHandler{reportService: reportSvc}
+}
+
+type createReportRequest struct {
+	MessageID      *uuid.UUID          `json:"message_id"`
+	UserID         *uuid.UUID          `json:"user_id"`
+	ConversationID *uuid.UUID          `json:"conversation_id"`
+	Reason         domain.ReportReason `json:"reason"`
+	Details        string              `json:"details"`
+}
+
+type moderatorActionRequest struct {
+	Action   domain.ModeratorAction `json:"action"`
+	Duration string                 `json:"duration"`
+	Note     string                 `json:"note"`
+}
+
+type queuedReportResponse struct {
+	Report  domain.Report     `json:"report"`
+	Context []messageResponse `json:"context"`
+}
+
+// decodeJSON reads a JSON request body into v.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeInvalidRequest, "Invalid request body", err))
+		return false
+	}
+	return true
+}
+
+// Create handles POST /reports. The body references either a message_id, or
+// a user_id together with the conversation_id the reporter shares with them.
+func (h *ReportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req createReportRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var report *domain.Report
+	var err error
+	switch {
+	case req.MessageID != nil:
+		report, err = h.reportService.ReportMessage(userID, *req.MessageID, req.Reason, req.Details)
+	case req.UserID != nil && req.ConversationID != nil:
+		report, err = h.reportService.ReportUser(userID, *req.UserID, *req.ConversationID, req.Reason, req.Details)
+	default:
+		err = apperror.New(apperror.CodeInvalidRequest, "Either message_id, or user_id and conversation_id, are required")
+	}
+	if err != nil {
+		log.Printf("Failed to create report for user %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, report)
+}
+
+// Queue handles GET /moderation/reports and lists open reports, oldest first,
+// with the surrounding conversation messages.
+func (h *ReportHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	queue, err := h.reportService.ListOpenReports(userID, limit, offset)
+	if err != nil {
+		log.Printf("Failed to list reports for moderator %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+
+	responses := make([]queuedReportResponse, 0, len(queue))
+	for _, item := range queue {
+		response := queuedReportResponse{Report: item.Report, Context: make([]messageResponse, 0, len(item.Messages))}
+		for _, message := range item.Messages {
+			response.Context = append(response.Context, newMessageResponse(message))
+		}
+		responses = append(responses, response)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reports": responses})
+}
+
+// Act handles POST /moderation/reports/{id}/actions. Duration applies to
+// mute_user and ban_user; leaving it empty makes the sanction permanent.
+func (h *ReportHandler) Act(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	reportID, ok := uuidParam(w, r.PathValue("id"), "report ID")
+	if !ok {
+		return
+	}
+	var req moderatorActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "Invalid duration"))
+			return
+		}
+	}
+
+	report, err := h.reportService.ApplyAction(userID, reportID, req.Action, duration, req.Note)
+	if err != nil {
+		log.Printf("Moderator %s failed to act on report %s: %v", userID.String(), reportID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/auth"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// requestUserID returns the user set by auth.RequireAuth.
+func requestUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeUnauthorized, "Unauthorized"))
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// uuidParam parses a UUID from a path wildcard or query parameter.
+func uuidParam(w http.ResponseWriter, value string, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeInvalidRequest, "Invalid "+name+" format", err))
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type SavedReplyHandler struct {
+	savedReplyService services.SavedReplyService
+}
+
+func NewSavedReplyHandler(savedReplySvc services.SavedReplyService) *SavedReplyHandler {
+	return &SavedReplyHandler{savedReplyService: savedReplySvc}
+}
+
+type savedReplyRequest struct {
+	Scope   domain.SavedReplyScope `json:"scope"`
+	Title   string                 `json:"title"`
+	Content string                 `json:"content"`
+}
+
+// List handles GET /saved-replies?q=refund.
+func (h *SavedReplyHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	replies, err := h.savedReplyService.List(userID, r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"saved_replies": replies})
+}
+
+// Create handles POST /saved-replies with
+// {"scope": "personal", "title": "...", "content": "..."}.
+func (h *SavedReplyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req savedReplyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = domain.SavedReplyScopePersonal
+	}
+	reply, err := h.savedReplyService.Create(userID, req.Scope, req.Title, req.Content)
+	if err != nil {
+		log.Printf("Failed to create saved reply for %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, reply)
+}
+
+// Update handles PUT /saved-replies/{id} with {"title": "...", "content": "..."}.
+func (h *SavedReplyHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	replyID, ok := uuidParam(w, r.PathValue("id"), "saved reply ID")
+	if !ok {
+		return
+	}
+	var req savedReplyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	reply, err := h.savedReplyService.Update(userID, replyID, req.Title, req.Content)
+	if err != nil {
+		log.Printf("Failed to update saved reply %s for %s: %v", replyID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// Delete handles DELETE /saved-replies/{id}.
+func (h *SavedReplyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	replyID, ok := uuidParam(w, r.PathValue("id"), "saved reply ID")
+	if !ok {
+		return
+	}
+	if err := h.savedReplyService.Delete(userID, replyID); err != nil {
+		log.Printf("Failed to delete saved reply %s for %s: %v", replyID.String(), userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
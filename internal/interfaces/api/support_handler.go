@@ -0,0 +1,132 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+type SupportHandler struct {
+	supportService services.SupportService
+}
+
+func NewSupportHandler(supportSvc services.SupportService) *SupportHandler {
+	return &SupportHandler{supportService: supportSvc}
+}
+
+type availabilityRequest struct {
+	Available bool `json:"available"`
+}
+
+type transferRequest struct {
+	AgentID uuid.UUID `json:"agent_id"`
+}
+
+// SetAvailability handles PUT /support/availability for agents.
+func (h *SupportHandler) SetAvailability(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var req availabilityRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	agent, err := h.supportService.SetAvailability(userID, req.Available)
+	if err != nil {
+		log.Printf("Failed to set availability for agent %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, agent)
+}
+
+// Tickets handles GET /support/tickets?status=queued.
+func (h *SupportHandler) Tickets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	status := domain.SupportTicketStatus(r.URL.Query().Get("status"))
+	tickets, err := h.supportService.ListTickets(userID, status, limit, offset)
+	if err != nil {
+		log.Printf("Failed to list tickets for agent %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tickets": tickets})
+}
+
+// Transfer handles POST /support/tickets/{id}/transfer with {"agent_id": "..."}.
+func (h *SupportHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	ticketID, ok := uuidParam(w, r.PathValue("id"), "ticket ID")
+	if !ok {
+		return
+	}
+	var req transferRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.AgentID == uuid.Nil {
+		apperror.WriteHTTP(w, apperror.New(apperror.CodeInvalidRequest, "agent_id is required"))
+		return
+	}
+	ticket, err := h.supportService.Transfer(userID, ticketID, req.AgentID)
+	if err != nil {
+		log.Printf("User %s failed to transfer ticket %s: %v", userID.String(), ticketID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ticket)
+}
+
+// SLAReport handles GET /support/sla?from=2026-10-01&to=2026-10-18 for
+// supervisors. Dates are inclusive, in UTC; the default is the last 30 days.
+func (h *SupportHandler) SLAReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, ok := dateParam(w, r.URL.Query().Get("from"), "from", today.AddDate(0, 0, -29))
+	if !ok {
+		return
+	}
+	to, ok := dateParam(w, r.URL.Query().Get("to"), "to", today)
+	if !ok {
+		return
+	}
+	reports, err := h.supportService.SLAReport(userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Failed to build SLA report for %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reports": reports})
+}
+
+// dateParam parses a YYYY-MM-DD query value, returning fallback when empty.
+func dateParam(w http.ResponseWriter, value string, name string, fallback time.Time) (time.Time, bool) {
+	if value == "" {
+		return fallback, true
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeInvalidRequest, "Invalid "+name+" date, expected YYYY-MM-DD", err))
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+type SurveyHandler struct {
+	surveyService services.SurveyService
+}
+
+func NewSurveyHandler(surveySvc services.SurveyService) *SurveyHandler {
+	return &SurveyHandler{surveyService: surveySvc}
+}
+
+// Report handles GET /surveys/report?group_by=agent&from=2026-10-01&to=2026-10-18
+// for supervisors. group_by is agent (default) or purpose; dates work as for
+// the SLA report.
+func (h *SurveyHandler) Report(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = services.SurveyGroupByAgent
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, ok := dateParam(w, query.Get("from"), "from", today.AddDate(0, 0, -29))
+	if !ok {
+		return
+	}
+	to, ok := dateParam(w, query.Get("to"), "to", today)
+	if !ok {
+		return
+	}
+	summaries, err := h.surveyService.Report(userID, groupBy, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Failed to build survey report for %s: %v", userID.String(), err)
+		apperror.WriteHTTP(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group_by": groupBy, "summaries": summaries})
+}
@@ -4,20 +4,24 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/masjids-io/limestone-chat/internal/apperror"
 	"github.com/masjids-io/limestone-chat/internal/application/services"
 	"github.com/masjids-io/limestone-chat/internal/auth"
+	"github.com/masjids-io/limestone-chat/internal/domain"
 	"github.com/masjids-io/limestone-chat/internal/infrastructure/websocket"
 )
 
 type WebSocketHandler struct {
-	chatService services.ChatService
-	hub         *websocket.Hub
+	chatService    services.ChatService
+	supportService services.SupportService
+	hub            *websocket.Hub
 }
 
-func NewWebSocketHandler(chatSvc services.ChatService, hub *websocket.Hub) *WebSocketHandler {
+func NewWebSocketHandler(chatSvc services.ChatService, supportSvc services.SupportService, hub *websocket.Hub) *WebSocketHandler {
 	return &WebSocketHandler{
-		chatService: chatSvc,
-		hub:         hub,
+		chatService:    chatSvc,
+		supportService: supportSvc,
+		hub:            hub,
 	}
 }
 
@@ -25,10 +29,26 @@ func (h *WebSocketHandler) ServeChatWs(w http.ResponseWriter, r *http.Request) {
 	userID, err := auth.VerifyJWTForWebSocket(r)
 	if err != nil {
 		log.Printf("WebSocket authentication failed: %v", err)
-		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		apperror.WriteHTTP(w, apperror.Wrap(apperror.CodeUnauthorized, "Unauthorized", err))
 		return
 	}
 
 	log.Printf("Incoming WebSocket connection from authenticated User ID: %s\n", userID.String())
+
+	// Support conversations are opened without a partner and answered by
+	// whichever agent the queue assigns.
+	query := r.URL.Query()
+	purpose := domain.ConversationPurpose(query.Get("purpose"))
+	if purpose.IsSupport() && query.Get("partner_id") == "" && query.Get("conversation_id") == "" {
+		conversation, err := h.supportService.OpenTicket(userID, purpose)
+		if err != nil {
+			log.Printf("Failed to open support ticket for user %s: %v", userID.String(), err)
+			apperror.WriteHTTP(w, err)
+			return
+		}
+		websocket.ServeConversation(h.hub, w, r, userID, conversation)
+		return
+	}
+
 	websocket.ServeWs(h.hub, w, r, userID)
 }
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+)
+
+func TestApperror_Marshal(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		requestID     string
+		wantCode      apperror.Code
+		wantMessage   string
+		wantRetryable bool
+	}{
+		{
+			name:        "Client Error Keeps Public Message",
+			err:         apperror.Wrap(apperror.CodeInvalidRequest, "Invalid message format", errors.New(`unexpected "}"`)),
+			requestID:   "req-1",
+			wantCode:    apperror.CodeInvalidRequest,
+			wantMessage: "Invalid message format",
+		},
+		{
+			name:          "Plain Error Is Hidden As Internal",
+			err:           errors.New(`pq: duplicate key value violates unique constraint "messages_pkey"`),
+			wantCode:      apperror.CodeInternal,
+			wantMessage:   "Internal server error",
+			wantRetryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var frame apperror.Frame
+			if err := json.Unmarshal(apperror.Marshal(tt.err, tt.requestID), &frame); err != nil {
+				t.Fatalf("Marshal() produced invalid JSON: %v", err)
+			}
+			if frame.Error.Code != tt.wantCode {
+				t.Errorf("code got = %q, want %q", frame.Error.Code, tt.wantCode)
+			}
+			if frame.Error.Message != tt.wantMessage {
+				t.Errorf("message got = %q, want %q", frame.Error.Message, tt.wantMessage)
+			}
+			if frame.Error.RequestID != tt.requestID {
+				t.Errorf("request_id got = %q, want %q", frame.Error.RequestID, tt.requestID)
+			}
+			if frame.Error.Retryable != tt.wantRetryable {
+				t.Errorf("retryable got = %v, want %v", frame.Error.Retryable, tt.wantRetryable)
+			}
+		})
+	}
+}
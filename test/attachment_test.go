@@ -0,0 +1,214 @@
+package test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/auth"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/storage"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "Nested Key", key: "conversation/attachment"},
+		{name: "Thumbnail Key", key: "conversation/attachment.thumb.jpg"},
+		{name: "Parent Directory", key: "../escape", wantErr: true},
+		{name: "Parent Inside Key", key: "conversation/../../escape", wantErr: true},
+		{name: "Root Itself", key: ".", wantErr: true},
+		{name: "Empty Key", key: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Put(tt.key, strings.NewReader("content"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Put(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if _, err := store.Open(tt.key); tt.wantErr && err == nil {
+				t.Fatalf("Open(%q) succeeded, want an error", tt.key)
+			}
+			if tt.wantErr {
+				if err := store.Delete(tt.key); err == nil {
+					t.Fatalf("Delete(%q) succeeded, want an error", tt.key)
+				}
+				return
+			}
+
+			rc, err := store.Open(tt.key)
+			if err != nil {
+				t.Fatalf("Open(%q) error = %v", tt.key, err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(data) != "content" {
+				t.Errorf("Open(%q) = %q, want %q", tt.key, data, "content")
+			}
+			if err := store.Delete(tt.key); err != nil {
+				t.Fatalf("Delete(%q) error = %v", tt.key, err)
+			}
+			if _, err := store.Open(tt.key); err == nil {
+				t.Errorf("Open(%q) after Delete succeeded", tt.key)
+			}
+			if err := store.Delete(tt.key); err != nil {
+				t.Errorf("Delete(%q) of a missing blob error = %v, want nil", tt.key, err)
+			}
+		})
+	}
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAttachmentService_Upload(t *testing.T) {
+	db := newTestDB(t)
+	aisha := createUser(t, db, "aisha")
+	omar := createUser(t, db, "omar")
+	outsider := createUser(t, db, "outsider")
+	conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeGeneralSupport, aisha, omar)
+
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore() error = %v", err)
+	}
+	service := services.NewAttachmentService(db, store, auth.NewURLSigner("secret", time.Minute), 64*1024)
+
+	tests := []struct {
+		name         string
+		uploader     domain.User
+		fileName     string
+		content      []byte
+		wantCode     apperror.Code
+		wantMime     string
+		wantFileName string
+		wantWidth    int
+	}{
+		{name: "Text File", uploader: aisha, fileName: "notes.txt", content: []byte("Bring two witnesses."), wantMime: "text/plain; charset=utf-8", wantFileName: "notes.txt"},
+		{name: "Image", uploader: omar, fileName: "photo.png", content: testPNG(t, 40, 30), wantMime: "image/png", wantFileName: "photo.png", wantWidth: 40},
+		{name: "Path In File Name", uploader: aisha, fileName: "../../etc/passwd", content: []byte("x"), wantMime: "text/plain; charset=utf-8", wantFileName: "passwd"},
+		{name: "Not A Participant", uploader: outsider, fileName: "a.txt", content: []byte("x"), wantCode: apperror.CodeForbidden},
+		{name: "Empty", uploader: aisha, fileName: "empty.txt", wantCode: apperror.CodeInvalidRequest},
+		{name: "Too Large", uploader: aisha, fileName: "big.txt", content: bytes.Repeat([]byte("a"), 64*1024+1), wantCode: apperror.CodeTooLarge},
+		{name: "WebP Image", uploader: aisha, fileName: "photo.webp", content: []byte("RIFF\x24\x00\x00\x00WEBPVP8 \x18\x00\x00\x00"), wantCode: apperror.CodeInvalidRequest},
+		{name: "BMP Image", uploader: aisha, fileName: "photo.bmp", content: append([]byte("BM"), make([]byte, 60)...), wantCode: apperror.CodeInvalidRequest},
+		{name: "Corrupt Image", uploader: aisha, fileName: "broken.png", content: testPNG(t, 40, 30)[:60], wantCode: apperror.CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachment, err := service.Upload(tt.uploader.ID, conversation.ID, tt.fileName, bytes.NewReader(tt.content))
+			checkCode(t, err, tt.wantCode)
+			if tt.wantCode != "" {
+				return
+			}
+			if attachment.MimeType != tt.wantMime || attachment.FileName != tt.wantFileName || attachment.Width != tt.wantWidth {
+				t.Errorf("Upload() = %s %q %dpx, want %s %q %dpx", attachment.MimeType, attachment.FileName, attachment.Width, tt.wantMime, tt.wantFileName, tt.wantWidth)
+			}
+			rc, err := service.Open(attachment, domain.AttachmentVariantOriginal)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			stored, _ := io.ReadAll(rc)
+			rc.Close()
+			if int64(len(stored)) != attachment.Size {
+				t.Errorf("stored %d bytes, attachment records %d", len(stored), attachment.Size)
+			}
+			if _, err := service.Open(attachment, domain.AttachmentVariantThumbnail); (err == nil) != (tt.wantWidth > 0) {
+				t.Errorf("Open(thumbnail) error = %v, want a thumbnail only for images", err)
+			}
+		})
+	}
+}
+
+func TestAttachmentService_AuthorizeDownload(t *testing.T) {
+	db := newTestDB(t)
+	aisha := createUser(t, db, "aisha")
+	omar := createUser(t, db, "omar")
+	conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeGeneralSupport, aisha, omar)
+
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore() error = %v", err)
+	}
+	service := services.NewAttachmentService(db, store, auth.NewURLSigner("secret", time.Minute), 64*1024)
+	attachment, err := service.Upload(aisha.ID, conversation.ID, "photo.png", bytes.NewReader(testPNG(t, 20, 20)))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	sign := func(variant domain.AttachmentVariant) url.Values {
+		signed, err := service.SignDownloadURL(attachment, omar.ID, variant)
+		if err != nil {
+			t.Fatalf("SignDownloadURL() error = %v", err)
+		}
+		parsed, err := url.Parse(signed.URL)
+		if err != nil {
+			t.Fatalf("signed URL %q does not parse: %v", signed.URL, err)
+		}
+		return parsed.Query()
+	}
+	original := sign(domain.AttachmentVariantOriginal)
+	thumbnail := sign(domain.AttachmentVariantThumbnail)
+
+	tests := []struct {
+		name     string
+		user     domain.User
+		variant  domain.AttachmentVariant
+		query    url.Values
+		wantCode apperror.Code
+	}{
+		{name: "Original", user: omar, query: original},
+		{name: "Thumbnail", user: omar, variant: domain.AttachmentVariantThumbnail, query: thumbnail},
+		{name: "Thumbnail URL For Original", user: omar, query: thumbnail, wantCode: apperror.CodeForbidden},
+		{name: "Other User", user: aisha, query: original, wantCode: apperror.CodeForbidden},
+		{name: "Bad Signature", user: omar, query: url.Values{"expires": original["expires"], "signature": {"00"}}, wantCode: apperror.CodeForbidden},
+		{name: "Expired", user: omar, query: url.Values{"expires": {"1"}, "signature": original["signature"]}, wantCode: apperror.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.AuthorizeDownload(attachment.ID, tt.user.ID, tt.variant, tt.query.Get("expires"), tt.query.Get("signature"))
+			checkCode(t, err, tt.wantCode)
+		})
+	}
+
+	t.Run("Participant Left", func(t *testing.T) {
+		if err := db.Model(&domain.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversation.ID, omar.ID).
+			Update("left_at", time.Now()).Error; err != nil {
+			t.Fatalf("failed to mark participant as left: %v", err)
+		}
+		_, err := service.AuthorizeDownload(attachment.ID, omar.ID, domain.AttachmentVariantOriginal, original.Get("expires"), original.Get("signature"))
+		checkCode(t, err, apperror.CodeForbidden)
+	})
+}
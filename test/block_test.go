@@ -0,0 +1,157 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestBlockService(t *testing.T) {
+	db := newTestDB(t)
+	blocks := services.NewBlockService(db)
+	chat := newChatService(db, newRecordingNotifier())
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+
+	checkCode(t, blocks.Block(aisha.ID, aisha.ID), apperror.CodeInvalidRequest)
+	for i := 0; i < 2; i++ {
+		if err := blocks.Block(aisha.ID, bilal.ID); err != nil {
+			t.Fatalf("Block() error = %v", err)
+		}
+	}
+	blocked, err := blocks.ListBlocked(aisha.ID)
+	if err != nil {
+		t.Fatalf("ListBlocked() error = %v", err)
+	}
+	if len(blocked) != 1 || blocked[0].BlockedID != bilal.ID {
+		t.Errorf("ListBlocked() = %v, want bilal once", blocked)
+	}
+	if has, err := chat.HasBlocked(aisha.ID, bilal.ID); err != nil || !has {
+		t.Errorf("HasBlocked(aisha, bilal) = %t, %v, want true", has, err)
+	}
+	if has, err := chat.HasBlocked(bilal.ID, aisha.ID); err != nil || has {
+		t.Errorf("HasBlocked(bilal, aisha) = %t, %v, want false", has, err)
+	}
+
+	if err := blocks.Unblock(aisha.ID, bilal.ID); err != nil {
+		t.Fatalf("Unblock() error = %v", err)
+	}
+	if blocked, _ := blocks.ListBlocked(aisha.ID); len(blocked) != 0 {
+		t.Errorf("ListBlocked() after unblocking = %v, want none", blocked)
+	}
+	if has, _ := chat.HasBlocked(aisha.ID, bilal.ID); has {
+		t.Error("HasBlocked() after unblocking = true, want false")
+	}
+}
+
+func TestBlockService_SendMessage(t *testing.T) {
+	tests := []struct {
+		name             string
+		conversationType domain.ConversationType
+		blockerIsSender  bool
+		unblock          bool
+		wantCode         apperror.Code
+		wantMessage      string
+	}{
+		{name: "Sender Blocked", conversationType: domain.ConversationTypePrivate, wantCode: apperror.CodeForbidden, wantMessage: "You cannot send messages to this user"},
+		{name: "Sender Blocked Partner", conversationType: domain.ConversationTypePrivate, blockerIsSender: true, wantCode: apperror.CodeForbidden, wantMessage: "You have blocked this user, unblock them to send messages"},
+		{name: "Unblocked", conversationType: domain.ConversationTypePrivate, unblock: true},
+		{name: "Group", conversationType: domain.ConversationTypeGroup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			blocks := services.NewBlockService(db)
+			aisha := createUser(t, db, "aisha")
+			bilal := createUser(t, db, "bilal")
+			conversation := createConversation(t, db, tt.conversationType, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+
+			blocker, blocked := bilal, aisha
+			if tt.blockerIsSender {
+				blocker, blocked = aisha, bilal
+			}
+			if err := blocks.Block(blocker.ID, blocked.ID); err != nil {
+				t.Fatalf("Block() error = %v", err)
+			}
+			if tt.unblock {
+				if err := blocks.Unblock(blocker.ID, blocked.ID); err != nil {
+					t.Fatalf("Unblock() error = %v", err)
+				}
+			}
+
+			_, err := newChatService(db, newRecordingNotifier()).SendMessage(aisha.ID, conversation.ID, "salam", "text", "", nil, nil, nil)
+			checkCode(t, err, tt.wantCode)
+			if err != nil && err.Error() != tt.wantMessage {
+				t.Errorf("error = %q, want %q", err.Error(), tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestGetParticipantConversation_Blocked(t *testing.T) {
+	tests := []struct {
+		name             string
+		conversationType domain.ConversationType
+		observer         bool
+		wantCode         apperror.Code
+	}{
+		{name: "Private", conversationType: domain.ConversationTypePrivate, wantCode: apperror.CodeForbidden},
+		{name: "Group", conversationType: domain.ConversationTypeGroup},
+		{name: "Observer", conversationType: domain.ConversationTypePrivate, observer: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			aisha := createUser(t, db, "aisha")
+			bilal := createUser(t, db, "bilal")
+			conversation := createConversation(t, db, tt.conversationType, domain.ConversationPurposeGeneralSupport, aisha)
+			role := domain.ParticipantRoleMember
+			if tt.observer {
+				role = domain.ParticipantRoleObserver
+			}
+			addParticipant(t, db, conversation.ID, bilal.ID, role)
+			if err := services.NewBlockService(db).Block(aisha.ID, bilal.ID); err != nil {
+				t.Fatalf("Block() error = %v", err)
+			}
+
+			_, err := newChatService(db, newRecordingNotifier()).GetParticipantConversation(bilal.ID, conversation.ID)
+			checkCode(t, err, tt.wantCode)
+		})
+	}
+}
+
+func TestBlockService_SendMessageBesideBlocked(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		left bool
+	}{
+		{name: "Observer", role: domain.ParticipantRoleObserver},
+		{name: "Left Participant", role: domain.ParticipantRoleMember, left: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			aisha := createUser(t, db, "aisha")
+			bilal := createUser(t, db, "bilal")
+			wali := createUser(t, db, "wali")
+			conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+			addParticipant(t, db, conversation.ID, wali.ID, tt.role)
+			if tt.left {
+				db.Model(&domain.ConversationParticipant{}).Where("user_id = ?", wali.ID).Update("left_at", time.Now())
+			}
+			if err := services.NewBlockService(db).Block(wali.ID, aisha.ID); err != nil {
+				t.Fatalf("Block() error = %v", err)
+			}
+
+			_, err := newChatService(db, newRecordingNotifier()).SendMessage(aisha.ID, conversation.ID, "salam", "text", "", nil, nil, nil)
+			checkCode(t, err, "")
+		})
+	}
+}
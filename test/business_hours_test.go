@@ -0,0 +1,88 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+const testBusinessHours = `{
+  "admin_support": {
+    "timezone": "Asia/Jakarta",
+    "hours": {
+      "monday": ["13:00-17:00", "09:00-12:00"],
+      "friday": ["09:00-11:30"]
+    },
+    "holidays": ["2026-03-23"],
+    "ramadan": [{"from": "2026-02-18", "to": "2026-03-19", "hours": {"monday": ["10:00-15:00"]}}]
+  }
+}`
+
+func TestBusinessCalendar_NextOpening(t *testing.T) {
+	hours, err := services.ParseBusinessHours([]byte(testBusinessHours))
+	if err != nil {
+		t.Fatalf("ParseBusinessHours() error = %v", err)
+	}
+	calendar := hours[domain.ConversationPurposeAdminSupport]
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, jakarta)
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "Open Morning", now: at(2026, 10, 19, 10, 0), want: at(2026, 10, 19, 10, 0), wantOK: true},
+		{name: "Lunch Break", now: at(2026, 10, 19, 12, 30), want: at(2026, 10, 19, 13, 0), wantOK: true},
+		{name: "Before Opening", now: at(2026, 10, 19, 7, 0), want: at(2026, 10, 19, 9, 0), wantOK: true},
+		{name: "After Closing Skips Closed Days", now: at(2026, 10, 19, 17, 0), want: at(2026, 10, 23, 9, 0), wantOK: true},
+		{name: "Weekend", now: at(2026, 10, 24, 10, 0), want: at(2026, 10, 26, 9, 0), wantOK: true},
+		{name: "Other Time Zone", now: time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC), want: time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC), wantOK: true},
+		{name: "Holiday", now: at(2026, 3, 23, 10, 0), want: at(2026, 3, 27, 9, 0), wantOK: true},
+		{name: "Ramadan Hours", now: at(2026, 3, 9, 9, 30), want: at(2026, 3, 9, 10, 0), wantOK: true},
+		{name: "Ramadan Closes Early", now: at(2026, 3, 16, 15, 30), want: at(2026, 3, 20, 9, 0), wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := calendar.NextOpening(tt.now)
+			if ok != tt.wantOK {
+				t.Fatalf("NextOpening() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextOpening() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBusinessHours_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "Unknown Purpose", config: `{"bake_sale": {"timezone": "UTC"}}`},
+		{name: "Missing Timezone", config: `{"admin_support": {"hours": {"monday": ["09:00-17:00"]}}}`},
+		{name: "Unknown Timezone", config: `{"admin_support": {"timezone": "Mars/Olympus"}}`},
+		{name: "Unknown Weekday", config: `{"admin_support": {"timezone": "UTC", "hours": {"funday": ["09:00-17:00"]}}}`},
+		{name: "Range Past Midnight", config: `{"admin_support": {"timezone": "UTC", "hours": {"monday": ["22:00-02:00"]}}}`},
+		{name: "Bad Clock", config: `{"admin_support": {"timezone": "UTC", "hours": {"monday": ["9am-5pm"]}}}`},
+		{name: "Ramadan Ends Before Start", config: `{"admin_support": {"timezone": "UTC", "ramadan": [{"from": "2026-03-19", "to": "2026-02-18"}]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := services.ParseBusinessHours([]byte(tt.config)); err == nil {
+				t.Error("ParseBusinessHours() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestConversationPolicy_Evaluate(t *testing.T) {
+	t.Setenv("CONVERSATION_POLICY_NIKKAH_SERVICE", "")
+	t.Setenv("CONVERSATION_POLICY_GENERAL_SUPPORT", "same_gender")
+	policy, err := services.LoadConversationPolicy()
+	if err != nil {
+		t.Fatalf("LoadConversationPolicy() error = %v", err)
+	}
+
+	man := domain.User{ID: uuid.New(), Gender: "Male"}
+	otherMan := domain.User{ID: uuid.New(), Gender: "male"}
+	woman := domain.User{ID: uuid.New(), Gender: " female "}
+	unknown := domain.User{ID: uuid.New(), Gender: ""}
+	approved := &domain.Match{Status: domain.MatchStatusApproved}
+
+	tests := []struct {
+		name    string
+		input   services.PolicyInput
+		wantErr bool
+	}{
+		{name: "Nikkah Rules Lifted By Empty Override", input: services.PolicyInput{Purpose: domain.ConversationPurposeNikkah, User: man, Partner: otherMan}},
+		{name: "Revert Same Gender", input: services.PolicyInput{Purpose: domain.ConversationPurposeRevertService, User: man, Partner: otherMan}},
+		{name: "Revert Opposite Gender", input: services.PolicyInput{Purpose: domain.ConversationPurposeRevertService, User: man, Partner: woman}, wantErr: true},
+		{name: "Revert Unknown Gender", input: services.PolicyInput{Purpose: domain.ConversationPurposeRevertService, User: unknown, Partner: man}, wantErr: true},
+		{name: "Support Override Applies", input: services.PolicyInput{Purpose: domain.ConversationPurposeGeneralSupport, User: woman, Partner: man, Match: approved}, wantErr: true},
+		{name: "Admin Support Unrestricted", input: services.PolicyInput{Purpose: domain.ConversationPurposeAdminSupport, User: woman, Partner: unknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Evaluate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var appErr *apperror.Error
+			if err != nil && (!errors.As(err, &appErr) || appErr.Code != apperror.CodeForbidden) {
+				t.Errorf("Evaluate() error = %v, want a forbidden apperror", err)
+			}
+		})
+	}
+}
+
+func TestLoadConversationPolicy_Defaults(t *testing.T) {
+	policy, err := services.LoadConversationPolicy()
+	if err != nil {
+		t.Fatalf("LoadConversationPolicy() error = %v", err)
+	}
+
+	man := domain.User{ID: uuid.New(), Gender: "male"}
+	otherMan := domain.User{ID: uuid.New(), Gender: "male"}
+	woman := domain.User{ID: uuid.New(), Gender: "female"}
+
+	tests := []struct {
+		name    string
+		input   services.PolicyInput
+		wantErr bool
+	}{
+		{name: "Nikkah Without Match", input: services.PolicyInput{Purpose: domain.ConversationPurposeNikkah, User: man, Partner: woman}},
+		{name: "Nikkah Same Gender", input: services.PolicyInput{Purpose: domain.ConversationPurposeNikkah, User: man, Partner: otherMan}, wantErr: true},
+		{name: "Revert Opposite Gender", input: services.PolicyInput{Purpose: domain.ConversationPurposeRevertService, User: man, Partner: woman}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Evaluate(tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConversationRules(t *testing.T) {
+	man := domain.User{ID: uuid.New(), Gender: "male"}
+	woman := domain.User{ID: uuid.New(), Gender: "female"}
+
+	tests := []struct {
+		name    string
+		rule    services.ConversationRule
+		input   services.PolicyInput
+		wantErr bool
+	}{
+		{name: "Opposite Gender Allowed", rule: services.OppositeGenderRule{}, input: services.PolicyInput{User: man, Partner: woman}},
+		{name: "Opposite Gender Rejects Same", rule: services.OppositeGenderRule{}, input: services.PolicyInput{User: woman, Partner: woman}, wantErr: true},
+		{name: "Approved Match", rule: services.ApprovedMatchRule{}, input: services.PolicyInput{Match: &domain.Match{Status: domain.MatchStatusApproved}}},
+		{name: "Pending Match", rule: services.ApprovedMatchRule{}, input: services.PolicyInput{Match: &domain.Match{Status: domain.MatchStatusPending}}, wantErr: true},
+		{name: "No Match", rule: services.ApprovedMatchRule{}, input: services.PolicyInput{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Evaluate(tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("%s.Evaluate() error = %v, wantErr %v", tt.rule.Name(), err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConversationPolicy_UnknownRule(t *testing.T) {
+	t.Setenv("CONVERSATION_POLICY_REVERT_SERVICE", "same_gender,no_such_rule")
+	if _, err := services.LoadConversationPolicy(); err == nil {
+		t.Error("LoadConversationPolicy() error = nil, want an error for an unknown rule")
+	}
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestCheckStatusChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		staff    bool
+		purpose  domain.ConversationPurpose
+		from     domain.ConversationStatus
+		to       domain.ConversationStatus
+		wantCode apperror.Code
+	}{
+		{name: "Participant Resolves", purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusOpen, to: domain.ConversationStatusResolved},
+		{name: "Participant Reopens Resolved", purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusResolved, to: domain.ConversationStatusOpen},
+		{name: "Participant Closes Private Chat", purpose: domain.ConversationPurposeNikkah, from: domain.ConversationStatusOpen, to: domain.ConversationStatusClosed},
+		{name: "Participant Cannot Close Support", purpose: domain.ConversationPurposeAdminSupport, from: domain.ConversationStatusOpen, to: domain.ConversationStatusClosed, wantCode: apperror.CodeForbidden},
+		{name: "Participant Cannot Set Pending", purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusOpen, to: domain.ConversationStatusPending, wantCode: apperror.CodeForbidden},
+		{name: "Participant Cannot Reopen Closed", purpose: domain.ConversationPurposeNikkah, from: domain.ConversationStatusClosed, to: domain.ConversationStatusOpen, wantCode: apperror.CodeForbidden},
+		{name: "Staff Sets Pending", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusOpen, to: domain.ConversationStatusPending},
+		{name: "Staff Closes Support", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusResolved, to: domain.ConversationStatusClosed},
+		{name: "Staff Reopens Closed", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusClosed, to: domain.ConversationStatusOpen},
+		{name: "Closed Cannot Resolve", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusClosed, to: domain.ConversationStatusResolved, wantCode: apperror.CodeConflict},
+		{name: "Resolved Cannot Go Pending", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusResolved, to: domain.ConversationStatusPending, wantCode: apperror.CodeConflict},
+		{name: "Same Status", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusOpen, to: domain.ConversationStatusOpen, wantCode: apperror.CodeConflict},
+		{name: "Unknown Status", staff: true, purpose: domain.ConversationPurposeGeneralSupport, from: domain.ConversationStatusOpen, to: "archived", wantCode: apperror.CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.CheckStatusChange(tt.staff, tt.purpose, tt.from, tt.to)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("CheckStatusChange() error = %v, want nil", err)
+				}
+				return
+			}
+			var appErr *apperror.Error
+			if !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+				t.Errorf("CheckStatusChange() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestChangeStatus_DrainsSupportQueue(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       domain.ConversationStatus
+		wantAssigned bool
+	}{
+		{name: "Resolved", status: domain.ConversationStatusResolved, wantAssigned: true},
+		{name: "Closed", status: domain.ConversationStatusClosed, wantAssigned: true},
+		{name: "Pending", status: domain.ConversationStatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			notifier := newRecordingNotifier()
+			support := newSupportService(db, notifier, 1)
+			agent := createUser(t, db, "agent")
+			addStaff(t, db, agent.ID, domain.StaffRoleAgent)
+			if _, err := support.SetAvailability(agent.ID, true); err != nil {
+				t.Fatalf("SetAvailability() error = %v", err)
+			}
+			first, err := support.OpenTicket(createUser(t, db, "aisha").ID, domain.ConversationPurposeGeneralSupport)
+			if err != nil {
+				t.Fatalf("OpenTicket() error = %v", err)
+			}
+			second, err := support.OpenTicket(createUser(t, db, "bilal").ID, domain.ConversationPurposeGeneralSupport)
+			if err != nil {
+				t.Fatalf("OpenTicket() error = %v", err)
+			}
+
+			if _, err := newChatService(db, notifier).ChangeStatus(agent.ID, first.ID, tt.status); err != nil {
+				t.Fatalf("ChangeStatus() error = %v", err)
+			}
+			var ticket domain.SupportTicket
+			db.First(&ticket, "conversation_id = ?", second.ID)
+			if assigned := ticket.Status == domain.SupportTicketStatusAssigned; assigned != tt.wantAssigned {
+				t.Errorf("queued ticket status = %s, want assigned %t", ticket.Status, tt.wantAssigned)
+			}
+		})
+	}
+}
+
+func TestSendMessage_ReopensAfterModeration(t *testing.T) {
+	db := newTestDB(t)
+	notifier := newRecordingNotifier()
+	moderation := services.NewModerationPipeline(&services.LinkFilter{
+		Action:   domain.ModerationActionReject,
+		Purposes: []domain.ConversationPurpose{domain.ConversationPurposeGeneralSupport},
+	})
+	chat := services.NewChatService(db, services.NewMessageTypeRegistry(), moderation, services.NewConversationPolicy(), services.BusinessHours{}, newSupportService(db, notifier, 1), notifier)
+	aisha := createUser(t, db, "aisha")
+	agent := createUser(t, db, "agent")
+	addStaff(t, db, agent.ID, domain.StaffRoleAgent)
+	conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeGeneralSupport, aisha, agent)
+	if _, err := chat.ChangeStatus(agent.ID, conversation.ID, domain.ConversationStatusResolved); err != nil {
+		t.Fatalf("ChangeStatus() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		content    string
+		wantCode   apperror.Code
+		wantStatus domain.ConversationStatus
+	}{
+		{name: "Rejected", content: "see example.com", wantCode: apperror.CodeRejected, wantStatus: domain.ConversationStatusResolved},
+		{name: "Accepted", content: "it broke again", wantStatus: domain.ConversationStatusOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := chat.SendMessage(aisha.ID, conversation.ID, tt.content, "text", "", nil, nil, nil)
+			checkCode(t, err, tt.wantCode)
+			var stored domain.Conversation
+			db.First(&stored, "id = ?", conversation.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns a SQLite database in a temporary directory with every
+// table the services use, including those owned by the main Limestone
+// schema in production.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "chat.db") + "?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=off"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&domain.User{},
+		&domain.Conversation{},
+		&domain.ConversationParticipant{},
+		&domain.Message{},
+		&domain.MessageRead{},
+		&domain.Attachment{},
+		&domain.ModerationDecision{},
+		&domain.Report{},
+		&domain.UserSanction{},
+		&domain.StaffMember{},
+		&domain.UserBlock{},
+		&domain.Guardian{},
+		&domain.Match{},
+		&domain.SupportTicket{},
+		&domain.SupportAgent{},
+		&domain.SupportEscalation{},
+		&domain.MentorProfile{},
+		&domain.Mentorship{},
+		&domain.MentorshipDecline{},
+		&domain.SavedReply{},
+		&domain.SatisfactionSurvey{},
+		&domain.OutOfHoursReply{},
+		&domain.QuietHours{},
+		&domain.OutboxNotification{},
+		&domain.NotificationSetting{},
+		&domain.EmailDigest{},
+		&domain.MessageMention{},
+		&domain.PinnedMessage{},
+	); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, username string) domain.User {
+	t.Helper()
+	user := domain.User{
+		ID:             uuid.New(),
+		Email:          username + "@example.com",
+		Username:       username,
+		HashedPassword: "x",
+		IsVerified:     true,
+		FirstName:      username,
+		LastName:       "Test",
+		PhoneNumber:    "+620000000",
+		Gender:         "male",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createConversation creates a conversation started by the first member,
+// with every member as a participant.
+func createConversation(t *testing.T, db *gorm.DB, conversationType domain.ConversationType, purpose domain.ConversationPurpose, members ...domain.User) domain.Conversation {
+	t.Helper()
+	conversation := domain.Conversation{
+		ID:        uuid.New(),
+		CreatorID: members[0].ID,
+		Type:      conversationType,
+		Purpose:   purpose,
+		Status:    domain.ConversationStatusOpen,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.Omit("Creator").Create(&conversation).Error; err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	for _, member := range members {
+		addParticipant(t, db, conversation.ID, member.ID, domain.ParticipantRoleMember)
+	}
+	return conversation
+}
+
+func addParticipant(t *testing.T, db *gorm.DB, conversationID uuid.UUID, userID uuid.UUID, role string) domain.ConversationParticipant {
+	t.Helper()
+	participant := domain.ConversationParticipant{
+		ConversationID: conversationID,
+		UserID:         userID,
+		JoinedAt:       time.Now().Add(-time.Hour),
+		Role:           role,
+	}
+	if err := db.Omit("Conversation", "User", "LastReadMessage").Create(&participant).Error; err != nil {
+		t.Fatalf("failed to add participant: %v", err)
+	}
+	return participant
+}
+
+// checkCode fails the test unless err is an apperror with code, or nil when
+// code is empty.
+func checkCode(t *testing.T, err error, code apperror.Code) {
+	t.Helper()
+	if code == "" {
+		if err != nil {
+			t.Fatalf("error = %v, want nil", err)
+		}
+		return
+	}
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Code != code {
+		t.Fatalf("error = %v, want code %s", err, code)
+	}
+}
+
+// newChatService returns a chat service that masks contact details in nikkah
+// chats, as configured in production, and has no other filters or rules.
+func newChatService(db *gorm.DB, notifier services.LiveNotifier) services.ChatService {
+	moderation := services.NewModerationPipeline(&services.ContactDetailsFilter{
+		Action:   domain.ModerationActionMask,
+		Purposes: []domain.ConversationPurpose{domain.ConversationPurposeNikkah},
+	})
+	return services.NewChatService(db, services.NewMessageTypeRegistry(), moderation, services.NewConversationPolicy(), services.BusinessHours{}, newSupportService(db, notifier, 1), notifier)
+}
+
+// newSupportService returns a least-load support queue whose agents hold at
+// most maxTickets tickets.
+func newSupportService(db *gorm.DB, notifier services.LiveNotifier, maxTickets int) services.SupportService {
+	return services.NewSupportService(db, notifier, services.LeastLoadStrategy{}, maxTickets, services.SLATargets{})
+}
+
+// recordingNotifier is a services.LiveNotifier that records what it is asked
+// to push.
+type recordingNotifier struct {
+	broadcasts   []domain.Event
+	messages     []*domain.Message
+	userEvents   map[uuid.UUID][]domain.Event
+	disconnected []uuid.UUID
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{userEvents: make(map[uuid.UUID][]domain.Event)}
+}
+
+func (n *recordingNotifier) BroadcastMessage(message *domain.Message) {
+	n.messages = append(n.messages, message)
+}
+
+func (n *recordingNotifier) BroadcastEvent(event domain.Event) {
+	n.broadcasts = append(n.broadcasts, event)
+}
+
+func (n *recordingNotifier) NotifyUser(userID uuid.UUID, event domain.Event) {
+	n.userEvents[userID] = append(n.userEvents[userID], event)
+}
+
+func (n *recordingNotifier) DisconnectUser(userID uuid.UUID, event domain.Event) {
+	n.userEvents[userID] = append(n.userEvents[userID], event)
+	n.disconnected = append(n.disconnected, userID)
+}
+
+func (n *recordingNotifier) DisconnectParticipant(conversationID uuid.UUID, userID uuid.UUID, event domain.Event) {
+	n.userEvents[userID] = append(n.userEvents[userID], event)
+	n.disconnected = append(n.disconnected, userID)
+}
+
+// hasEvent reports whether events include one of the given type.
+func hasEvent(events []domain.Event, eventType domain.EventType) bool {
+	for _, event := range events {
+		if event.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func addStaff(t *testing.T, db *gorm.DB, userID uuid.UUID, role domain.StaffRole) {
+	t.Helper()
+	if err := db.Create(&domain.StaffMember{UserID: userID, Role: role}).Error; err != nil {
+		t.Fatalf("failed to add staff role %s: %v", role, err)
+	}
+}
+
+// createMessage stores a text message directly, bypassing the chat service.
+func createMessage(t *testing.T, db *gorm.DB, conversationID uuid.UUID, senderID uuid.UUID, content string) domain.Message {
+	t.Helper()
+	message := domain.Message{
+		ID:             uuid.New(),
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Content:        content,
+		MessageType:    "text",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if err := db.Omit("Conversation", "Sender", "ReplyToMessage", "ThreadRoot", "Attachment").Create(&message).Error; err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	return message
+}
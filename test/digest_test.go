@@ -0,0 +1,121 @@
+package test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/email"
+)
+
+func TestDigestTemplates_Render(t *testing.T) {
+	templates, err := services.LoadDigestTemplates("../config/templates")
+	if err != nil {
+		t.Fatalf("LoadDigestTemplates() error = %v", err)
+	}
+	sentAt := time.Date(2026, 10, 17, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		digest      services.Digest
+		wantSubject string
+		wantText    []string
+		wantHTML    []string
+	}{
+		{
+			name: "One Message",
+			digest: services.Digest{
+				FirstName: "Aisha",
+				Unread:    1,
+				Conversations: []services.DigestConversation{
+					{Title: "Revert service", Unread: 1, Messages: []services.DigestMessage{{Sender: "Yusuf", Preview: "How was your first Jumu'ah?", SentAt: sentAt}}},
+				},
+			},
+			wantSubject: "You have 1 unread message",
+			wantText:    []string{"Assalamu'alaikum Aisha", "1 unread message on", "Yusuf, 17 Oct 08:30 UTC: How was your first Jumu'ah?"},
+			wantHTML:    []string{"How was your first Jumu&#39;ah?"},
+		},
+		{
+			name: "More Than Shown",
+			digest: services.Digest{
+				FirstName: "Umar",
+				Unread:    7,
+				AppURL:    "https://app.masjids.io/chat",
+				Conversations: []services.DigestConversation{
+					{Title: "Nikkah service", Unread: 7, Messages: []services.DigestMessage{{Sender: "Maryam", Preview: "<b>Salam</b>", SentAt: sentAt}}},
+				},
+			},
+			wantSubject: "You have 7 unread messages",
+			wantText:    []string{"7 unread messages", "... and 6 more", "https://app.masjids.io/chat"},
+			wantHTML:    []string{"&lt;b&gt;Salam&lt;/b&gt;", "... and 6 more", `href="https://app.masjids.io/chat"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, text, html, err := templates.Render(tt.digest)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("Render() subject = %q, want %q", subject, tt.wantSubject)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(text, want) {
+					t.Errorf("Render() text = %q, want it to contain %q", text, want)
+				}
+			}
+			for _, want := range tt.wantHTML {
+				if !strings.Contains(html, want) {
+					t.Errorf("Render() html = %q, want it to contain %q", html, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	raw, err := email.BuildMessage("Limestone <chat@masjids.io>", "aisha@example.com", "Salam, 2 unread messages", "Plain ✓", "<p>HTML ✓</p>", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("BuildMessage() error = %v", err)
+	}
+	message, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != "Salam, 2 unread messages" {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, "Salam, 2 unread messages")
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", mediaType, err)
+	}
+
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{contentType: "text/plain; charset=utf-8", body: "Plain ✓"},
+		{contentType: "text/html; charset=utf-8", body: "<p>HTML ✓</p>"},
+	}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for _, tt := range tests {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		if got := part.Header.Get("Content-Type"); got != tt.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, tt.contentType)
+		}
+		if string(body) != tt.body {
+			t.Errorf("part body = %q, want %q", body, tt.body)
+		}
+	}
+}
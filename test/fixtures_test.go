@@ -0,0 +1,17 @@
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fixtureTime is the time candidate fixtures are placed around.
+var fixtureTime = time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+// fixtureID returns a fixed UUID ending in n, so failures name fixtures
+// readably.
+func fixtureID(n int) uuid.UUID {
+	return uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012x", n))
+}
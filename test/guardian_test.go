@@ -0,0 +1,247 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+const contactMessage = "Call me on +62 812 3456 7890"
+
+func TestConversationApproval_Unguarded(t *testing.T) {
+	tests := []struct {
+		name            string
+		guardian        bool
+		requireApproval bool
+		wantCode        apperror.Code
+		wantApproved    bool
+	}{
+		{name: "No Guardian", wantApproved: true},
+		{name: "Guardian Without Approval", guardian: true, wantApproved: true},
+		{name: "Guardian Requiring Approval", guardian: true, requireApproval: true, wantCode: apperror.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ahmad := createUser(t, db, "ahmad")
+			fatimah := createUser(t, db, "fatimah")
+			conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeNikkah, ahmad, fatimah)
+			if tt.guardian {
+				wali := createUser(t, db, "wali")
+				addParticipant(t, db, conversation.ID, wali.ID, domain.ParticipantRoleObserver)
+				db.Model(&domain.ConversationParticipant{}).Where("user_id = ?", wali.ID).Update("approval_required", tt.requireApproval)
+			}
+
+			message, err := newChatService(db, newRecordingNotifier()).SendMessage(ahmad.ID, conversation.ID, contactMessage, "text", "", nil, nil, nil)
+			checkCode(t, err, tt.wantCode)
+			if err == nil && message.Content != contactMessage {
+				t.Errorf("Content = %q, want contact details kept once approved", message.Content)
+			}
+			var stored domain.Conversation
+			db.First(&stored, "id = ?", conversation.ID)
+			if stored.ApprovedAt.Valid != tt.wantApproved {
+				t.Errorf("ApprovedAt.Valid = %t, want %t", stored.ApprovedAt.Valid, tt.wantApproved)
+			}
+		})
+	}
+}
+
+func TestConversationApproval_GuardianLeaves(t *testing.T) {
+	db := newTestDB(t)
+	notifier := newRecordingNotifier()
+	ahmad := createUser(t, db, "ahmad")
+	fatimah := createUser(t, db, "fatimah")
+	wali := createUser(t, db, "wali")
+	conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeNikkah, ahmad, fatimah)
+	addParticipant(t, db, conversation.ID, wali.ID, domain.ParticipantRoleObserver)
+	db.Model(&domain.ConversationParticipant{}).Where("user_id = ?", wali.ID).Update("approval_required", true)
+
+	if err := services.NewGuardianService(db, notifier).RemoveObserver(wali.ID, conversation.ID, wali.ID); err != nil {
+		t.Fatalf("RemoveObserver() error = %v", err)
+	}
+	if !hasEvent(notifier.broadcasts, domain.EventConversationApproved) {
+		t.Errorf("broadcasts = %v, want a %s event", notifier.broadcasts, domain.EventConversationApproved)
+	}
+	message, err := newChatService(db, notifier).SendMessage(fatimah.ID, conversation.ID, contactMessage, "text", "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if strings.Contains(message.Content, "*") {
+		t.Errorf("Content = %q, want contact details kept once approved", message.Content)
+	}
+}
+
+func TestGuardianService_SetGuardian(t *testing.T) {
+	db := newTestDB(t)
+	guardians := services.NewGuardianService(db, newRecordingNotifier())
+	fatimah := createUser(t, db, "fatimah")
+	wali := createUser(t, db, "wali")
+	uncle := createUser(t, db, "uncle")
+
+	_, err := guardians.SetGuardian(fatimah.ID, fatimah.ID, true)
+	checkCode(t, err, apperror.CodeInvalidRequest)
+	_, err = guardians.GetGuardian(fatimah.ID)
+	checkCode(t, err, apperror.CodeNotFound)
+
+	if _, err := guardians.SetGuardian(fatimah.ID, wali.ID, true); err != nil {
+		t.Fatalf("SetGuardian() error = %v", err)
+	}
+	if _, err := guardians.SetGuardian(fatimah.ID, uncle.ID, false); err != nil {
+		t.Fatalf("SetGuardian() replacing error = %v", err)
+	}
+	guardian, err := guardians.GetGuardian(fatimah.ID)
+	if err != nil {
+		t.Fatalf("GetGuardian() error = %v", err)
+	}
+	if guardian.GuardianID != uncle.ID || guardian.RequireApproval {
+		t.Errorf("GetGuardian() = %+v, want the replacement without approval", guardian)
+	}
+
+	if err := guardians.RemoveGuardian(fatimah.ID); err != nil {
+		t.Fatalf("RemoveGuardian() error = %v", err)
+	}
+	_, err = guardians.GetGuardian(fatimah.ID)
+	checkCode(t, err, apperror.CodeNotFound)
+}
+
+func TestGuardianObservers(t *testing.T) {
+	db := newTestDB(t)
+	guardians := services.NewGuardianService(db, newRecordingNotifier())
+	ahmad := createUser(t, db, "ahmad")
+	fatimah := createUser(t, db, "fatimah")
+	wali := createUser(t, db, "wali")
+	// Both share a guardian, who requires approval for one of them only.
+	guardians.SetGuardian(ahmad.ID, wali.ID, false)
+	guardians.SetGuardian(fatimah.ID, wali.ID, true)
+
+	observers, err := newChatService(db, newRecordingNotifier()).GuardianObservers(uuid.New(), ahmad.ID, fatimah.ID)
+	if err != nil {
+		t.Fatalf("GuardianObservers() error = %v", err)
+	}
+	if len(observers) != 1 {
+		t.Fatalf("len(observers) = %d, want 1", len(observers))
+	}
+	if observers[0].UserID != wali.ID || observers[0].Role != domain.ParticipantRoleObserver || !observers[0].ApprovalRequired {
+		t.Errorf("observer = %+v, want the wali observing with approval required", observers[0])
+	}
+
+	// A guardian who is a member of the conversation does not observe it.
+	guardians.SetGuardian(ahmad.ID, fatimah.ID, true)
+	guardians.RemoveGuardian(fatimah.ID)
+	observers, err = newChatService(db, newRecordingNotifier()).GuardianObservers(uuid.New(), ahmad.ID, fatimah.ID)
+	if err != nil {
+		t.Fatalf("GuardianObservers() error = %v", err)
+	}
+	if len(observers) != 0 {
+		t.Errorf("observers = %+v, want none", observers)
+	}
+}
+
+func TestGuardianService_ApproveConversation(t *testing.T) {
+	db := newTestDB(t)
+	notifier := newRecordingNotifier()
+	guardians := services.NewGuardianService(db, notifier)
+	chat := newChatService(db, notifier)
+	ahmad := createUser(t, db, "ahmad")
+	fatimah := createUser(t, db, "fatimah")
+	wali := createUser(t, db, "wali")
+	mahram := createUser(t, db, "mahram")
+	relative := createUser(t, db, "relative")
+	conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeNikkah, ahmad, fatimah)
+	for _, observer := range []domain.User{wali, mahram, relative} {
+		addParticipant(t, db, conversation.ID, observer.ID, domain.ParticipantRoleObserver)
+	}
+	db.Model(&domain.ConversationParticipant{}).Where("user_id IN ?", []uuid.UUID{wali.ID, mahram.ID}).Update("approval_required", true)
+
+	_, err := chat.SendMessage(ahmad.ID, conversation.ID, "salam", "text", "", nil, nil, nil)
+	checkCode(t, err, apperror.CodeForbidden)
+	_, err = chat.SendMessage(wali.ID, conversation.ID, "salam", "text", "", nil, nil, nil)
+	checkCode(t, err, apperror.CodeForbidden)
+
+	tests := []struct {
+		name         string
+		guardian     domain.User
+		wantCode     apperror.Code
+		wantApproved bool
+	}{
+		{name: "Member", guardian: ahmad, wantCode: apperror.CodeForbidden},
+		{name: "Observer Without Approval", guardian: relative, wantCode: apperror.CodeForbidden},
+		{name: "First Guardian", guardian: wali},
+		{name: "Twice", guardian: wali, wantCode: apperror.CodeConflict},
+		{name: "Last Guardian", guardian: mahram, wantApproved: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier.broadcasts = nil
+			approved, err := guardians.ApproveConversation(tt.guardian.ID, conversation.ID)
+			checkCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if approved.ApprovedAt.Valid != tt.wantApproved {
+				t.Errorf("ApprovedAt.Valid = %t, want %t", approved.ApprovedAt.Valid, tt.wantApproved)
+			}
+			if hasEvent(notifier.broadcasts, domain.EventConversationApproved) != tt.wantApproved {
+				t.Errorf("broadcasts = %v, want %s only once approved", notifier.broadcasts, domain.EventConversationApproved)
+			}
+		})
+	}
+
+	if _, err := chat.SendMessage(ahmad.ID, conversation.ID, "salam", "text", "", nil, nil, nil); err != nil {
+		t.Errorf("SendMessage() after approval error = %v", err)
+	}
+}
+
+func TestGuardianService_RemoveObserver(t *testing.T) {
+	tests := []struct {
+		name      string
+		moderator bool
+		self      bool
+		wantCode  apperror.Code
+	}{
+		{name: "Self", self: true},
+		{name: "Moderator", moderator: true},
+		{name: "Member", wantCode: apperror.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			notifier := newRecordingNotifier()
+			ahmad := createUser(t, db, "ahmad")
+			fatimah := createUser(t, db, "fatimah")
+			wali := createUser(t, db, "wali")
+			conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeNikkah, ahmad, fatimah)
+			addParticipant(t, db, conversation.ID, wali.ID, domain.ParticipantRoleObserver)
+
+			actor := ahmad
+			if tt.self {
+				actor = wali
+			}
+			if tt.moderator {
+				actor = createUser(t, db, "moderator")
+				addStaff(t, db, actor.ID, domain.StaffRoleModerator)
+			}
+			err := services.NewGuardianService(db, notifier).RemoveObserver(actor.ID, conversation.ID, wali.ID)
+			checkCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if !hasEvent(notifier.broadcasts, domain.EventObserverLeft) || len(notifier.disconnected) != 1 || notifier.disconnected[0] != wali.ID {
+				t.Errorf("broadcasts = %v, disconnected = %v, want the guardian announced and disconnected", notifier.broadcasts, notifier.disconnected)
+			}
+			var system int64
+			db.Model(&domain.Message{}).Where("conversation_id = ? AND message_type = ?", conversation.ID, domain.MessageTypeSystem).Count(&system)
+			if system != 1 {
+				t.Errorf("system messages = %d, want 1", system)
+			}
+			_, err = newChatService(db, notifier).GetParticipantConversation(wali.ID, conversation.ID)
+			checkCode(t, err, apperror.CodeForbidden)
+		})
+	}
+}
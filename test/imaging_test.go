@@ -0,0 +1,84 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/imaging"
+)
+
+// jpegWithExif encodes a w x h JPEG and inserts an APP1 EXIF segment holding
+// the given orientation and a fake GPS marker string.
+func jpegWithExif(t *testing.T, w, h int, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry[0:], 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, []byte("GPSLatitude-6.2088")...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestImaging_Process(t *testing.T) {
+	tests := []struct {
+		name        string
+		orientation uint16
+		wantWidth   int
+		wantHeight  int
+	}{
+		{name: "Upright", orientation: 1, wantWidth: 640, wantHeight: 400},
+		{name: "Rotated 90 Clockwise", orientation: 6, wantWidth: 400, wantHeight: 640},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := imaging.Process(jpegWithExif(t, 640, 400, tt.orientation), "image/jpeg")
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if bytes.Contains(processed.Image, []byte("Exif")) || bytes.Contains(processed.Image, []byte("GPSLatitude")) {
+				t.Errorf("Process() kept EXIF metadata in the stored image")
+			}
+			if processed.Width != tt.wantWidth || processed.Height != tt.wantHeight {
+				t.Errorf("dimensions got = %dx%d, want %dx%d", processed.Width, processed.Height, tt.wantWidth, tt.wantHeight)
+			}
+
+			thumb, err := jpeg.DecodeConfig(bytes.NewReader(processed.Thumbnail))
+			if err != nil {
+				t.Fatalf("thumbnail is not a valid JPEG: %v", err)
+			}
+			if thumb.Width > 320 || thumb.Height > 320 {
+				t.Errorf("thumbnail got = %dx%d, want at most 320 on the longest side", thumb.Width, thumb.Height)
+			}
+			if len(processed.Preview) == 0 {
+				t.Errorf("Process() returned no preview")
+			}
+		})
+	}
+}
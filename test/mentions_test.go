@@ -0,0 +1,106 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []services.MentionToken
+	}{
+		{name: "No Mentions", content: "Assalamu'alaikum everyone", want: nil},
+		{name: "At Start", content: "@aisha can you help?", want: []services.MentionToken{{Username: "aisha", Offset: 0, Length: 6}}},
+		{name: "Several", content: "Thanks @omar_f and @Yusuf.K", want: []services.MentionToken{
+			{Username: "omar_f", Offset: 7, Length: 7},
+			{Username: "Yusuf.K", Offset: 19, Length: 8},
+		}},
+		{name: "Trailing Punctuation", content: "Ask @bilal. Or @zaid-", want: []services.MentionToken{
+			{Username: "bilal", Offset: 4, Length: 6},
+			{Username: "zaid", Offset: 15, Length: 5},
+		}},
+		{name: "Email Address", content: "Write to info@masjid.org", want: nil},
+		{name: "Lone At", content: "Meet @ 5pm", want: nil},
+		{name: "Offsets In Runes", content: "سلام @fatima", want: []services.MentionToken{{Username: "fatima", Offset: 5, Length: 7}}},
+		{name: "After Punctuation", content: "(@hamza)", want: []services.MentionToken{{Username: "hamza", Offset: 1, Length: 6}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.ParseMentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %+v, want %+v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendMessage_Mentions(t *testing.T) {
+	tests := []struct {
+		name             string
+		conversationType domain.ConversationType
+		content          string
+		wantMentioned    bool
+	}{
+		{name: "Participant", conversationType: domain.ConversationTypeGroup, content: "@Bilal can you help?", wantMentioned: true},
+		{name: "Non Participant", conversationType: domain.ConversationTypeGroup, content: "@zaid can you help?"},
+		{name: "Unknown Name", conversationType: domain.ConversationTypeGroup, content: "@nobody can you help?"},
+		{name: "Private", conversationType: domain.ConversationTypePrivate, content: "@bilal can you help?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			notifier := newRecordingNotifier()
+			aisha := createUser(t, db, "aisha")
+			bilal := createUser(t, db, "bilal")
+			zaid := createUser(t, db, "zaid")
+			conversation := createConversation(t, db, tt.conversationType, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+
+			message, err := newChatService(db, notifier).SendMessage(aisha.ID, conversation.ID, tt.content, "text", "", nil, nil, nil)
+			if err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+			if message.Content != tt.content {
+				t.Errorf("Content = %q, want %q", message.Content, tt.content)
+			}
+			if got := strings.Contains(string(message.Metadata), bilal.ID.String()); got != tt.wantMentioned {
+				t.Errorf("Metadata = %s, want bilal mentioned: %t", message.Metadata, tt.wantMentioned)
+			}
+
+			var mentions []domain.MessageMention
+			db.Where("message_id = ?", message.ID).Find(&mentions)
+			if tt.wantMentioned && (len(mentions) != 1 || mentions[0].UserID != bilal.ID) {
+				t.Errorf("mentions = %+v, want bilal", mentions)
+			}
+			if !tt.wantMentioned && len(mentions) != 0 {
+				t.Errorf("mentions = %+v, want none", mentions)
+			}
+
+			var notification domain.OutboxNotification
+			if err := db.Where("message_id = ? AND recipient_id = ?", message.ID, bilal.ID).First(&notification).Error; err != nil {
+				t.Fatalf("failed to load bilal's notification: %v", err)
+			}
+			wantType := domain.NotificationTypeMessage
+			if tt.wantMentioned {
+				wantType = domain.NotificationTypeMention
+			}
+			if notification.Type != wantType {
+				t.Errorf("notification Type = %s, want %s", notification.Type, wantType)
+			}
+			if hasEvent(notifier.userEvents[bilal.ID], domain.EventMentioned) != tt.wantMentioned {
+				t.Errorf("bilal's events = %v, want %s: %t", notifier.userEvents[bilal.ID], domain.EventMentioned, tt.wantMentioned)
+			}
+			var outsider int64
+			db.Model(&domain.OutboxNotification{}).Where("recipient_id = ?", zaid.ID).Count(&outsider)
+			if outsider != 0 || len(notifier.userEvents[zaid.ID]) != 0 {
+				t.Errorf("zaid got %d notifications and events %v, want none", outsider, notifier.userEvents[zaid.ID])
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestSelectMentor(t *testing.T) {
+	revertID := fixtureID(0xaa)
+	arabic := services.MentorCandidate{UserID: fixtureID(1), Gender: domain.GenderFemale, Languages: []string{"ar"}, Capacity: 3, Mentees: 2, LastMatchedAt: fixtureTime}
+	english := services.MentorCandidate{UserID: fixtureID(2), Gender: domain.GenderFemale, Languages: []string{"en", "fr"}, Capacity: 4, Mentees: 1, LastMatchedAt: fixtureTime.Add(time.Hour)}
+	englishOld := services.MentorCandidate{UserID: fixtureID(3), Gender: domain.GenderFemale, Languages: []string{"en"}, Capacity: 4, Mentees: 1, LastMatchedAt: fixtureTime}
+	englishFull := services.MentorCandidate{UserID: fixtureID(4), Gender: domain.GenderFemale, Languages: []string{"en"}, Capacity: 1, Mentees: 1}
+	male := services.MentorCandidate{UserID: fixtureID(5), Gender: domain.GenderMale, Languages: []string{"en"}, Capacity: 5}
+	busyEnglish := services.MentorCandidate{UserID: fixtureID(6), Gender: domain.GenderFemale, Languages: []string{"en"}, Capacity: 4, Mentees: 3}
+	self := services.MentorCandidate{UserID: revertID, Gender: domain.GenderFemale, Languages: []string{"en"}, Capacity: 5}
+
+	tests := []struct {
+		name       string
+		languages  []string
+		excluded   []uuid.UUID
+		candidates []services.MentorCandidate
+		want       uuid.UUID
+		wantOK     bool
+	}{
+		{name: "No Candidates", languages: []string{"en"}, wantOK: false},
+		{name: "Same Gender Only", languages: []string{"en"}, candidates: []services.MentorCandidate{male}, wantOK: false},
+		{name: "Shared Language Required", languages: []string{"ur"}, candidates: []services.MentorCandidate{arabic, english}, wantOK: false},
+		{name: "Full Mentor Skipped", languages: []string{"en"}, candidates: []services.MentorCandidate{englishFull}, wantOK: false},
+		{name: "Revert Is Not Their Own Mentor", languages: []string{"en"}, candidates: []services.MentorCandidate{self}, wantOK: false},
+		{name: "Preferred Language First", languages: []string{"ar", "en"}, candidates: []services.MentorCandidate{english, arabic}, want: arabic.UserID, wantOK: true},
+		{name: "Falls Back To Later Language", languages: []string{"ur", "fr"}, candidates: []services.MentorCandidate{arabic, english}, want: english.UserID, wantOK: true},
+		{name: "Lowest Share Of Capacity", languages: []string{"en"}, candidates: []services.MentorCandidate{busyEnglish, english}, want: english.UserID, wantOK: true},
+		{name: "Ties By Longest Wait", languages: []string{"en"}, candidates: []services.MentorCandidate{english, englishOld}, want: englishOld.UserID, wantOK: true},
+		{name: "Declined Mentor Excluded", languages: []string{"en"}, excluded: []uuid.UUID{englishOld.UserID}, candidates: []services.MentorCandidate{englishOld, busyEnglish}, want: busyEnglish.UserID, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := services.MenteeRequest{RevertID: revertID, Gender: domain.GenderFemale, Languages: tt.languages, Excluded: map[uuid.UUID]bool{}}
+			for _, id := range tt.excluded {
+				request.Excluded[id] = true
+			}
+			got, ok := services.SelectMentor(request, tt.candidates)
+			if ok != tt.wantOK {
+				t.Fatalf("SelectMentor() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got.UserID != tt.want {
+				t.Errorf("SelectMentor() got = %s, want %s", got.UserID, tt.want)
+			}
+		})
+	}
+}
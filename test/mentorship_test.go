@@ -0,0 +1,90 @@
+package test
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestMentorshipService_AcceptOnce(t *testing.T) {
+	db := newTestDB(t)
+	mentorships := services.NewMentorshipService(db, newRecordingNotifier(), 48*time.Hour, 14*24*time.Hour)
+	revert := createUser(t, db, "revert")
+	mentor := createUser(t, db, "mentor")
+	mentorship := domain.Mentorship{
+		ID:         uuid.New(),
+		RevertID:   revert.ID,
+		Gender:     domain.GenderMale,
+		Languages:  "en",
+		Status:     domain.MentorshipStatusProposed,
+		MentorID:   sql.NullString{String: mentor.ID.String(), Valid: true},
+		ProposedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := db.Create(&mentorship).Error; err != nil {
+		t.Fatalf("failed to create mentorship: %v", err)
+	}
+
+	const attempts = 5
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = mentorships.Accept(mentor.ID, mentorship.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, err := range errs {
+		if err == nil {
+			accepted++
+			continue
+		}
+		checkCode(t, err, apperror.CodeConflict)
+	}
+	if accepted != 1 {
+		t.Errorf("accepted %d times, want once", accepted)
+	}
+	var conversations int64
+	db.Model(&domain.Conversation{}).Where("purpose = ?", domain.ConversationPurposeRevertService).Count(&conversations)
+	if conversations != 1 {
+		t.Errorf("conversations = %d, want 1", conversations)
+	}
+}
+
+func TestMentorshipService_RegisterMentor(t *testing.T) {
+	db := newTestDB(t)
+	mentorships := services.NewMentorshipService(db, newRecordingNotifier(), 48*time.Hour, 14*24*time.Hour)
+	mentor := createUser(t, db, "mentor")
+	addStaff(t, db, mentor.ID, domain.StaffRoleMentor)
+
+	for _, active := range []bool{false, true, false} {
+		if _, err := mentorships.RegisterMentor(mentor.ID, 2, []string{"en"}, active); err != nil {
+			t.Fatalf("RegisterMentor(active: %t) error = %v", active, err)
+		}
+		var stored domain.MentorProfile
+		db.First(&stored, "user_id = ?", mentor.ID)
+		if stored.Active != active {
+			t.Errorf("Active after registering with active: %t = %t", active, stored.Active)
+		}
+	}
+
+	revert := createUser(t, db, "revert")
+	mentorship, err := mentorships.RequestMentor(revert.ID, []string{"en"})
+	if err != nil {
+		t.Fatalf("RequestMentor() error = %v", err)
+	}
+	if mentorship.Status != domain.MentorshipStatusRequested || mentorship.MentorID.Valid {
+		t.Errorf("mentorship = %s with mentor %v, want it waiting for an active mentor", mentorship.Status, mentorship.MentorID)
+	}
+}
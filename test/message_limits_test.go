@@ -0,0 +1,53 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/websocket"
+)
+
+func TestMessageLimits_Check(t *testing.T) {
+	limits := websocket.MessageLimits{MaxFrameBytes: 1024, MaxContentRunes: 10, MaxMetadataBytes: 16}
+
+	tests := []struct {
+		name     string
+		content  string
+		metadata []byte
+		wantErr  bool
+	}{
+		{
+			name:    "Arabic Content Counted In Runes",
+			content: "السلام علي", // 10 runes, 19 bytes
+		},
+		{
+			name:    "Content Over Rune Limit",
+			content: strings.Repeat("a", 11),
+			wantErr: true,
+		},
+		{
+			name:     "Metadata Within Limit",
+			metadata: []byte(`{"a":"b"}`),
+		},
+		{
+			name:     "Metadata Over Limit",
+			metadata: []byte(`{"transaction_id":"INV12345"}`),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limits.Check(tt.content, tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var appErr *apperror.Error
+			if err != nil && (!errors.As(err, &appErr) || appErr.Code != apperror.CodeTooLarge) {
+				t.Errorf("Check() error code got = %v, want %q", err, apperror.CodeTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestMessageTypeRegistry_Validate(t *testing.T) {
+	jpeg := &domain.Attachment{MimeType: "image/jpeg"}
+	pdf := &domain.Attachment{MimeType: "application/pdf"}
+	webp := &domain.Attachment{MimeType: "image/webp"}
+	mp3 := &domain.Attachment{MimeType: "audio/mpeg"}
+	opus := &domain.Attachment{MimeType: "application/ogg"}
+	m4a := &domain.Attachment{MimeType: "video/mp4"}
+
+	tests := []struct {
+		name        string
+		messageType string
+		content     string
+		mediaURL    string
+		metadata    string
+		attachment  *domain.Attachment
+		wantErr     bool
+	}{
+		{name: "Valid Text", messageType: "text", content: "Assalamu'alaikum"},
+		{name: "Empty Text", messageType: "text", content: "  ", wantErr: true},
+		{name: "Empty Type", messageType: "", content: "hello", wantErr: true},
+		{name: "Unknown Type", messageType: "sticker", content: "hello", wantErr: true},
+		{name: "System Sent By Client", messageType: "system", content: "hello", wantErr: true},
+		{name: "Survey Sent By Client", messageType: "survey", content: "Rate us", wantErr: true},
+		{name: "Valid Image", messageType: "image", attachment: jpeg, metadata: `{"width": 640, "height": 480}`},
+		{name: "Image Without Attachment", messageType: "image", wantErr: true},
+		{name: "Image With Raw URL", messageType: "image", mediaURL: "https://cdn.example.com/a.jpg", attachment: jpeg, wantErr: true},
+		{name: "Image With PDF Attachment", messageType: "image", attachment: pdf, wantErr: true},
+		{name: "Image With WebP Attachment", messageType: "image", attachment: webp, wantErr: true},
+		{name: "File With PDF Attachment", messageType: "file", attachment: pdf},
+		{name: "Image With Invalid Width", messageType: "image", attachment: jpeg, metadata: `{"width": "wide"}`, wantErr: true},
+		{name: "MP3 Audio", messageType: "audio", attachment: mp3, metadata: `{"duration_seconds": 12}`},
+		{name: "Ogg Opus Voice Note", messageType: "audio", attachment: opus},
+		{name: "M4A Voice Note", messageType: "audio", attachment: m4a},
+		{name: "Audio With PDF Attachment", messageType: "audio", attachment: pdf, wantErr: true},
+		{name: "Text With Attachment", messageType: "text", content: "see file", attachment: pdf, wantErr: true},
+		{name: "Valid Location", messageType: "location", metadata: `{"latitude": -6.2, "longitude": 106.8}`},
+		{name: "Location Out Of Range", messageType: "location", metadata: `{"latitude": 91, "longitude": 0}`, wantErr: true},
+		{name: "Valid Contact Card", messageType: "contact_card", metadata: `{"name": "Imam Yusuf", "phone_number": "+62123"}`},
+		{name: "Contact Card Without Details", messageType: "contact_card", metadata: `{"name": "Imam Yusuf"}`, wantErr: true},
+		{name: "Metadata Not An Object", messageType: "text", content: "hi", metadata: `[1, 2]`, wantErr: true},
+	}
+
+	registry := services.NewMessageTypeRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadata []byte
+			if tt.metadata != "" {
+				metadata = []byte(tt.metadata)
+			}
+			err := registry.Validate(tt.messageType, tt.content, tt.mediaURL, metadata, tt.attachment)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, wantErr %v", tt.messageType, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageTypeRegistry_Register(t *testing.T) {
+	registry := services.NewMessageTypeRegistry()
+	registry.Register(domain.MessageType("poll"), services.MessageTypeHandler{})
+
+	if err := registry.Validate("poll", "", "", nil, nil); err != nil {
+		t.Errorf("Validate() for registered type got error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestModerationPipeline_Run(t *testing.T) {
+	profanity := &services.ProfanityFilter{Action: domain.ModerationActionMask}
+	profanity.AddWords("en", "darn")
+	profanity.AddWords("id", "bodoh")
+
+	pipeline := services.NewModerationPipeline(
+		profanity,
+		&services.LinkFilter{
+			Action:         domain.ModerationActionReject,
+			Purposes:       []domain.ConversationPurpose{domain.ConversationPurposeNikkah},
+			AllowedDomains: []string{"masjids.io"},
+		},
+		&services.ContactDetailsFilter{
+			Action:   domain.ModerationActionMask,
+			Purposes: []domain.ConversationPurpose{domain.ConversationPurposeNikkah},
+		},
+	)
+
+	nikkah := &domain.Conversation{ID: uuid.New(), Purpose: domain.ConversationPurposeNikkah}
+	approvedNikkah := &domain.Conversation{ID: uuid.New(), Purpose: domain.ConversationPurposeNikkah, ApprovedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	support := &domain.Conversation{ID: uuid.New(), Purpose: domain.ConversationPurposeGeneralSupport}
+
+	tests := []struct {
+		name          string
+		conversation  *domain.Conversation
+		content       string
+		metadata      map[string]interface{}
+		wantAction    domain.ModerationAction
+		wantContent   string
+		wantDecisions int
+	}{
+		{name: "Clean Message", conversation: nikkah, content: "Assalamu'alaikum", wantAction: domain.ModerationActionAllow, wantContent: "Assalamu'alaikum"},
+		{name: "Profanity Masked", conversation: support, content: "Darn, that is BODOH.", wantAction: domain.ModerationActionMask, wantContent: "****, that is *****.", wantDecisions: 1},
+		{name: "Link Rejected In Nikkah", conversation: nikkah, content: "see https://example.com/me", wantAction: domain.ModerationActionReject, wantDecisions: 1},
+		{name: "Allowed Link Domain", conversation: nikkah, content: "see https://app.masjids.io/faq", wantAction: domain.ModerationActionAllow, wantContent: "see https://app.masjids.io/faq"},
+		{name: "Link Allowed In Support", conversation: support, content: "see example.com", wantAction: domain.ModerationActionAllow, wantContent: "see example.com"},
+		{name: "Contact Details Masked Before Approval", conversation: nikkah, content: "call +62 812 3456 7890 or a@b.co", wantAction: domain.ModerationActionMask, wantContent: "call ***************** or ******", wantDecisions: 1},
+		{name: "Contact Details Allowed After Approval", conversation: approvedNikkah, content: "call +62 812 3456 7890", wantAction: domain.ModerationActionAllow, wantContent: "call +62 812 3456 7890"},
+		{name: "Date Is Not A Phone Number", conversation: nikkah, content: "meet on 2025-06-22", wantAction: domain.ModerationActionAllow, wantContent: "meet on 2025-06-22"},
+		{name: "Contact Card Phone Before Approval", conversation: nikkah, metadata: map[string]interface{}{"name": "Aisha", "phone_number": "+62 812 3456 7890"}, wantAction: domain.ModerationActionReject, wantDecisions: 1},
+		{name: "Contact Card Email Before Approval", conversation: nikkah, metadata: map[string]interface{}{"name": "Aisha", "email": "aisha@example.com"}, wantAction: domain.ModerationActionReject, wantDecisions: 1},
+		{name: "Contact Card User Before Approval", conversation: nikkah, metadata: map[string]interface{}{"name": "Aisha", "user_id": "12345678-1234-4234-8234-123456789012"}, wantAction: domain.ModerationActionAllow},
+		{name: "Contact Card After Approval", conversation: approvedNikkah, metadata: map[string]interface{}{"name": "Aisha", "phone_number": "+62 812 3456 7890"}, wantAction: domain.ModerationActionAllow},
+		{name: "Nested Metadata Link", conversation: nikkah, content: "my file", metadata: map[string]interface{}{"files": []interface{}{map[string]interface{}{"file_name": "example.com/cv.pdf"}}}, wantAction: domain.ModerationActionReject, wantDecisions: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pipeline.Run(services.ModerationInput{SenderID: uuid.New(), Conversation: tt.conversation, Content: tt.content, Metadata: tt.metadata})
+			if result.Action != tt.wantAction {
+				t.Errorf("action got = %q, want %q", result.Action, tt.wantAction)
+			}
+			if tt.wantAction != domain.ModerationActionReject && result.Content != tt.wantContent {
+				t.Errorf("content got = %q, want %q", result.Content, tt.wantContent)
+			}
+			if len(result.Decisions) != tt.wantDecisions {
+				t.Errorf("decisions got = %d, want %d", len(result.Decisions), tt.wantDecisions)
+			}
+		})
+	}
+}
+
+func TestSendMessage_ContactCardBeforeApproval(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		wantCode apperror.Code
+	}{
+		{name: "Phone Number", metadata: `{"name": "Aisha", "phone_number": "+62 812 3456 7890"}`, wantCode: apperror.CodeRejected},
+		{name: "Email", metadata: `{"name": "Aisha", "email": "aisha@example.com"}`, wantCode: apperror.CodeRejected},
+		{name: "User", metadata: `{"name": "Aisha", "user_id": "12345678-1234-4234-8234-123456789012"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			notifier := newRecordingNotifier()
+			aisha := createUser(t, db, "aisha")
+			bilal := createUser(t, db, "bilal")
+			// Support chats are never approved, so the filter always applies.
+			conversation := createConversation(t, db, domain.ConversationTypePrivate, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+			moderation := services.NewModerationPipeline(&services.ContactDetailsFilter{
+				Action:   domain.ModerationActionMask,
+				Purposes: []domain.ConversationPurpose{domain.ConversationPurposeGeneralSupport},
+			})
+			chat := services.NewChatService(db, services.NewMessageTypeRegistry(), moderation, services.NewConversationPolicy(), services.BusinessHours{}, newSupportService(db, notifier, 1), notifier)
+
+			_, err := chat.SendMessage(aisha.ID, conversation.ID, "", string(domain.MessageTypeContactCard), "", []byte(tt.metadata), nil, nil)
+			checkCode(t, err, tt.wantCode)
+			var stored int64
+			db.Model(&domain.Message{}).Where("conversation_id = ?", conversation.ID).Count(&stored)
+			if stored > 0 != (tt.wantCode == "") {
+				t.Errorf("stored messages = %d, want the card stored only when allowed", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/webhook"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := services.RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		name    string
+		attempt int
+		want    time.Duration
+	}{
+		{name: "First Failure", attempt: 1, want: 30 * time.Second},
+		{name: "Doubles", attempt: 2, want: time.Minute},
+		{name: "Keeps Doubling", attempt: 4, want: 4 * time.Minute},
+		{name: "Capped", attempt: 5, want: 5 * time.Minute},
+		{name: "Stays Capped", attempt: 40, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Backoff(tt.attempt); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationPreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		message domain.Message
+		want    string
+	}{
+		{name: "Short Text", message: domain.Message{Content: "Assalamu'alaikum", MessageType: "text"}, want: "Assalamu'alaikum"},
+		{name: "Long Text Is Shortened", message: domain.Message{Content: strings.Repeat("ب", 200), MessageType: "text"}, want: strings.Repeat("ب", 119) + "…"},
+		{name: "No Text", message: domain.Message{MessageType: "image"}, want: "Sent a image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.NotificationPreview(&tt.message); got != tt.want {
+				t.Errorf("NotificationPreview() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSender_Send(t *testing.T) {
+	const secret = "gateway-secret"
+
+	tests := []struct {
+		name         string
+		status       int
+		wantErr      bool
+		wantRejected bool
+	}{
+		{name: "Delivered", status: http.StatusAccepted},
+		{name: "Bad Request Is Rejected", status: http.StatusBadRequest, wantErr: true, wantRejected: true},
+		{name: "Too Many Requests Is Retried", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "Server Error Is Retried", status: http.StatusBadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				timestamp := r.Header.Get(webhook.TimestampHeader)
+				if got, want := r.Header.Get(webhook.SignatureHeader), webhook.Sign([]byte(secret), timestamp, body); got != want {
+					t.Errorf("signature = %q, want %q", got, want)
+				}
+				if !strings.Contains(string(body), `"type":"message"`) {
+					t.Errorf("body = %s, want a message notification", body)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sender := webhook.NewSender(server.URL, secret, time.Second)
+			err := sender.Send(context.Background(), services.NotificationPayload{
+				ID:          uuid.New(),
+				Type:        domain.NotificationTypeMessage,
+				RecipientID: uuid.New(),
+				Preview:     "Salam",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if rejected := errors.Is(err, services.ErrNotificationRejected); rejected != tt.wantRejected {
+				t.Errorf("Send() rejected = %v, want %v", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestNotificationAllowed(t *testing.T) {
+	at := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	until := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: at.Add(d), Valid: true} }
+
+	tests := []struct {
+		name             string
+		setting          *domain.NotificationSetting
+		participant      domain.ConversationParticipant
+		notificationType domain.NotificationType
+		want             bool
+	}{
+		{name: "Defaults To All", notificationType: domain.NotificationTypeMessage, want: true},
+		{name: "User Level None", setting: &domain.NotificationSetting{Level: domain.NotificationLevelNone}, notificationType: domain.NotificationTypeMessage},
+		{name: "User Mentions Only Skips Messages", setting: &domain.NotificationSetting{Level: domain.NotificationLevelMentions}, notificationType: domain.NotificationTypeMessage},
+		{name: "User Mentions Only Allows Mentions", setting: &domain.NotificationSetting{Level: domain.NotificationLevelMentions}, notificationType: domain.NotificationTypeMention, want: true},
+		{name: "Conversation Overrides User", setting: &domain.NotificationSetting{Level: domain.NotificationLevelNone},
+			participant: domain.ConversationParticipant{NotificationLevel: domain.NotificationLevelAll}, notificationType: domain.NotificationTypeMessage, want: true},
+		{name: "Muted Conversation", participant: domain.ConversationParticipant{MutedUntil: until(time.Hour)}, notificationType: domain.NotificationTypeMention},
+		{name: "Mute Ended", participant: domain.ConversationParticipant{MutedUntil: until(-time.Minute)}, notificationType: domain.NotificationTypeMessage, want: true},
+		{name: "Muted Everywhere Beats Conversation Level", setting: &domain.NotificationSetting{Level: domain.NotificationLevelAll, MutedUntil: until(time.Hour)},
+			participant: domain.ConversationParticipant{NotificationLevel: domain.NotificationLevelAll}, notificationType: domain.NotificationTypeMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.NotificationAllowed(tt.setting, &tt.participant, tt.notificationType, at); got != tt.want {
+				t.Errorf("NotificationAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
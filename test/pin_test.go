@@ -0,0 +1,92 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestCheckPin(t *testing.T) {
+	tests := []struct {
+		name             string
+		staff            bool
+		creator          bool
+		conversationType domain.ConversationType
+		role             string
+		wantCode         apperror.Code
+	}{
+		{name: "Staff Participant", staff: true, conversationType: domain.ConversationTypePrivate, role: domain.ParticipantRoleMember},
+		{name: "Staff Overseeing", staff: true, conversationType: domain.ConversationTypePrivate},
+		{name: "Group Creator", creator: true, conversationType: domain.ConversationTypeGroup, role: domain.ParticipantRoleMember},
+		{name: "Private Chat Creator", creator: true, conversationType: domain.ConversationTypePrivate, role: domain.ParticipantRoleMember, wantCode: apperror.CodeForbidden},
+		{name: "Group Member", conversationType: domain.ConversationTypeGroup, role: domain.ParticipantRoleMember, wantCode: apperror.CodeForbidden},
+		{name: "Observer", staff: true, conversationType: domain.ConversationTypePrivate, role: domain.ParticipantRoleObserver, wantCode: apperror.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.CheckPin(tt.staff, tt.creator, tt.conversationType, tt.role)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("CheckPin() error = %v, want nil", err)
+				}
+				return
+			}
+			var appErr *apperror.Error
+			if !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+				t.Errorf("CheckPin() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPinService_Pin(t *testing.T) {
+	db := newTestDB(t)
+	notifier := newRecordingNotifier()
+	pins := services.NewPinService(db, notifier)
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	message := createMessage(t, db, conversation.ID, bilal.ID, "Jumu'ah starts at 12:30")
+
+	_, err := pins.Pin(bilal.ID, conversation.ID, message.ID)
+	checkCode(t, err, apperror.CodeForbidden)
+
+	first, err := pins.Pin(aisha.ID, conversation.ID, message.ID)
+	if err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	again, err := pins.Pin(aisha.ID, conversation.ID, message.ID)
+	if err != nil {
+		t.Fatalf("Pin() again error = %v", err)
+	}
+	if !again.PinnedAt.Equal(first.PinnedAt) || again.PinnedBy != aisha.ID || again.Message == nil || again.Message.ID != message.ID {
+		t.Errorf("Pin() again = %+v, want the existing pin %+v", again, first)
+	}
+	if len(notifier.broadcasts) != 1 || notifier.broadcasts[0].Type != domain.EventMessagePinned {
+		t.Errorf("broadcasts = %v, want one %s event", notifier.broadcasts, domain.EventMessagePinned)
+	}
+	listed, err := pins.List(bilal.ID, conversation.ID)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(listed) != 1 {
+		t.Errorf("len(pins) = %d, want 1", len(listed))
+	}
+
+	for i := 1; i < services.MaxPinnedMessages; i++ {
+		other := createMessage(t, db, conversation.ID, bilal.ID, "notice")
+		if _, err := pins.Pin(aisha.ID, conversation.ID, other.ID); err != nil {
+			t.Fatalf("Pin() %d error = %v", i, err)
+		}
+	}
+	if _, err := pins.Pin(aisha.ID, conversation.ID, message.ID); err != nil {
+		t.Errorf("Pin() of a pinned message at the limit error = %v, want its pin", err)
+	}
+	extra := createMessage(t, db, conversation.ID, bilal.ID, "one too many")
+	_, err = pins.Pin(aisha.ID, conversation.ID, extra.ID)
+	checkCode(t, err, apperror.CodeConflict)
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestCalculatePrayerTimes(t *testing.T) {
+	makkah := services.Coordinates{Latitude: 21.4225, Longitude: 39.8262}
+	jakarta := services.Coordinates{Latitude: -6.2088, Longitude: 106.8456}
+	newYork := services.Coordinates{Latitude: 40.7128, Longitude: -74.0060}
+	oslo := services.Coordinates{Latitude: 59.9139, Longitude: 10.7522}
+	tromso := services.Coordinates{Latitude: 69.6492, Longitude: 18.9553}
+
+	tests := []struct {
+		name     string
+		timezone string
+		date     time.Time
+		at       services.Coordinates
+		method   domain.PrayerMethod
+		school   domain.AsrSchool
+		// want lists Fajr, Sunrise, Dhuhr, Asr, Maghrib and Isha as HH:MM in
+		// timezone.
+		want    [6]string
+		wantErr bool
+	}{
+		{name: "Makkah Umm al-Qura", timezone: "Asia/Riyadh", date: utcDate(2026, 10, 18), at: makkah, method: domain.PrayerMethodMakkah, school: domain.AsrSchoolStandard,
+			want: [6]string{"05:01", "06:17", "12:06", "15:26", "17:54", "19:24"}},
+		{name: "Makkah Hanafi Asr", timezone: "Asia/Riyadh", date: utcDate(2026, 10, 18), at: makkah, method: domain.PrayerMethodMakkah, school: domain.AsrSchoolHanafi,
+			want: [6]string{"05:01", "06:17", "12:06", "16:17", "17:54", "19:24"}},
+		{name: "Jakarta Kemenag", timezone: "Asia/Jakarta", date: utcDate(2026, 10, 18), at: jakarta, method: domain.PrayerMethodKemenag, school: domain.AsrSchoolStandard,
+			want: [6]string{"04:12", "05:30", "11:38", "14:46", "17:45", "18:56"}},
+		{name: "New York ISNA", timezone: "America/New_York", date: utcDate(2026, 10, 18), at: newYork, method: domain.PrayerMethodISNA, school: domain.AsrSchoolStandard,
+			want: [6]string{"05:55", "07:11", "12:41", "15:43", "18:11", "19:26"}},
+		{name: "High Latitude Summer", timezone: "Europe/Oslo", date: utcDate(2026, 6, 21), at: oslo, method: domain.PrayerMethodMWL, school: domain.AsrSchoolStandard,
+			want: [6]string{"02:21", "03:54", "13:19", "18:00", "22:44", "00:12"}},
+		{name: "Midnight Sun", timezone: "Europe/Oslo", date: utcDate(2026, 6, 21), at: tromso, method: domain.PrayerMethodMWL, school: domain.AsrSchoolStandard, wantErr: true},
+		{name: "Unknown Method", timezone: "UTC", date: utcDate(2026, 10, 18), at: makkah, method: "unknown", school: domain.AsrSchoolStandard, wantErr: true},
+		{name: "Invalid Coordinates", timezone: "UTC", date: utcDate(2026, 10, 18), at: services.Coordinates{Latitude: 91}, method: domain.PrayerMethodMWL, school: domain.AsrSchoolStandard, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, err := time.LoadLocation(tt.timezone)
+			if err != nil {
+				t.Fatalf("LoadLocation() error = %v", err)
+			}
+			times, err := services.CalculatePrayerTimes(tt.date, tt.at, tt.method, tt.school)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalculatePrayerTimes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := [6]string{}
+			for i, at := range []time.Time{times.Fajr, times.Sunrise, times.Dhuhr, times.Asr, times.Maghrib, times.Isha} {
+				got[i] = at.In(location).Format("15:04")
+			}
+			if got != tt.want {
+				t.Errorf("CalculatePrayerTimes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	makkah := services.Coordinates{Latitude: 21.4225, Longitude: 39.8262}
+	riyadh, err := time.LoadLocation("Asia/Riyadh")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, riyadh)
+	}
+	settings := domain.QuietHours{
+		Method:        domain.PrayerMethodMakkah,
+		AsrSchool:     domain.AsrSchoolStandard,
+		Prayers:       "fajr,dhuhr,asr,maghrib,isha",
+		MinutesBefore: 5,
+		MinutesAfter:  20,
+	}
+	// Maghrib and Isha 90 minutes later overlap with a long window.
+	long := settings
+	long.MinutesBefore, long.MinutesAfter = 30, 90
+	fajrOnly := settings
+	fajrOnly.Prayers = "fajr"
+
+	tests := []struct {
+		name      string
+		settings  domain.QuietHours
+		now       time.Time
+		want      time.Time
+		wantQuiet bool
+	}{
+		{name: "Outside Windows", settings: settings, now: at(18, 10, 0), want: at(18, 10, 0)},
+		{name: "Before Dhuhr", settings: settings, now: at(18, 12, 2), want: at(18, 12, 26), wantQuiet: true},
+		{name: "After Asr", settings: settings, now: at(18, 15, 40), want: at(18, 15, 46), wantQuiet: true},
+		{name: "Window End Is Not Quiet", settings: settings, now: at(18, 15, 46), want: at(18, 15, 46)},
+		{name: "Overlapping Windows", settings: long, now: at(18, 17, 30), want: at(18, 20, 54), wantQuiet: true},
+		{name: "Unobserved Prayer", settings: fajrOnly, now: at(18, 12, 10), want: at(18, 12, 10)},
+		{name: "Fajr Of The Next Day", settings: fajrOnly, now: at(19, 5, 0), want: at(19, 5, 21), wantQuiet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, quiet, err := services.QuietUntil(tt.settings, makkah, tt.now)
+			if err != nil {
+				t.Fatalf("QuietUntil() error = %v", err)
+			}
+			if quiet != tt.wantQuiet || !got.Equal(tt.want) {
+				t.Errorf("QuietUntil() = %v, %v, want %v, %v", got.In(riyadh), quiet, tt.want.In(riyadh), tt.wantQuiet)
+			}
+		})
+	}
+}
+
+func TestValidateQuietHours(t *testing.T) {
+	valid := domain.QuietHours{
+		Latitude:      -6.2088,
+		Longitude:     106.8456,
+		Method:        domain.PrayerMethodKemenag,
+		AsrSchool:     domain.AsrSchoolStandard,
+		Prayers:       "fajr,isha",
+		MinutesBefore: 5,
+		MinutesAfter:  20,
+	}
+	with := func(change func(*domain.QuietHours)) domain.QuietHours {
+		settings := valid
+		change(&settings)
+		return settings
+	}
+
+	tests := []struct {
+		name     string
+		settings domain.QuietHours
+		wantErr  bool
+	}{
+		{name: "Valid", settings: valid},
+		{name: "Masjid Location Skips Coordinates", settings: with(func(s *domain.QuietHours) { s.UseMasjidLocation, s.Latitude = true, 200 })},
+		{name: "Invalid Coordinates", settings: with(func(s *domain.QuietHours) { s.Longitude = 181 }), wantErr: true},
+		{name: "Unknown Method", settings: with(func(s *domain.QuietHours) { s.Method = "jafari" }), wantErr: true},
+		{name: "Unknown Asr School", settings: with(func(s *domain.QuietHours) { s.AsrSchool = "maliki" }), wantErr: true},
+		{name: "Unknown Prayer", settings: with(func(s *domain.QuietHours) { s.Prayers = "fajr,tahajjud" }), wantErr: true},
+		{name: "No Prayers", settings: with(func(s *domain.QuietHours) { s.Prayers = "" }), wantErr: true},
+		{name: "Window Too Long", settings: with(func(s *domain.QuietHours) { s.MinutesAfter = 121 }), wantErr: true},
+		{name: "Empty Window", settings: with(func(s *domain.QuietHours) { s.MinutesBefore, s.MinutesAfter = 0, 0 }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.ValidateQuietHours(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateQuietHours() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func utcDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestQuietHoursService_SetEnabled(t *testing.T) {
+	db := newTestDB(t)
+	quietHours := services.NewQuietHoursService(db, nil)
+	user := createUser(t, db, "aisha")
+	settings := domain.QuietHours{
+		Latitude:      -6.2088,
+		Longitude:     106.8456,
+		Method:        domain.PrayerMethodKemenag,
+		AsrSchool:     domain.AsrSchoolStandard,
+		Prayers:       "fajr,isha",
+		MinutesBefore: 5,
+		MinutesAfter:  20,
+	}
+
+	for _, enabled := range []bool{false, true, false} {
+		settings.Enabled = enabled
+		if _, err := quietHours.Set(user.ID, settings); err != nil {
+			t.Fatalf("Set(enabled: %t) error = %v", enabled, err)
+		}
+		stored, err := quietHours.Get(user.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if stored.Enabled != enabled {
+			t.Errorf("Enabled after setting enabled: %t = %t", enabled, stored.Enabled)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestReportService_Report(t *testing.T) {
+	db := newTestDB(t)
+	reports := services.NewReportService(db, newRecordingNotifier())
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	outsider := createUser(t, db, "outsider")
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	message := createMessage(t, db, conversation.ID, bilal.ID, "buy now")
+
+	tests := []struct {
+		name     string
+		report   func() (*domain.Report, error)
+		wantCode apperror.Code
+	}{
+		{
+			name: "Message",
+			report: func() (*domain.Report, error) {
+				return reports.ReportMessage(aisha.ID, message.ID, domain.ReportReasonSpam, "")
+			},
+		},
+		{
+			name: "Own Message",
+			report: func() (*domain.Report, error) {
+				return reports.ReportMessage(bilal.ID, message.ID, domain.ReportReasonSpam, "")
+			},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name: "Message As Outsider",
+			report: func() (*domain.Report, error) {
+				return reports.ReportMessage(outsider.ID, message.ID, domain.ReportReasonSpam, "")
+			},
+			wantCode: apperror.CodeForbidden,
+		},
+		{
+			name:     "Invalid Reason",
+			report:   func() (*domain.Report, error) { return reports.ReportMessage(aisha.ID, message.ID, "rude", "") },
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name: "Unknown Message",
+			report: func() (*domain.Report, error) {
+				return reports.ReportMessage(aisha.ID, uuid.New(), domain.ReportReasonSpam, "")
+			},
+			wantCode: apperror.CodeNotFound,
+		},
+		{
+			name: "User",
+			report: func() (*domain.Report, error) {
+				return reports.ReportUser(aisha.ID, bilal.ID, conversation.ID, domain.ReportReasonHarassment, "")
+			},
+		},
+		{
+			name: "Self",
+			report: func() (*domain.Report, error) {
+				return reports.ReportUser(aisha.ID, aisha.ID, conversation.ID, domain.ReportReasonHarassment, "")
+			},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name: "User Outside Conversation",
+			report: func() (*domain.Report, error) {
+				return reports.ReportUser(aisha.ID, outsider.ID, conversation.ID, domain.ReportReasonHarassment, "")
+			},
+			wantCode: apperror.CodeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := tt.report()
+			checkCode(t, err, tt.wantCode)
+			if err == nil && report.Status != domain.ReportStatusOpen {
+				t.Errorf("Status = %s, want %s", report.Status, domain.ReportStatusOpen)
+			}
+		})
+	}
+}
+
+func TestReportService_ListOpenReports(t *testing.T) {
+	db := newTestDB(t)
+	reports := services.NewReportService(db, newRecordingNotifier())
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	moderator := createUser(t, db, "moderator")
+	addStaff(t, db, moderator.ID, domain.StaffRoleModerator)
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	createMessage(t, db, conversation.ID, aisha.ID, "before")
+	message := createMessage(t, db, conversation.ID, bilal.ID, "buy now")
+	createMessage(t, db, conversation.ID, aisha.ID, "after")
+	if _, err := reports.ReportMessage(aisha.ID, message.ID, domain.ReportReasonSpam, ""); err != nil {
+		t.Fatalf("ReportMessage() error = %v", err)
+	}
+
+	_, err := reports.ListOpenReports(aisha.ID, 10, 0)
+	checkCode(t, err, apperror.CodeForbidden)
+
+	queue, err := reports.ListOpenReports(moderator.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("ListOpenReports() error = %v", err)
+	}
+	if len(queue) != 1 {
+		t.Fatalf("len(queue) = %d, want 1", len(queue))
+	}
+	var contents []string
+	for _, m := range queue[0].Messages {
+		contents = append(contents, m.Content)
+	}
+	if len(contents) != 3 || contents[1] != "buy now" {
+		t.Errorf("context = %v, want the reported message between its neighbours", contents)
+	}
+}
+
+func TestReportService_ApplyAction(t *testing.T) {
+	tests := []struct {
+		name          string
+		action        domain.ModeratorAction
+		reportUser    bool
+		duration      time.Duration
+		wantCode      apperror.Code
+		wantStatus    domain.ReportStatus
+		wantSanction  domain.SanctionType
+		wantSendCode  apperror.Code
+		wantEvent     domain.EventType
+		wantBroadcast bool
+		wantDeleted   bool
+	}{
+		{name: "Dismiss", action: domain.ModeratorActionDismiss, wantStatus: domain.ReportStatusDismissed},
+		{name: "Delete Message", action: domain.ModeratorActionDeleteMessage, wantStatus: domain.ReportStatusActioned, wantEvent: domain.EventMessageDeleted, wantBroadcast: true, wantDeleted: true},
+		{name: "Delete Without Message", action: domain.ModeratorActionDeleteMessage, reportUser: true, wantCode: apperror.CodeInvalidRequest},
+		{name: "Mute", action: domain.ModeratorActionMuteUser, duration: time.Hour, wantStatus: domain.ReportStatusActioned, wantSanction: domain.SanctionTypeMute, wantSendCode: apperror.CodeForbidden, wantEvent: domain.EventUserMuted},
+		{name: "Ban", action: domain.ModeratorActionBanUser, reportUser: true, wantStatus: domain.ReportStatusActioned, wantSanction: domain.SanctionTypeBan, wantSendCode: apperror.CodeForbidden, wantEvent: domain.EventUserBanned},
+		{name: "Invalid Action", action: "warn", wantCode: apperror.CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			notifier := newRecordingNotifier()
+			reports := services.NewReportService(db, notifier)
+			aisha := createUser(t, db, "aisha")
+			bilal := createUser(t, db, "bilal")
+			moderator := createUser(t, db, "moderator")
+			addStaff(t, db, moderator.ID, domain.StaffRoleModerator)
+			conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+			message := createMessage(t, db, conversation.ID, bilal.ID, "buy now")
+
+			var report *domain.Report
+			var err error
+			if tt.reportUser {
+				report, err = reports.ReportUser(aisha.ID, bilal.ID, conversation.ID, domain.ReportReasonHarassment, "")
+			} else {
+				report, err = reports.ReportMessage(aisha.ID, message.ID, domain.ReportReasonSpam, "")
+			}
+			if err != nil {
+				t.Fatalf("report error = %v", err)
+			}
+
+			_, err = reports.ApplyAction(aisha.ID, report.ID, tt.action, tt.duration, "")
+			checkCode(t, err, apperror.CodeForbidden)
+
+			resolved, err := reports.ApplyAction(moderator.ID, report.ID, tt.action, tt.duration, "checked")
+			checkCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if resolved.Status != tt.wantStatus || resolved.ModeratorNote != "checked" {
+				t.Errorf("Status = %s, note = %q, want %s and the note", resolved.Status, resolved.ModeratorNote, tt.wantStatus)
+			}
+			_, err = reports.ApplyAction(moderator.ID, report.ID, tt.action, tt.duration, "")
+			checkCode(t, err, apperror.CodeConflict)
+
+			var sanctions []domain.UserSanction
+			db.Where("user_id = ?", bilal.ID).Find(&sanctions)
+			if tt.wantSanction == "" && len(sanctions) != 0 {
+				t.Errorf("sanctions = %v, want none", sanctions)
+			}
+			if tt.wantSanction != "" {
+				if len(sanctions) != 1 || sanctions[0].Type != tt.wantSanction {
+					t.Fatalf("sanctions = %v, want one %s", sanctions, tt.wantSanction)
+				}
+				if sanctions[0].ExpiresAt.Valid != (tt.duration > 0) {
+					t.Errorf("ExpiresAt.Valid = %t, want %t", sanctions[0].ExpiresAt.Valid, tt.duration > 0)
+				}
+			}
+
+			_, err = newChatService(db, notifier).SendMessage(bilal.ID, conversation.ID, "hello again", "text", "", nil, nil, nil)
+			checkCode(t, err, tt.wantSendCode)
+
+			if tt.wantEvent != "" {
+				events := notifier.userEvents[bilal.ID]
+				if tt.wantBroadcast {
+					events = notifier.broadcasts
+				}
+				if !hasEvent(events, tt.wantEvent) {
+					t.Errorf("events = %v, want a %s event", events, tt.wantEvent)
+				}
+			}
+			if banned := len(notifier.disconnected) > 0; banned != (tt.wantSanction == domain.SanctionTypeBan) {
+				t.Errorf("disconnected = %v, want a disconnect only for a ban", notifier.disconnected)
+			}
+			var remaining int64
+			db.Model(&domain.Message{}).Where("id = ?", message.ID).Count(&remaining)
+			if deleted := remaining == 0; deleted != tt.wantDeleted {
+				t.Errorf("message deleted = %t, want %t", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestReportService_ApplyActionOnce(t *testing.T) {
+	db := newTestDB(t)
+	reports := services.NewReportService(db, newRecordingNotifier())
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	report, err := reports.ReportUser(aisha.ID, bilal.ID, conversation.ID, domain.ReportReasonHarassment, "")
+	if err != nil {
+		t.Fatalf("ReportUser() error = %v", err)
+	}
+
+	const moderators = 5
+	errs := make([]error, moderators)
+	var wg sync.WaitGroup
+	for i := range errs {
+		moderator := createUser(t, db, fmt.Sprintf("moderator%d", i))
+		addStaff(t, db, moderator.ID, domain.StaffRoleModerator)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = reports.ApplyAction(moderator.ID, report.ID, domain.ModeratorActionMuteUser, time.Hour, "")
+		}(i)
+	}
+	wg.Wait()
+
+	resolved := 0
+	for _, err := range errs {
+		if err == nil {
+			resolved++
+			continue
+		}
+		checkCode(t, err, apperror.CodeConflict)
+	}
+	if resolved != 1 {
+		t.Errorf("resolved %d times, want once", resolved)
+	}
+	var sanctions int64
+	db.Model(&domain.UserSanction{}).Where("user_id = ?", bilal.ID).Count(&sanctions)
+	if sanctions != 1 {
+		t.Errorf("sanctions = %d, want 1", sanctions)
+	}
+}
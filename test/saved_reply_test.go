@@ -0,0 +1,58 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+func TestExpandSavedReply(t *testing.T) {
+	values := map[string]string{
+		services.PlaceholderFirstName: "Aisha",
+		services.PlaceholderPurpose:   "general support",
+		services.PlaceholderDate:      "18 October 2026",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		values  map[string]string
+		want    string
+	}{
+		{name: "No Placeholders", values: values, content: "Thank you for waiting.", want: "Thank you for waiting."},
+		{name: "All Placeholders", values: values, content: "Salam {{first_name}}, about your {{purpose}} request of {{date}}:", want: "Salam Aisha, about your general support request of 18 October 2026:"},
+		{name: "Spaces Inside Braces", values: values, content: "Hi {{ first_name }}!", want: "Hi Aisha!"},
+		{name: "Repeated Placeholder", values: values, content: "{{first_name}}, {{first_name}}", want: "Aisha, Aisha"},
+		{name: "Missing Value Left As Is", values: map[string]string{}, content: "Hi {{first_name}}", want: "Hi {{first_name}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.ExpandSavedReply(tt.content, tt.values); got != tt.want {
+				t.Errorf("ExpandSavedReply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSavedReplyContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "Plain Text", content: "We will get back to you shortly.", wantErr: false},
+		{name: "Known Placeholders", content: "Salam {{first_name}}, today is {{date}}.", wantErr: false},
+		{name: "Empty", content: "   ", wantErr: true},
+		{name: "Unknown Placeholder", content: "Your ticket {{ticket_id}} is open.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.ValidateSavedReplyContent(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSavedReplyContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
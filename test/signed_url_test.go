@@ -0,0 +1,44 @@
+package test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/auth"
+)
+
+func TestURLSigner_Verify(t *testing.T) {
+	signer := auth.NewURLSigner("test-secret", time.Minute)
+	attachmentID := uuid.New().String()
+	userID := uuid.New()
+	expiresAt, signature := signer.Sign(attachmentID, userID)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	tests := []struct {
+		name       string
+		resourceID string
+		userID     uuid.UUID
+		expires    string
+		signature  string
+		wantErr    bool
+	}{
+		{name: "Valid Signature", resourceID: attachmentID, userID: userID, expires: expires, signature: signature},
+		{name: "Other User", resourceID: attachmentID, userID: uuid.New(), expires: expires, signature: signature, wantErr: true},
+		{name: "Other Attachment", resourceID: uuid.New().String(), userID: userID, expires: expires, signature: signature, wantErr: true},
+		{name: "Other Variant", resourceID: attachmentID + "/thumbnail", userID: userID, expires: expires, signature: signature, wantErr: true},
+		{name: "Extended Expiry", resourceID: attachmentID, userID: userID, expires: strconv.FormatInt(expiresAt.Add(time.Hour).Unix(), 10), signature: signature, wantErr: true},
+		{name: "Expired", resourceID: attachmentID, userID: userID, expires: strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10), signature: signature, wantErr: true},
+		{name: "Malformed Expiry", resourceID: attachmentID, userID: userID, expires: "tomorrow", signature: signature, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := signer.Verify(tt.resourceID, tt.userID, tt.expires, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
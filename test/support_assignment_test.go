@@ -0,0 +1,51 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+)
+
+func TestAssignmentStrategy_Pick(t *testing.T) {
+	busyRecent := services.AgentCandidate{AgentID: fixtureID(1), OpenTickets: 3, LastAssignedAt: fixtureTime.Add(time.Hour)}
+	idleRecent := services.AgentCandidate{AgentID: fixtureID(2), OpenTickets: 1, LastAssignedAt: fixtureTime.Add(2 * time.Hour)}
+	idleOld := services.AgentCandidate{AgentID: fixtureID(3), OpenTickets: 1, LastAssignedAt: fixtureTime}
+	neverAssigned := services.AgentCandidate{AgentID: fixtureID(4), OpenTickets: 2}
+
+	tests := []struct {
+		name       string
+		strategy   string
+		candidates []services.AgentCandidate
+		want       uuid.UUID
+		wantOK     bool
+	}{
+		{name: "No Candidates", strategy: "least_load", wantOK: false},
+		{name: "Least Load Picks Fewest Tickets", strategy: "least_load", candidates: []services.AgentCandidate{busyRecent, neverAssigned, idleRecent}, want: idleRecent.AgentID, wantOK: true},
+		{name: "Least Load Ties By Longest Wait", strategy: "least_load", candidates: []services.AgentCandidate{idleRecent, idleOld}, want: idleOld.AgentID, wantOK: true},
+		{name: "Round Robin Picks Never Assigned", strategy: "round_robin", candidates: []services.AgentCandidate{busyRecent, idleOld, neverAssigned}, want: neverAssigned.AgentID, wantOK: true},
+		{name: "Round Robin Ignores Load", strategy: "round_robin", candidates: []services.AgentCandidate{idleRecent, busyRecent}, want: busyRecent.AgentID, wantOK: true},
+		{name: "Default Is Least Load", strategy: "", candidates: []services.AgentCandidate{busyRecent, idleRecent}, want: idleRecent.AgentID, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := services.NewAssignmentStrategy(tt.strategy)
+			if err != nil {
+				t.Fatalf("NewAssignmentStrategy(%q) error = %v", tt.strategy, err)
+			}
+			got, ok := strategy.Pick(tt.candidates)
+			if ok != tt.wantOK {
+				t.Fatalf("Pick() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got.AgentID != tt.want {
+				t.Errorf("Pick() got = %s, want %s", got.AgentID, tt.want)
+			}
+		})
+	}
+
+	if _, err := services.NewAssignmentStrategy("random"); err == nil {
+		t.Error("NewAssignmentStrategy(\"random\") error = nil, want an error")
+	}
+}
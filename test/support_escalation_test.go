@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestSupportService_EscalatesAsSystem(t *testing.T) {
+	db := newTestDB(t)
+	notifier := newRecordingNotifier()
+	targets := services.SLATargets{domain.ConversationPurposeGeneralSupport: {FirstResponse: time.Minute}}
+	support := services.NewSupportService(db, notifier, services.LeastLoadStrategy{}, 5, targets)
+	first := createUser(t, db, "first")
+	addStaff(t, db, first.ID, domain.StaffRoleAgent)
+	if _, err := support.SetAvailability(first.ID, true); err != nil {
+		t.Fatalf("SetAvailability() error = %v", err)
+	}
+	requester := createUser(t, db, "requester")
+	conversation, err := support.OpenTicket(requester.ID, domain.ConversationPurposeGeneralSupport)
+	if err != nil {
+		t.Fatalf("OpenTicket() error = %v", err)
+	}
+	second := createUser(t, db, "second")
+	addStaff(t, db, second.ID, domain.StaffRoleAgent)
+	if _, err := support.SetAvailability(second.ID, true); err != nil {
+		t.Fatalf("SetAvailability() error = %v", err)
+	}
+	db.Model(&domain.SupportTicket{}).Where("conversation_id = ?", conversation.ID).Update("created_at", time.Now().Add(-time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		support.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	<-done
+
+	var ticket domain.SupportTicket
+	db.First(&ticket, "conversation_id = ?", conversation.ID)
+	if !ticket.EscalatedAt.Valid || ticket.AgentID.String != second.ID.String() {
+		t.Fatalf("ticket = %+v, want it escalated to the second agent", ticket)
+	}
+	var messages []domain.Message
+	db.Where("conversation_id = ? AND message_type = ?", conversation.ID, domain.MessageTypeSystem).Find(&messages)
+	if len(messages) != 2 {
+		t.Fatalf("system messages = %d, want the transfer and the escalation", len(messages))
+	}
+	for _, message := range messages {
+		if message.SenderID != uuid.Nil {
+			t.Errorf("message %q sent by %s, want the nil UUID", message.Content, message.SenderID)
+		}
+	}
+}
+
+func TestSupportService_Transfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   domain.SupportTicketStatus
+		wantCode apperror.Code
+	}{
+		{name: "Assigned", status: domain.SupportTicketStatusAssigned},
+		{name: "Resolved", status: domain.SupportTicketStatusResolved, wantCode: apperror.CodeConflict},
+		{name: "Closed", status: domain.SupportTicketStatusClosed, wantCode: apperror.CodeConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			support := newSupportService(db, newRecordingNotifier(), 5)
+			first := createUser(t, db, "first")
+			second := createUser(t, db, "second")
+			for _, agent := range []domain.User{first, second} {
+				addStaff(t, db, agent.ID, domain.StaffRoleAgent)
+			}
+			if _, err := support.SetAvailability(first.ID, true); err != nil {
+				t.Fatalf("SetAvailability() error = %v", err)
+			}
+			requester := createUser(t, db, "requester")
+			conversation, err := support.OpenTicket(requester.ID, domain.ConversationPurposeGeneralSupport)
+			if err != nil {
+				t.Fatalf("OpenTicket() error = %v", err)
+			}
+			var ticket domain.SupportTicket
+			db.First(&ticket, "conversation_id = ?", conversation.ID)
+			// The agent answered before the transfer, and may have resolved it.
+			answeredAt := time.Now().Add(-time.Minute)
+			db.Model(&domain.SupportTicket{}).Where("id = ?", ticket.ID).
+				Updates(map[string]interface{}{"status": tt.status, "first_response_at": answeredAt})
+
+			_, err = support.Transfer(first.ID, ticket.ID, second.ID)
+			checkCode(t, err, tt.wantCode)
+
+			var stored domain.SupportTicket
+			db.First(&stored, "id = ?", ticket.ID)
+			wantAgent, wantStatus := first.ID, tt.status
+			if tt.wantCode == "" {
+				wantAgent, wantStatus = second.ID, domain.SupportTicketStatusAssigned
+			}
+			if stored.AgentID.String != wantAgent.String() || stored.Status != wantStatus {
+				t.Errorf("ticket = %s with agent %s, want %s with agent %s", stored.Status, stored.AgentID.String, wantStatus, wantAgent)
+			}
+			if !stored.FirstResponseAt.Valid {
+				t.Error("FirstResponseAt was cleared")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestSLATarget_Breaches(t *testing.T) {
+	opened := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	target := services.SLATarget{FirstResponse: time.Hour, Resolution: 24 * time.Hour}
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: opened.Add(d), Valid: true} }
+
+	tests := []struct {
+		name   string
+		target services.SLATarget
+		ticket domain.SupportTicket
+		now    time.Time
+		want   []domain.SLAKind
+	}{
+		{name: "Within Targets", target: target, ticket: domain.SupportTicket{CreatedAt: opened}, now: opened.Add(30 * time.Minute), want: nil},
+		{name: "Unanswered Past Target", target: target, ticket: domain.SupportTicket{CreatedAt: opened}, now: opened.Add(2 * time.Hour), want: []domain.SLAKind{domain.SLAKindFirstResponse}},
+		{name: "Answered In Time", target: target, ticket: domain.SupportTicket{CreatedAt: opened, FirstResponseAt: at(10 * time.Minute)}, now: opened.Add(2 * time.Hour), want: nil},
+		{name: "Answered Late", target: target, ticket: domain.SupportTicket{CreatedAt: opened, FirstResponseAt: at(90 * time.Minute)}, now: opened.Add(2 * time.Hour), want: []domain.SLAKind{domain.SLAKindFirstResponse}},
+		{name: "Unresolved Past Target", target: target, ticket: domain.SupportTicket{CreatedAt: opened, FirstResponseAt: at(time.Minute)}, now: opened.Add(25 * time.Hour), want: []domain.SLAKind{domain.SLAKindResolution}},
+		{name: "Resolved In Time", target: target, ticket: domain.SupportTicket{CreatedAt: opened, FirstResponseAt: at(time.Minute), ResolvedAt: at(time.Hour)}, now: opened.Add(48 * time.Hour), want: nil},
+		{name: "Untracked Target", target: services.SLATarget{}, ticket: domain.SupportTicket{CreatedAt: opened}, now: opened.Add(100 * time.Hour), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.Breaches(tt.ticket, tt.now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Breaches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeSLA(t *testing.T) {
+	opened := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: opened.Add(d), Valid: true} }
+	targets := services.SLATargets{
+		domain.ConversationPurposeAdminSupport: {FirstResponse: time.Hour, Resolution: 24 * time.Hour},
+	}
+	tickets := []domain.SupportTicket{
+		{Purpose: domain.ConversationPurposeAdminSupport, CreatedAt: opened, FirstResponseAt: at(20 * time.Minute), ResolvedAt: at(2 * time.Hour)},
+		{Purpose: domain.ConversationPurposeAdminSupport, CreatedAt: opened, FirstResponseAt: at(100 * time.Minute), ResolvedAt: at(30 * time.Hour)},
+		{Purpose: domain.ConversationPurposeAdminSupport, CreatedAt: opened},
+		{Purpose: domain.ConversationPurposeGeneralSupport, CreatedAt: opened, FirstResponseAt: at(5 * time.Hour)},
+	}
+
+	got := services.SummarizeSLA(tickets, targets, opened.Add(3*time.Hour))
+	want := []services.SLAReport{
+		{
+			Purpose:                     domain.ConversationPurposeAdminSupport,
+			FirstResponseTargetSeconds:  3600,
+			ResolutionTargetSeconds:     86400,
+			Tickets:                     3,
+			Responded:                   2,
+			AverageFirstResponseSeconds: 3600,
+			FirstResponseBreaches:       2,
+			Resolved:                    2,
+			AverageResolutionSeconds:    16 * 3600,
+			ResolutionBreaches:          1,
+		},
+		{
+			Purpose:                     domain.ConversationPurposeGeneralSupport,
+			Tickets:                     1,
+			Responded:                   1,
+			AverageFirstResponseSeconds: 5 * 3600,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeSLA() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/masjids-io/limestone-chat/internal/application/services"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+)
+
+func TestValidateSurveyResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		rating  int
+		comment string
+		wantErr bool
+	}{
+		{name: "Lowest Rating", rating: 1, wantErr: false},
+		{name: "Highest Rating With Comment", rating: 5, comment: "Very helpful", wantErr: false},
+		{name: "Missing Rating", rating: 0, wantErr: true},
+		{name: "Rating Too High", rating: 6, wantErr: true},
+		{name: "Comment Too Long", rating: 3, comment: string(make([]rune, 1001)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.ValidateSurveyResponse(tt.rating, tt.comment)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSurveyResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSummarizeSurveys(t *testing.T) {
+	agentA := sql.NullString{String: "00000000-0000-0000-0000-00000000000a", Valid: true}
+	agentB := sql.NullString{String: "00000000-0000-0000-0000-00000000000b", Valid: true}
+	rated := func(r int16) sql.NullInt16 { return sql.NullInt16{Int16: r, Valid: true} }
+	surveys := []domain.SatisfactionSurvey{
+		{Purpose: domain.ConversationPurposeAdminSupport, AgentID: agentA, Rating: rated(5)},
+		{Purpose: domain.ConversationPurposeAdminSupport, AgentID: agentA, Rating: rated(4)},
+		{Purpose: domain.ConversationPurposeGeneralSupport, AgentID: agentA},
+		{Purpose: domain.ConversationPurposeGeneralSupport, AgentID: agentB, Rating: rated(1)},
+		{Purpose: domain.ConversationPurposeRevertService, Rating: rated(3)},
+	}
+
+	tests := []struct {
+		name    string
+		groupBy string
+		want    []services.SurveySummary
+		wantErr bool
+	}{
+		{
+			name:    "By Agent",
+			groupBy: services.SurveyGroupByAgent,
+			want: []services.SurveySummary{
+				{Group: agentA.String, Sent: 3, Answered: 2, AverageRating: 4.5, Ratings: [5]int{0, 0, 0, 1, 1}},
+				{Group: agentB.String, Sent: 1, Answered: 1, AverageRating: 1, Ratings: [5]int{1, 0, 0, 0, 0}},
+				{Group: "unassigned", Sent: 1, Answered: 1, AverageRating: 3, Ratings: [5]int{0, 0, 1, 0, 0}},
+			},
+		},
+		{
+			name:    "By Purpose",
+			groupBy: services.SurveyGroupByPurpose,
+			want: []services.SurveySummary{
+				{Group: "admin_support", Sent: 2, Answered: 2, AverageRating: 4.5, Ratings: [5]int{0, 0, 0, 1, 1}},
+				{Group: "general_support", Sent: 2, Answered: 1, AverageRating: 1, Ratings: [5]int{1, 0, 0, 0, 0}},
+				{Group: "revert_service", Sent: 1, Answered: 1, AverageRating: 3, Ratings: [5]int{0, 0, 1, 0, 0}},
+			},
+		},
+		{name: "Unknown Grouping", groupBy: "month", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := services.SummarizeSurveys(surveys, tt.groupBy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SummarizeSurveys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SummarizeSurveys() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
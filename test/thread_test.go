@@ -0,0 +1,227 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/masjids-io/limestone-chat/internal/apperror"
+	"github.com/masjids-io/limestone-chat/internal/domain"
+	"github.com/masjids-io/limestone-chat/internal/infrastructure/websocket"
+)
+
+func TestSendMessage_Reply(t *testing.T) {
+	db := newTestDB(t)
+	chat := newChatService(db, newRecordingNotifier())
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	other := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	root := createMessage(t, db, conversation.ID, aisha.ID, "Who is coming on Friday?")
+	first := createMessage(t, db, conversation.ID, bilal.ID, "Not sure yet")
+	elsewhere := createMessage(t, db, other.ID, aisha.ID, "salam")
+	deleted := createMessage(t, db, conversation.ID, aisha.ID, "oops")
+	db.Delete(&deleted)
+
+	tests := []struct {
+		name           string
+		replyTo        uuid.UUID
+		wantCode       apperror.Code
+		wantReplyCount int
+	}{
+		{name: "To Root", replyTo: root.ID, wantReplyCount: 1},
+		{name: "To Reply", replyTo: uuid.Nil, wantReplyCount: 2},
+		{name: "To Unthreaded Message", replyTo: first.ID, wantReplyCount: 1},
+		{name: "Other Conversation", replyTo: elsewhere.ID, wantCode: apperror.CodeInvalidRequest},
+		{name: "Deleted Message", replyTo: deleted.ID, wantCode: apperror.CodeInvalidRequest},
+		{name: "Unknown Message", replyTo: uuid.New(), wantCode: apperror.CodeInvalidRequest},
+	}
+
+	var lastReply uuid.UUID
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replyTo := tt.replyTo
+			wantRoot := replyTo
+			if replyTo == uuid.Nil {
+				replyTo, wantRoot = lastReply, root.ID
+			}
+			reply, err := chat.SendMessage(bilal.ID, conversation.ID, "I am", "text", "", nil, &replyTo, nil)
+			checkCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			lastReply = reply.ID
+			if reply.ReplyToMessageID.String != replyTo.String() || reply.ThreadRootID.String != wantRoot.String() {
+				t.Errorf("reply_to = %s, thread_root = %s, want %s and %s", reply.ReplyToMessageID.String, reply.ThreadRootID.String, replyTo, wantRoot)
+			}
+			var stored domain.Message
+			db.First(&stored, "id = ?", wantRoot)
+			if stored.ReplyCount != tt.wantReplyCount || !stored.LastReplyAt.Valid || !stored.LastReplyAt.Time.Equal(reply.CreatedAt) {
+				t.Errorf("root reply_count = %d, last_reply_at = %v, want %d at %v", stored.ReplyCount, stored.LastReplyAt, tt.wantReplyCount, reply.CreatedAt)
+			}
+			if reply.ThreadRoot == nil || reply.ThreadRoot.ReplyCount != tt.wantReplyCount {
+				t.Errorf("ThreadRoot = %+v, want the root with %d replies", reply.ThreadRoot, tt.wantReplyCount)
+			}
+		})
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	db := newTestDB(t)
+	chat := newChatService(db, newRecordingNotifier())
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	outsider := createUser(t, db, "outsider")
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal)
+	root := createMessage(t, db, conversation.ID, aisha.ID, "Who is coming on Friday?")
+	var replies []uuid.UUID
+	for _, content := range []string{"I am", "Me too", "Insha'Allah"} {
+		reply, err := chat.SendMessage(bilal.ID, conversation.ID, content, "text", "", nil, &root.ID, nil)
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		replies = append(replies, reply.ID)
+	}
+
+	tests := []struct {
+		name      string
+		userID    uuid.UUID
+		messageID uuid.UUID
+		offset    int
+		wantCode  apperror.Code
+		want      []uuid.UUID
+	}{
+		{name: "By Root", userID: aisha.ID, messageID: root.ID, want: replies},
+		{name: "By Reply", userID: aisha.ID, messageID: replies[2], want: replies},
+		{name: "Page", userID: aisha.ID, messageID: root.ID, offset: 2, want: replies[2:]},
+		{name: "Outsider", userID: outsider.ID, messageID: root.ID, wantCode: apperror.CodeForbidden},
+		{name: "Unknown Message", userID: aisha.ID, messageID: uuid.New(), wantCode: apperror.CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRoot, got, err := chat.GetThread(tt.userID, tt.messageID, 50, tt.offset)
+			checkCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if gotRoot.ID != root.ID || gotRoot.ReplyCount != len(replies) {
+				t.Errorf("root = %s with %d replies, want %s with %d", gotRoot.ID, gotRoot.ReplyCount, root.ID, len(replies))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("len(replies) = %d, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i] {
+					t.Errorf("replies[%d] = %s, want %s", i, got[i].ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestHub_ThreadSubscriptions checks that every connection gets a thread's
+// replies and only subscribed ones its thread_updated events.
+func TestHub_ThreadSubscriptions(t *testing.T) {
+	db := newTestDB(t)
+	aisha := createUser(t, db, "aisha")
+	bilal := createUser(t, db, "bilal")
+	omar := createUser(t, db, "omar")
+	conversation := createConversation(t, db, domain.ConversationTypeGroup, domain.ConversationPurposeGeneralSupport, aisha, bilal, omar)
+	root := createMessage(t, db, conversation.ID, aisha.ID, "Who is coming on Friday?")
+
+	hub := websocket.NewHub(newChatService(db, newRecordingNotifier()), db)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		websocket.ServeConversation(hub, w, r, uuid.MustParse(r.URL.Query().Get("user_id")), &conversation)
+	}))
+	t.Cleanup(server.Close)
+	sender := dialConversation(t, server, aisha.ID)
+	subscriber := dialConversation(t, server, bilal.ID)
+	other := dialConversation(t, server, omar.ID)
+
+	tests := []struct {
+		name      string
+		op        string
+		threadID  uuid.UUID
+		wantCode  apperror.Code
+		wantEvent bool
+	}{
+		{name: "Subscribe", op: "subscribe_thread", threadID: root.ID, wantEvent: true},
+		{name: "Unsubscribe", op: "unsubscribe_thread", threadID: root.ID},
+		{name: "Unknown Thread", op: "subscribe_thread", threadID: uuid.New(), wantCode: apperror.CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFrame(t, subscriber, map[string]interface{}{"op": tt.op, "thread_id": tt.threadID, "request_id": "subscribe"})
+			if tt.wantCode != "" {
+				if frame := readFrame(t, subscriber); !strings.Contains(string(frame["error"]), string(tt.wantCode)) {
+					t.Errorf("frame = %v, want a %s error", frame, tt.wantCode)
+				}
+				return
+			}
+			awaitOps(t, subscriber)
+
+			writeFrame(t, sender, map[string]interface{}{"type": "text", "content": "I am", "reply_to_message_id": root.ID})
+			for _, conn := range []*gorillaws.Conn{subscriber, other} {
+				if frame := readFrame(t, conn); frame["id"] == nil || !strings.Contains(string(frame["thread_root_id"]), root.ID.String()) {
+					t.Errorf("frame = %v, want the reply", frame)
+				}
+			}
+			if frame := readFrame(t, sender); frame["id"] == nil {
+				t.Errorf("sender's frame = %v, want the reply", frame)
+			}
+			awaitOps(t, other)
+			if !tt.wantEvent {
+				awaitOps(t, subscriber)
+				return
+			}
+			if frame := readFrame(t, subscriber); string(frame["event"]) != `"thread_updated"` {
+				t.Errorf("frame = %v, want a thread_updated event", frame)
+			}
+		})
+	}
+}
+
+func dialConversation(t *testing.T, server *httptest.Server, userID uuid.UUID) *gorillaws.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?user_id=" + userID.String()
+	conn, _, err := gorillaws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to dial hub: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func writeFrame(t *testing.T, conn *gorillaws.Conn, frame map[string]interface{}) {
+	t.Helper()
+	if err := conn.WriteJSON(frame); err != nil {
+		t.Fatalf("failed to write frame: %v", err)
+	}
+}
+
+func readFrame(t *testing.T, conn *gorillaws.Conn) map[string]json.RawMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame map[string]json.RawMessage
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("failed to read frame: %v", err)
+	}
+	return frame
+}
+
+// awaitOps sends an unsupported op and reads up to its error, so the ops sent
+// before it were handled and nothing else was queued in between.
+func awaitOps(t *testing.T, conn *gorillaws.Conn) {
+	t.Helper()
+	writeFrame(t, conn, map[string]interface{}{"op": "sync", "request_id": "sync"})
+	if frame := readFrame(t, conn); !strings.Contains(string(frame["error"]), `"sync"`) {
+		t.Fatalf("frame = %v, want the sync error", frame)
+	}
+}